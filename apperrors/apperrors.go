@@ -0,0 +1,193 @@
+// Package apperrors defines the errors the API answers with.
+//
+// Every error carries an HTTP status, a stable machine-readable code that
+// clients can switch on, a human-readable message and, for invalid input, the
+// fields at fault. The underlying cause is kept for the log but never sent.
+package apperrors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Code identifies the kind of an error; codes never change once published
+type Code string
+
+// Generic codes
+const (
+	CodeInvalidInput     Code = "INVALID_INPUT"     // The body is not valid JSON or does not match the expected types
+	CodeValidationFailed Code = "VALIDATION_FAILED" // The body is well formed but fields are invalid, see details
+	CodeInvalidQuery     Code = "INVALID_QUERY"     // A query parameter is invalid, see details
+	CodeUnauthorized     Code = "UNAUTHORIZED"
+	CodeForbidden        Code = "FORBIDDEN"
+	CodeNotFound         Code = "NOT_FOUND"
+	CodeRouteNotFound    Code = "ROUTE_NOT_FOUND"
+	CodeMethodNotAllowed Code = "METHOD_NOT_ALLOWED"
+	CodeConflict         Code = "CONFLICT"
+	CodeTooManyRequests  Code = "TOO_MANY_REQUESTS"
+	CodeInternal         Code = "INTERNAL_ERROR"
+	CodeUnavailable      Code = "SERVICE_UNAVAILABLE"
+)
+
+// Resource codes
+const (
+	CodeCourseNotFound        Code = "COURSE_NOT_FOUND"
+	CodeProgramNotFound       Code = "PROGRAM_NOT_FOUND"
+	CodeMaterialNotFound      Code = "MATERIAL_NOT_FOUND"
+	CodeContentTopicNotFound  Code = "CONTENT_TOPIC_NOT_FOUND"
+	CodeVideoNotFound         Code = "VIDEO_NOT_FOUND"
+	CodeUserNotFound          Code = "USER_NOT_FOUND"
+	CodeEnrollmentNotFound    Code = "ENROLLMENT_NOT_FOUND"
+	CodeCourseNotInProgram    Code = "COURSE_NOT_IN_PROGRAM"
+	CodeMaterialNotInCourse   Code = "MATERIAL_NOT_IN_COURSE"
+	CodeCourseInProgram       Code = "COURSE_ALREADY_IN_PROGRAM"
+	CodeMaterialInCourse      Code = "MATERIAL_ALREADY_IN_COURSE"
+	CodeAlreadyEnrolled       Code = "ALREADY_ENROLLED"
+	CodeEnrollmentCancelled   Code = "ENROLLMENT_ALREADY_CANCELLED"
+	CodeNotEnrolled           Code = "NOT_ENROLLED"
+	CodeInvalidOrder          Code = "INVALID_ORDER"
+	CodeInvalidMaterialItem   Code = "INVALID_MATERIAL_ITEM"
+	CodeInvalidCacheNamespace Code = "INVALID_CACHE_NAMESPACE"
+)
+
+// Account and authentication codes
+const (
+	CodeMissingToken             Code = "MISSING_TOKEN"
+	CodeInvalidToken             Code = "INVALID_TOKEN"
+	CodeTokenRevoked             Code = "TOKEN_REVOKED"
+	CodeInvalidCredentials       Code = "INVALID_CREDENTIALS"
+	CodeInvalidRefreshToken      Code = "INVALID_REFRESH_TOKEN"
+	CodeRefreshTokenReused       Code = "REFRESH_TOKEN_REUSED"
+	CodeInvalidVerificationToken Code = "INVALID_VERIFICATION_TOKEN"
+	CodeInvalidResetToken        Code = "INVALID_RESET_TOKEN"
+	CodeEmailTaken               Code = "EMAIL_ALREADY_REGISTERED"
+	CodeEmailNotVerified         Code = "EMAIL_NOT_VERIFIED"
+	CodeAccountDeactivated       Code = "ACCOUNT_DEACTIVATED"
+	CodeInsufficientPermissions  Code = "INSUFFICIENT_PERMISSIONS"
+	CodeBootstrapDisabled        Code = "ADMIN_BOOTSTRAP_DISABLED"
+	CodeInvalidBootstrapToken    Code = "INVALID_BOOTSTRAP_TOKEN"
+	CodeAdminExists              Code = "ADMIN_ALREADY_EXISTS"
+	CodeLastAdmin                Code = "LAST_ACTIVE_ADMIN"
+	CodeSelfDeactivation         Code = "CANNOT_DEACTIVATE_SELF"
+)
+
+// FieldError describes one invalid field of the input
+type FieldError struct {
+	Field   string `json:"field"`           // JSON path of the field, e.g. title or content[0].title
+	Rule    string `json:"rule,omitempty"`  // The failed rule, e.g. required or max
+	Param   string `json:"param,omitempty"` // The rule's parameter, e.g. 255 for max=255
+	Message string `json:"message"`
+}
+
+// Error is an error the API answers with
+type Error struct {
+	Status  int
+	Code    Code
+	Message string
+	Details []FieldError
+	Err     error // The cause, logged but not sent
+}
+
+// New returns an error answered with status, code and message
+func New(status int, code Code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Wrap returns a copy of e caused by err
+func (e *Error) Wrap(err error) *Error {
+	wrapped := *e
+	wrapped.Err = err
+	return &wrapped
+}
+
+// WithDetails returns a copy of e describing the invalid fields
+func (e *Error) WithDetails(details ...FieldError) *Error {
+	detailed := *e
+	detailed.Details = append(append([]FieldError(nil), e.Details...), details...)
+	return &detailed
+}
+
+// BadRequest returns a 400 error
+func BadRequest(code Code, message string) *Error {
+	return New(http.StatusBadRequest, code, message)
+}
+
+// Unauthorized returns a 401 error
+func Unauthorized(code Code, message string) *Error {
+	return New(http.StatusUnauthorized, code, message)
+}
+
+// Forbidden returns a 403 error
+func Forbidden(code Code, message string) *Error {
+	return New(http.StatusForbidden, code, message)
+}
+
+// NotFound returns a 404 error
+func NotFound(code Code, message string) *Error {
+	return New(http.StatusNotFound, code, message)
+}
+
+// Conflict returns a 409 error
+func Conflict(code Code, message string) *Error {
+	return New(http.StatusConflict, code, message)
+}
+
+// Internal returns a 500 error caused by err. The message is sent to the client,
+// so it names the failed operation without revealing the cause.
+func Internal(message string, err error) *Error {
+	return &Error{Status: http.StatusInternalServerError, Code: CodeInternal, Message: message, Err: err}
+}
+
+// InvalidInput is answered when the request body cannot be parsed
+func InvalidInput(err error) *Error {
+	return BadRequest(CodeInvalidInput, "Invalid input").Wrap(err)
+}
+
+// From returns err as an *Error; errors of other types become an internal error
+func From(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	return Internal("Internal server error", err)
+}
+
+// FromStatus returns an error for a bare HTTP status, such as the 404 of a path
+// without a route
+func FromStatus(status int, message string) *Error {
+	code := CodeInternal
+	switch status {
+	case http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity:
+		code = CodeInvalidInput
+	case http.StatusUnauthorized:
+		code = CodeUnauthorized
+	case http.StatusForbidden:
+		code = CodeForbidden
+	case http.StatusNotFound:
+		code = CodeRouteNotFound
+	case http.StatusMethodNotAllowed:
+		code = CodeMethodNotAllowed
+	case http.StatusConflict:
+		code = CodeConflict
+	case http.StatusTooManyRequests:
+		code = CodeTooManyRequests
+	case http.StatusServiceUnavailable:
+		code = CodeUnavailable
+	}
+	if message == "" {
+		message = http.StatusText(status)
+	}
+	return New(status, code, message)
+}
@@ -0,0 +1,115 @@
+package apperrors
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Validation returns the VALIDATION_FAILED error listing the fields rejected by
+// the validator. Field names are the JSON names registered by validator.New.
+func Validation(err error) *Error {
+	return ValidationAt("", err)
+}
+
+// ValidationAt is Validation for a value nested in the input, with prefix, such
+// as content[2], prepended to the field names
+func ValidationAt(prefix string, err error) *Error {
+	var fieldErrors validator.ValidationErrors
+	if !errors.As(err, &fieldErrors) {
+		// Invalid arguments to the validator are programming errors
+		return Internal("Error validating input", err)
+	}
+
+	details := make([]FieldError, 0, len(fieldErrors))
+	for _, fe := range fieldErrors {
+		details = append(details, FieldError{
+			Field:   fieldPath(prefix, fe),
+			Rule:    fe.Tag(),
+			Param:   fe.Param(),
+			Message: describe(fe),
+		})
+	}
+	return BadRequest(CodeValidationFailed, "Validation failed").WithDetails(details...)
+}
+
+// fieldPath drops the struct name from the namespace, so CreateMaterialInput.content[0].title
+// becomes content[0].title
+func fieldPath(prefix string, fe validator.FieldError) string {
+	path := fe.Namespace()
+	if i := strings.IndexByte(path, '.'); i >= 0 {
+		path = path[i+1:]
+	}
+	if prefix != "" {
+		path = prefix + "." + path
+	}
+	return path
+}
+
+// describe explains a failed rule in words
+func describe(fe validator.FieldError) string {
+	param := fe.Param()
+	isString := fe.Kind() == reflect.String
+	isCollection := fe.Kind() == reflect.Slice || fe.Kind() == reflect.Map || fe.Kind() == reflect.Array
+
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "required_without":
+		return "is required unless " + snakeCase(param) + " is set"
+	case "email":
+		return "must be a valid email address"
+	case "oneof":
+		return "must be one of " + strings.Join(strings.Fields(param), ", ")
+	case "youtube_id":
+		return "must be an 11 character YouTube video ID"
+	case "video_duration":
+		return "must be a duration such as 12:30, 1:02:03 or 12m30s"
+	case "excluded_with":
+		return "must not be set together with " + snakeCase(param)
+	case "min", "gte":
+		switch {
+		case isString:
+			return fmt.Sprintf("must be at least %s characters long", param)
+		case isCollection:
+			return fmt.Sprintf("must contain at least %s items", param)
+		}
+		return "must be at least " + param
+	case "max", "lte":
+		switch {
+		case isString:
+			return fmt.Sprintf("must be at most %s characters long", param)
+		case isCollection:
+			return fmt.Sprintf("must contain at most %s items", param)
+		}
+		return "must be at most " + param
+	case "unique":
+		return "must not contain duplicates"
+	}
+	if param != "" {
+		return fmt.Sprintf("failed the %s=%s rule", fe.Tag(), param)
+	}
+	return fmt.Sprintf("failed the %s rule", fe.Tag())
+}
+
+// snakeCase turns the Go field names in rule parameters, such as ProgramID, into
+// the JSON names clients know, such as program_id
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			prevLower := i > 0 && unicode.IsLower(runes[i-1])
+			nextLower := i > 0 && i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1])
+			if prevLower || nextLower {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
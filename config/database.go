@@ -1,142 +1,130 @@
 package config
 
-// import (
-// 	"fmt"
-// 	"log"
-// 	"os"
-// 	"path/filepath"
-
-// 	"course-api/models"
-
-// 	"gorm.io/driver/postgres"
-// 	"gorm.io/gorm"
-
-// 	"github.com/joho/godotenv"
-// )
-
-// var DB *gorm.DB
-
-// // loadEnv ensures the .env file is loaded only from the root directory
-// func loadEnv() {
-// 	rootPath, err := os.Getwd() // Ambil direktori root proyek
-// 	if err != nil {
-// 		log.Fatal("Error getting root directory:", err)
-// 	}
-
-// 	envPath := filepath.Join(rootPath, ".env") // Pastikan ambil dari root folder
-
-// 	err = godotenv.Load(envPath) // Load hanya dari .env di root
-// 	if err != nil {
-// 		log.Fatal("Error loading .env file from root folder")
-// 	}
-// }
-
-// func ConnectDB() {
-// 	// Load environment variables from .env file
-// 	loadEnv()
-
-// 	log.Println("Attempting to connect to database...")
-
-// 	// Construct DSN using environment variables
-// 	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s",
-// 		os.Getenv("PGHOST"),
-// 		os.Getenv("PGUSER"),
-// 		os.Getenv("PGPASSWORD"),
-// 		os.Getenv("PGDATABASE"),
-// 		os.Getenv("PGPORT"),
-// 		os.Getenv("PGSSLMODE"),
-// 	)
-
-// 	// Log DSN parameters (without password)
-// 	log.Printf("Database parameters: host=%s user=%s dbname=%s port=%s sslmode=%s\n",
-// 		os.Getenv("PGHOST"),
-// 		os.Getenv("PGUSER"),
-// 		os.Getenv("PGDATABASE"),
-// 		os.Getenv("PGPORT"),
-// 		os.Getenv("PGSSLMODE"),
-// 	)
-
-// 	// Open database connection
-// 	var err error
-// 	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
-// 	if err != nil {
-// 		log.Fatal("Failed to connect to database: ", err)
-// 	}
-
-// 	log.Println("Database connection successfully opened")
-
-// 	// Run auto-migrations
-// 	log.Println("Attempting to run auto-migrations...")
-// 	err = DB.AutoMigrate(
-// 		&models.Course{},
-// 		&models.User{},
-// 		&models.Program{},
-// 		&models.Material{},
-// 		&models.ContentTopic{},
-// 		&models.VideoCourse{})
-// 	if err != nil {
-// 		log.Fatal("Failed to run auto-migrations: ", err)
-// 	}
-
-// 	log.Println("Auto-migrations completed successfully")
-// }
-
 import (
 	"fmt"
 	"log"
+	"log/slog"
+	"strings"
 
-	"course-api/models"
+	"course-api/utils/logging"
 
+	"github.com/glebarez/sqlite"
 	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+)
 
-	"github.com/joho/godotenv"
+// Supported values of DB_DRIVER
+const (
+	DriverMySQL    = "mysql" // MySQL and TiDB
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
 )
 
+// DefaultSQLitePath is the database file used when DB_DRIVER=sqlite and SQLITE_PATH is not set
+const DefaultSQLitePath = "course.db"
+
 // DB global variable
 var DB *gorm.DB
 
-func ConnectDB() {
-	// Load .env file
-	err := godotenv.Load(".env")
+// ConnectDB opens the database selected by the settings
+func ConnectDB(settings DatabaseSettings) {
+	dialector, err := Dialector(settings)
 	if err != nil {
-		log.Fatal("Error loading .env file")
+		log.Fatal("Invalid database configuration: ", err)
 	}
 
-	// Baca dari .env
-	envMap, err := godotenv.Read(".env")
+	slog.Info("Connecting to database", "driver", settings.Driver)
+	// TranslateError reports constraint violations as gorm.ErrDuplicatedKey on every driver
+	DB, err = gorm.Open(dialector, &gorm.Config{Logger: logging.NewGormLogger(settings.SlowQuery), TranslateError: true})
 	if err != nil {
-		log.Fatal("Failed to read .env file: ", err)
+		log.Fatal("Failed to connect to database: ", err)
 	}
 
-	// DSN format untuk MySQL / TiDB
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local&tls=true",
-		envMap["PGUSER"],
-		envMap["PGPASSWORD"],
-		envMap["PGHOST"],
-		envMap["PGPORT"],
-		envMap["PGDATABASE"],
-	)
+	slog.Info("Database connection successfully opened")
+}
 
-	// Koneksi pakai driver MySQL
-	DB, err = gorm.Open(mysql.Open(dsn), &gorm.Config{})
+// CloseDB closes the database connection pool
+func CloseDB() {
+	if DB == nil {
+		return
+	}
+	sqlDB, err := DB.DB()
 	if err != nil {
-		log.Fatal("Failed to connect to database: ", err)
+		slog.Error("Error getting database connection", "error", err)
+		return
+	}
+	if err := sqlDB.Close(); err != nil {
+		slog.Error("Error closing database connection", "error", err)
+	}
+}
+
+// Dialector builds the GORM dialector for the configured driver. DSN is used as is
+// when set, otherwise it is built from the driver specific settings.
+func Dialector(settings DatabaseSettings) (gorm.Dialector, error) {
+	dsn := settings.DSN
+
+	switch settings.Driver {
+	case DriverMySQL:
+		if dsn == "" {
+			dsn = mysqlDSN(settings)
+		}
+		return mysql.Open(dsn), nil
+	case DriverPostgres:
+		if dsn == "" {
+			dsn = postgresDSN(settings)
+		}
+		return postgres.Open(dsn), nil
+	case DriverSQLite:
+		if dsn == "" {
+			dsn = sqliteDSN(settings)
+		}
+		return sqlite.Open(dsn), nil
+	default:
+		return nil, fmt.Errorf("unknown DB_DRIVER %q, expected mysql, postgres or sqlite", settings.Driver)
 	}
+}
 
-	log.Println("Database connection successfully opened")
+// mysqlDSN builds a MySQL / TiDB DSN; TLS stays on unless DB_TLS=false
+func mysqlDSN(settings DatabaseSettings) string {
+	port := settings.Port
+	if port == "" {
+		port = "3306"
+	}
+	return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local&tls=%s",
+		settings.User,
+		settings.Password,
+		settings.Host,
+		port,
+		settings.Name,
+		settings.TLS,
+	)
+}
 
-	// Auto migrate
-	err = DB.AutoMigrate(
-		&models.Course{},
-		&models.User{},
-		&models.Program{},
-		&models.Material{},
-		&models.ContentTopic{},
-		&models.VideoCourse{})
-	if err != nil {
-		log.Fatal("Failed to run auto-migrations: ", err)
+// postgresDSN builds a Postgres DSN from the PG* settings
+func postgresDSN(settings DatabaseSettings) string {
+	port := settings.Port
+	if port == "" {
+		port = "5432"
 	}
+	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s",
+		settings.Host,
+		settings.User,
+		settings.Password,
+		settings.Name,
+		port,
+	)
+	if settings.SSLMode != "" {
+		dsn += " sslmode=" + settings.SSLMode
+	}
+	return dsn
+}
 
-	log.Println("Auto-migrations completed successfully")
+// sqliteDSN opens the SQLite file with foreign keys enforced, like the other databases
+func sqliteDSN(settings DatabaseSettings) string {
+	separator := "?"
+	if strings.Contains(settings.SQLitePath, "?") {
+		separator = "&"
+	}
+	return settings.SQLitePath + separator + "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
 }
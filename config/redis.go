@@ -2,8 +2,7 @@ package config
 
 import (
 	"context"
-	"log"
-	"os"
+	"log/slog"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -11,18 +10,17 @@ import (
 
 var RedisClient *redis.Client
 
-func ConnectRedis() {
-	// Get Redis URL from environment variable
-	redisURL := os.Getenv("REDIS_URL")
+func ConnectRedis(settings RedisSettings) {
+	redisURL := settings.URL
 	if redisURL == "" {
-		log.Println("Warning: REDIS_URL environment variable is not set. Caching will be disabled.")
+		slog.Warn("REDIS_URL is not set, caching is disabled")
 		return
 	}
 
 	// Parse Redis options from URL
 	opt, err := redis.ParseURL(redisURL)
 	if err != nil {
-		log.Printf("Warning: Failed to parse Redis URL: %v. Caching will be disabled.", err)
+		slog.Warn("Failed to parse REDIS_URL, caching is disabled", "error", err)
 		return
 	}
 
@@ -37,15 +35,15 @@ func ConnectRedis() {
 		cancel()
 
 		if err == nil {
-			log.Println("Successfully connected to Redis")
+			slog.Info("Successfully connected to Redis")
 			return
 		}
 
 		if i < maxRetries-1 {
-			log.Printf("Failed to connect to Redis (attempt %d/%d): %v. Retrying...", i+1, maxRetries, err)
+			slog.Warn("Failed to connect to Redis, retrying", "attempt", i+1, "max_attempts", maxRetries, "error", err)
 			time.Sleep(2 * time.Second)
 		} else {
-			log.Printf("Warning: Failed to connect to Redis after %d attempts: %v. Caching will be disabled.", maxRetries, err)
+			slog.Warn("Failed to connect to Redis, caching is disabled", "attempts", maxRetries, "error", err)
 			CloseRedis()
 			RedisClient = nil
 		}
@@ -56,7 +54,7 @@ func ConnectRedis() {
 func CloseRedis() {
 	if RedisClient != nil {
 		if err := RedisClient.Close(); err != nil {
-			log.Printf("Error closing Redis connection: %v", err)
+			slog.Error("Error closing Redis connection", "error", err)
 		}
 		RedisClient = nil
 	}
@@ -0,0 +1,306 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultConfigFile is read when CONFIG_FILE is not set and the file exists
+const DefaultConfigFile = "config.yaml"
+
+// Settings is the application configuration. It is loaded once at startup from
+// defaults, an optional YAML file, an optional .env file and environment variables,
+// later sources overriding earlier ones.
+type Settings struct {
+	Env             string           `yaml:"env" env:"APP_ENV" validate:"oneof=development production test"`
+	Port            int              `yaml:"port" env:"PORT" validate:"min=1,max=65535"`
+	AppURL          string           `yaml:"app_url" env:"APP_URL" validate:"omitempty,url"`          // Base URL of links sent by email
+	ShutdownTimeout time.Duration    `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" validate:"gt=0"` // Time in-flight requests get to finish on SIGTERM
+	Log             LogSettings      `yaml:"log"`
+	Tracing         TracingSettings  `yaml:"tracing"`
+	CORS            CORSSettings     `yaml:"cors"`
+	Database        DatabaseSettings `yaml:"database"`
+	Redis           RedisSettings    `yaml:"redis"`
+	Cache           CacheSettings    `yaml:"cache"`
+	Auth            AuthSettings     `yaml:"auth"`
+	Mail            MailSettings     `yaml:"mail"`
+}
+
+type LogSettings struct {
+	Level  string `yaml:"level" env:"LOG_LEVEL" validate:"oneof=debug info warn error"`
+	Format string `yaml:"format" env:"LOG_FORMAT" validate:"oneof=json text"`
+}
+
+type TracingSettings struct {
+	Exporter     string  `yaml:"exporter" env:"TRACING_EXPORTER" validate:"oneof=none stdout otlp"`
+	OTLPEndpoint string  `yaml:"otlp_endpoint" env:"TRACING_OTLP_ENDPOINT" validate:"omitempty,url"` // The OTEL_EXPORTER_OTLP_* variables apply when empty
+	ServiceName  string  `yaml:"service_name" env:"TRACING_SERVICE_NAME" validate:"required"`
+	SampleRatio  float64 `yaml:"sample_ratio" env:"TRACING_SAMPLE_RATIO" validate:"gte=0,lte=1"` // Share of new traces recorded; callers' sampling decisions are kept
+}
+
+type CORSSettings struct {
+	AllowOrigins []string `yaml:"allow_origins" env:"CORS_ALLOW_ORIGINS" validate:"min=1"` // Comma separated in the environment
+}
+
+type DatabaseSettings struct {
+	Driver         string        `yaml:"driver" env:"DB_DRIVER" validate:"oneof=mysql postgres sqlite"`
+	DSN            string        `yaml:"dsn" env:"DB_DSN"` // Used as is instead of the fields below when set
+	Host           string        `yaml:"host" env:"PGHOST"`
+	Port           string        `yaml:"port" env:"PGPORT"`
+	User           string        `yaml:"user" env:"PGUSER"`
+	Password       string        `yaml:"password" env:"PGPASSWORD"`
+	Name           string        `yaml:"name" env:"PGDATABASE"`
+	TLS            string        `yaml:"tls" env:"DB_TLS"`        // MySQL only
+	SSLMode        string        `yaml:"sslmode" env:"PGSSLMODE"` // Postgres only
+	SQLitePath     string        `yaml:"sqlite_path" env:"SQLITE_PATH"`
+	MigrateOnStart bool          `yaml:"migrate_on_start" env:"MIGRATE_ON_START"`
+	SlowQuery      time.Duration `yaml:"slow_query" env:"DB_SLOW_QUERY" validate:"gte=0"` // Queries taking longer are logged as warnings; 0 disables it
+}
+
+type RedisSettings struct {
+	URL string `yaml:"url" env:"REDIS_URL"` // Caching is disabled when empty
+}
+
+// CacheSettings holds the cache key prefix, the default TTL and optional
+// per-resource TTLs (0 uses the default), how expiring entries are refreshed
+// and the size of the in-process tier
+type CacheSettings struct {
+	Prefix    string        `yaml:"prefix" env:"CACHE_PREFIX" validate:"required"` // Every cache key starts with it
+	TTL       time.Duration `yaml:"ttl" env:"CACHE_TTL" validate:"gt=0"`
+	Courses   time.Duration `yaml:"courses_ttl" env:"CACHE_TTL_COURSES" validate:"gte=0"`
+	Programs  time.Duration `yaml:"programs_ttl" env:"CACHE_TTL_PROGRAMS" validate:"gte=0"`
+	Materials time.Duration `yaml:"materials_ttl" env:"CACHE_TTL_MATERIALS" validate:"gte=0"`
+	Content   time.Duration `yaml:"content_ttl" env:"CACHE_TTL_CONTENT" validate:"gte=0"`
+	Videos    time.Duration `yaml:"videos_ttl" env:"CACHE_TTL_VIDEOS" validate:"gte=0"`
+	Curricula time.Duration `yaml:"curricula_ttl" env:"CACHE_TTL_CURRICULA" validate:"gte=0"`
+
+	StaleFor    time.Duration `yaml:"stale_for" env:"CACHE_STALE_FOR" validate:"gte=0"` // 0 disables serving expired values
+	Jitter      float64       `yaml:"ttl_jitter" env:"CACHE_TTL_JITTER" validate:"gte=0,lte=1"`
+	Lock        bool          `yaml:"lock" env:"CACHE_LOCK"`
+	LockTimeout time.Duration `yaml:"lock_timeout" env:"CACHE_LOCK_TIMEOUT" validate:"gt=0"`
+
+	LocalSize int           `yaml:"local_size_mb" env:"CACHE_LOCAL_SIZE_MB" validate:"gte=0"` // In-process tier size in MiB; 0 disables it
+	LocalTTL  time.Duration `yaml:"local_ttl" env:"CACHE_LOCAL_TTL" validate:"gt=0"`          // Longest a value is kept in process
+}
+
+// TTLOf returns a resource TTL, falling back to the default TTL when it is 0
+func (s CacheSettings) TTLOf(ttl time.Duration) time.Duration {
+	if ttl > 0 {
+		return ttl
+	}
+	return s.TTL
+}
+
+type AuthSettings struct {
+	JWTSecret                string        `yaml:"jwt_secret" env:"JWT_SECRET" validate:"required"`
+	AccessTokenTTL           time.Duration `yaml:"access_token_ttl" env:"ACCESS_TOKEN_TTL" validate:"gt=0"`
+	RefreshTokenTTL          time.Duration `yaml:"refresh_token_ttl" env:"REFRESH_TOKEN_TTL" validate:"gt=0"`
+	RequireEmailVerification bool          `yaml:"require_email_verification" env:"REQUIRE_EMAIL_VERIFICATION"`
+	AdminBootstrapToken      string        `yaml:"admin_bootstrap_token" env:"ADMIN_BOOTSTRAP_TOKEN"`
+}
+
+type MailSettings struct {
+	Driver       string `yaml:"driver" env:"MAIL_DRIVER" validate:"oneof=log file smtp"`
+	From         string `yaml:"from" env:"MAIL_FROM"`
+	Dir          string `yaml:"dir" env:"MAIL_DIR"` // Used by the file driver
+	SMTPHost     string `yaml:"smtp_host" env:"SMTP_HOST"`
+	SMTPPort     string `yaml:"smtp_port" env:"SMTP_PORT"`
+	SMTPUsername string `yaml:"smtp_username" env:"SMTP_USERNAME"`
+	SMTPPassword string `yaml:"smtp_password" env:"SMTP_PASSWORD"`
+}
+
+// DefaultSettings returns the settings used for anything not configured
+func DefaultSettings() *Settings {
+	return &Settings{
+		Env:             "development",
+		Port:            3000,
+		ShutdownTimeout: 10 * time.Second,
+		Log:             LogSettings{Level: "info", Format: "json"},
+		Tracing:         TracingSettings{Exporter: "none", ServiceName: "course-api", SampleRatio: 1},
+		CORS:            CORSSettings{AllowOrigins: []string{"*"}},
+		Database: DatabaseSettings{
+			Driver:         DriverMySQL,
+			TLS:            "true",
+			SQLitePath:     DefaultSQLitePath,
+			MigrateOnStart: false,
+			SlowQuery:      200 * time.Millisecond,
+		},
+		Cache: CacheSettings{
+			Prefix:      "course-api:cache:",
+			TTL:         15 * time.Minute,
+			StaleFor:    time.Minute,
+			Jitter:      0.1,
+			LockTimeout: 5 * time.Second,
+			LocalSize:   64,
+			LocalTTL:    30 * time.Second,
+		},
+		Auth: AuthSettings{
+			AccessTokenTTL:  15 * time.Minute,
+			RefreshTokenTTL: 30 * 24 * time.Hour,
+		},
+		Mail: MailSettings{Driver: "file", Dir: "mail"},
+	}
+}
+
+// Load reads and validates the settings. The YAML file is CONFIG_FILE, or
+// config.yaml when present; a missing .env file is not an error.
+func Load() (*Settings, error) {
+	if err := godotenv.Load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("reading .env: %w", err)
+	}
+
+	s := DefaultSettings()
+
+	path := os.Getenv("CONFIG_FILE")
+	if path == "" {
+		if _, err := os.Stat(DefaultConfigFile); err == nil {
+			path = DefaultConfigFile
+		}
+	}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+		if err := yaml.Unmarshal(data, s); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", path, err)
+		}
+	}
+
+	if err := applyEnv(reflect.ValueOf(s).Elem()); err != nil {
+		return nil, err
+	}
+
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// settingsValidator names fields after their environment variable in error messages
+var settingsValidator = func() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		if name := f.Tag.Get("env"); name != "" {
+			return name
+		}
+		return f.Name
+	})
+	return v
+}()
+
+// Validate reports every invalid setting at once
+func (s *Settings) Validate() error {
+	var problems []string
+
+	if err := settingsValidator.Struct(s); err != nil {
+		var fieldErrors validator.ValidationErrors
+		if !errors.As(err, &fieldErrors) {
+			return err
+		}
+		for _, fe := range fieldErrors {
+			problems = append(problems, describeFieldError(fe))
+		}
+	}
+
+	db := s.Database
+	if db.Driver != DriverSQLite && db.DSN == "" && (db.Host == "" || db.Name == "") {
+		problems = append(problems, "PGHOST and PGDATABASE (or DB_DSN) are required for DB_DRIVER="+db.Driver)
+	}
+	if s.Mail.Driver == "smtp" && (s.Mail.SMTPHost == "" || s.Mail.SMTPPort == "" || s.Mail.From == "") {
+		problems = append(problems, "SMTP_HOST, SMTP_PORT and MAIL_FROM are required for MAIL_DRIVER=smtp")
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid configuration: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func describeFieldError(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return fe.Field() + " is required"
+	case "oneof":
+		return fmt.Sprintf("%s must be one of %s", fe.Field(), strings.ReplaceAll(fe.Param(), " ", ", "))
+	case "gt":
+		return fe.Field() + " must be positive"
+	case "gte":
+		return fe.Field() + " must not be negative"
+	case "url":
+		return fe.Field() + " must be a URL"
+	default:
+		return fmt.Sprintf("%s is invalid (%s %s)", fe.Field(), fe.Tag(), fe.Param())
+	}
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// applyEnv overrides fields tagged with env from non-empty environment variables
+func applyEnv(v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field, value := t.Field(i), v.Field(i)
+		if field.Type.Kind() == reflect.Struct && field.Type != durationType {
+			if err := applyEnv(value); err != nil {
+				return err
+			}
+			continue
+		}
+
+		name := field.Tag.Get("env")
+		raw := strings.TrimSpace(os.Getenv(name))
+		if name == "" || raw == "" {
+			continue
+		}
+
+		switch {
+		case field.Type == durationType:
+			d, err := time.ParseDuration(raw)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q: must be a duration such as 15m", name, raw)
+			}
+			value.SetInt(int64(d))
+		case field.Type.Kind() == reflect.String:
+			value.SetString(raw)
+		case field.Type.Kind() == reflect.Int:
+			n, err := strconv.Atoi(raw)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q: must be a number", name, raw)
+			}
+			value.SetInt(int64(n))
+		case field.Type.Kind() == reflect.Float64:
+			f, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q: must be a number", name, raw)
+			}
+			value.SetFloat(f)
+		case field.Type.Kind() == reflect.Bool:
+			b, err := strconv.ParseBool(raw)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q: must be true or false", name, raw)
+			}
+			value.SetBool(b)
+		case field.Type.Kind() == reflect.Slice && field.Type.Elem().Kind() == reflect.String:
+			var items []string
+			for _, item := range strings.Split(raw, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			value.Set(reflect.ValueOf(items))
+		default:
+			return fmt.Errorf("unsupported type for %s", name)
+		}
+	}
+	return nil
+}
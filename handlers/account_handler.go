@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"context"
+	"course-api/apperrors"
+	"course-api/middleware"
+	"course-api/models"
+	"course-api/repository"
+	"course-api/responses"
+	"course-api/utils/mailer"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	emailVerificationTTL = 24 * time.Hour
+	passwordResetTTL     = time.Hour
+)
+
+// VerifyEmail godoc
+// @Summary Verify an email address
+// @Description Confirm the email address of an account with the token sent on signup
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param input body models.VerifyEmailInput true "Verification token"
+// @Success 200 {object} responses.Response
+// @Failure 400 {object} responses.Response
+// @Failure 500 {object} responses.Response
+// @Router /api/v1/auth/verify-email [post]
+func (h *AuthHandler) VerifyEmail(c *fiber.Ctx) error {
+	input := new(models.VerifyEmailInput)
+
+	if err := c.BodyParser(input); err != nil {
+		return responses.SendError(c, apperrors.InvalidInput(err))
+	}
+
+	if err := h.validate.Struct(input); err != nil {
+		return responses.SendError(c, apperrors.Validation(err))
+	}
+
+	err := h.userTokens.VerifyEmail(c.UserContext(), middleware.HashToken(input.Token))
+	if errors.Is(err, repository.ErrInvalidToken) {
+		return responses.SendError(c, apperrors.BadRequest(apperrors.CodeInvalidVerificationToken, "Invalid or expired verification token"))
+	}
+	if err != nil {
+		return responses.SendError(c, apperrors.Internal("Error verifying email", err))
+	}
+
+	return responses.SendSuccess(c, "Email verified successfully", nil)
+}
+
+// ResendVerification godoc
+// @Summary Resend the verification email
+// @Description Send a new verification email. Always succeeds so it cannot be used to discover accounts.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param input body models.EmailInput true "Account email"
+// @Success 200 {object} responses.Response
+// @Failure 400 {object} responses.Response
+// @Router /api/v1/auth/resend-verification [post]
+func (h *AuthHandler) ResendVerification(c *fiber.Ctx) error {
+	input := new(models.EmailInput)
+
+	if err := c.BodyParser(input); err != nil {
+		return responses.SendError(c, apperrors.InvalidInput(err))
+	}
+
+	if err := h.validate.Struct(input); err != nil {
+		return responses.SendError(c, apperrors.Validation(err))
+	}
+
+	if user, err := h.users.FindByEmail(c.UserContext(), input.Email); err == nil && !user.EmailVerified {
+		h.sendVerificationEmail(c.UserContext(), user)
+	}
+
+	return responses.SendSuccess(c, "If the account exists and is not verified, a verification email has been sent", nil)
+}
+
+// ForgotPassword godoc
+// @Summary Request a password reset
+// @Description Email a single-use password reset token. Always succeeds so it cannot be used to discover accounts.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param input body models.EmailInput true "Account email"
+// @Success 200 {object} responses.Response
+// @Failure 400 {object} responses.Response
+// @Router /api/v1/auth/forgot-password [post]
+func (h *AuthHandler) ForgotPassword(c *fiber.Ctx) error {
+	input := new(models.EmailInput)
+
+	if err := c.BodyParser(input); err != nil {
+		return responses.SendError(c, apperrors.InvalidInput(err))
+	}
+
+	if err := h.validate.Struct(input); err != nil {
+		return responses.SendError(c, apperrors.Validation(err))
+	}
+
+	if user, err := h.users.FindByEmail(c.UserContext(), input.Email); err == nil && user.IsActive {
+		token, err := h.issueUserToken(c.UserContext(), user.ID, models.TokenPasswordReset, passwordResetTTL)
+		if err != nil {
+			slog.ErrorContext(c.UserContext(), "Error creating password reset token", "account_id", user.ID, "error", err)
+		} else {
+			h.sendMail(c.UserContext(), mailer.Message{
+				To:      user.Email,
+				Subject: "Reset your password",
+				Body: fmt.Sprintf("Hi %s,\n\nUse this code to reset your password. It expires in %s.\n\n%s\n\nIf you did not ask for a reset, you can ignore this email.",
+					user.FullName, passwordResetTTL, h.tokenLink("reset-password", token)),
+			})
+		}
+	}
+
+	return responses.SendSuccess(c, "If the account exists, a password reset email has been sent", nil)
+}
+
+// ResetPassword godoc
+// @Summary Reset a password
+// @Description Set a new password with a password reset token and sign out every session
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param input body models.ResetPasswordInput true "Reset token and new password"
+// @Success 200 {object} responses.Response
+// @Failure 400 {object} responses.Response
+// @Failure 500 {object} responses.Response
+// @Router /api/v1/auth/reset-password [post]
+func (h *AuthHandler) ResetPassword(c *fiber.Ctx) error {
+	input := new(models.ResetPasswordInput)
+
+	if err := c.BodyParser(input); err != nil {
+		return responses.SendError(c, apperrors.InvalidInput(err))
+	}
+
+	if err := h.validate.Struct(input); err != nil {
+		return responses.SendError(c, apperrors.Validation(err))
+	}
+
+	user := models.User{Password: input.Password}
+	if err := user.HashPassword(); err != nil {
+		return responses.SendError(c, apperrors.Internal("Error resetting password", err))
+	}
+
+	userID, err := h.userTokens.ResetPassword(c.UserContext(), middleware.HashToken(input.Token), user.Password)
+	if errors.Is(err, repository.ErrInvalidToken) {
+		return responses.SendError(c, apperrors.BadRequest(apperrors.CodeInvalidResetToken, "Invalid or expired reset token"))
+	}
+	if err != nil {
+		return responses.SendError(c, apperrors.Internal("Error resetting password", err))
+	}
+
+	if err := h.tokens.RevokeUserTokens(c.UserContext(), userID); err != nil {
+		slog.ErrorContext(c.UserContext(), "Error revoking sessions", "account_id", userID, "error", err)
+	}
+
+	return responses.SendSuccess(c, "Password reset successfully", nil)
+}
+
+// sendVerificationEmail issues a verification token and emails it; failures are logged
+// so signup does not fail because mail is unavailable
+func (h *AuthHandler) sendVerificationEmail(ctx context.Context, user models.User) {
+	token, err := h.issueUserToken(ctx, user.ID, models.TokenEmailVerification, emailVerificationTTL)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error creating verification token", "account_id", user.ID, "error", err)
+		return
+	}
+
+	h.sendMail(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nUse this code to verify your email address. It expires in %s.\n\n%s",
+			user.FullName, emailVerificationTTL, h.tokenLink("verify-email", token)),
+	})
+}
+
+func (h *AuthHandler) sendMail(ctx context.Context, msg mailer.Message) {
+	if err := h.mail.Send(ctx, msg); err != nil {
+		slog.ErrorContext(ctx, "Error sending email", "to", msg.To, "subject", msg.Subject, "error", err)
+	}
+}
+
+// tokenLink builds a link from APP_URL, or returns the bare token when no app URL is configured
+func (h *AuthHandler) tokenLink(path, token string) string {
+	if h.appURL == "" {
+		return token
+	}
+	return fmt.Sprintf("%s/%s?token=%s", h.appURL, path, url.QueryEscape(token))
+}
+
+// issueUserToken stores the hash of a new single-use token and returns the raw token
+func (h *AuthHandler) issueUserToken(ctx context.Context, userID uint, purpose models.UserTokenPurpose, ttl time.Duration) (string, error) {
+	token, hash, err := middleware.NewOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	if err := h.userTokens.Create(ctx, &models.UserToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(ttl),
+	}); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
@@ -1,17 +1,48 @@
 package handlers
 
 import (
+	"course-api/apperrors"
 	"course-api/config"
 	"course-api/middleware"
 	"course-api/models"
+	"course-api/repository"
 	"course-api/responses"
+	"course-api/utils/mailer"
+	"course-api/validator"
+	"crypto/subtle"
+	"errors"
 
 	"github.com/gofiber/fiber/v2"
 )
 
+// AuthHandler serves sign up, sign in, session and account recovery endpoints
+type AuthHandler struct {
+	users      repository.UserRepository
+	userTokens repository.UserTokenRepository
+	tokens     *middleware.TokenService
+	mail       mailer.Mailer
+	validate   *validator.Validator
+	auth       config.AuthSettings
+	appURL     string
+}
+
+// NewAuthHandler returns an AuthHandler. appURL is the frontend base URL used in
+// emailed links; when empty the emails carry the bare token.
+func NewAuthHandler(users repository.UserRepository, userTokens repository.UserTokenRepository, tokens *middleware.TokenService, mail mailer.Mailer, validate *validator.Validator, auth config.AuthSettings, appURL string) *AuthHandler {
+	return &AuthHandler{
+		users:      users,
+		userTokens: userTokens,
+		tokens:     tokens,
+		mail:       mail,
+		validate:   validate,
+		auth:       auth,
+		appURL:     appURL,
+	}
+}
+
 // SignUp godoc
 // @Summary Register a new user
-// @Description Create a new user account with the provided details
+// @Description Create a new user account with the provided details. When email verification is required no tokens are returned until the email is verified.
 // @Tags auth
 // @Accept json
 // @Produce json
@@ -20,50 +51,145 @@ import (
 // @Failure 400 {object} responses.Response
 // @Failure 500 {object} responses.Response
 // @Router /api/v1/auth/signup [post]
-func SignUp(c *fiber.Ctx) error {
+func (h *AuthHandler) SignUp(c *fiber.Ctx) error {
 	input := new(models.SignupInput)
 
 	if err := c.BodyParser(input); err != nil {
-		return responses.SendError(c, fiber.StatusBadRequest, "Invalid input")
+		return responses.SendError(c, apperrors.InvalidInput(err))
 	}
 
-	if err := validate.Struct(input); err != nil {
-		return responses.SendError(c, fiber.StatusBadRequest, err.Error())
+	if err := h.validate.Struct(input); err != nil {
+		return responses.SendError(c, apperrors.Validation(err))
 	}
 
 	// Check if user already exists
-	var existingUser models.User
-	if err := config.DB.Where("email = ?", input.Email).First(&existingUser).Error; err == nil {
-		return responses.SendError(c, fiber.StatusBadRequest, "Email already registered")
+	if _, err := h.users.FindByEmail(c.UserContext(), input.Email); err == nil {
+		return responses.SendError(c, errEmailTaken)
 	}
 
 	user := models.User{
 		Email:    input.Email,
 		Password: input.Password,
 		FullName: input.FullName,
-		Role:     input.Role,
+		Role:     models.RoleStudent,
+		IsActive: true,
 	}
 
 	if err := user.HashPassword(); err != nil {
-		return responses.SendError(c, fiber.StatusInternalServerError, "Error creating user")
+		return responses.SendError(c, apperrors.Internal("Error creating user", err))
+	}
+
+	// A concurrent sign up may have taken the email since the lookup above
+	if err := h.users.Create(c.UserContext(), &user); errors.Is(err, repository.ErrDuplicate) {
+		return responses.SendError(c, errEmailTaken)
+	} else if err != nil {
+		return responses.SendError(c, apperrors.Internal("Error creating user", err))
+	}
+
+	h.sendVerificationEmail(c.UserContext(), user)
+
+	profile := fiber.Map{
+		"id":             user.ID,
+		"email":          user.Email,
+		"full_name":      user.FullName,
+		"role":           user.Role,
+		"email_verified": user.EmailVerified,
 	}
 
-	if err := config.DB.Create(&user).Error; err != nil {
-		return responses.SendError(c, fiber.StatusInternalServerError, "Error creating user")
+	// Sign in would refuse the unverified account, so sign up does not sign it in either
+	if h.auth.RequireEmailVerification {
+		return responses.SendSuccess(c, "User created successfully, please verify your email before signing in", fiber.Map{
+			"user": profile,
+		})
 	}
 
-	token, err := middleware.GenerateToken(user.ID, user.Role)
+	tokens, err := h.tokens.IssueTokens(c.UserContext(), user)
 	if err != nil {
-		return responses.SendError(c, fiber.StatusInternalServerError, "Error generating token")
+		return responses.SendError(c, apperrors.Internal("Error generating token", err))
 	}
 
 	return responses.SendSuccess(c, "User created successfully", fiber.Map{
-		"token": token,
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
+		"user":          profile,
+	})
+}
+
+// BootstrapAdmin godoc
+// @Summary Create the first admin
+// @Description Create the very first admin account. Only works while no admin exists and requires the ADMIN_BOOTSTRAP_TOKEN configured on the server.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param input body models.BootstrapAdminInput true "Bootstrap token and admin details"
+// @Success 200 {object} responses.Response{data=map[string]interface{}}
+// @Failure 400 {object} responses.Response
+// @Failure 403 {object} responses.Response
+// @Failure 500 {object} responses.Response
+// @Router /api/v1/auth/bootstrap-admin [post]
+func (h *AuthHandler) BootstrapAdmin(c *fiber.Ctx) error {
+	bootstrapToken := h.auth.AdminBootstrapToken
+	if bootstrapToken == "" {
+		return responses.SendError(c, apperrors.Forbidden(apperrors.CodeBootstrapDisabled, "Admin bootstrap is disabled"))
+	}
+
+	input := new(models.BootstrapAdminInput)
+
+	if err := c.BodyParser(input); err != nil {
+		return responses.SendError(c, apperrors.InvalidInput(err))
+	}
+
+	if err := h.validate.Struct(input); err != nil {
+		return responses.SendError(c, apperrors.Validation(err))
+	}
+
+	if subtle.ConstantTimeCompare([]byte(input.Token), []byte(bootstrapToken)) != 1 {
+		return responses.SendError(c, apperrors.Forbidden(apperrors.CodeInvalidBootstrapToken, "Invalid bootstrap token"))
+	}
+
+	if _, err := h.users.FindByEmail(c.UserContext(), input.Email); err == nil {
+		return responses.SendError(c, errEmailTaken)
+	}
+
+	user := models.User{
+		Email:         input.Email,
+		Password:      input.Password,
+		FullName:      input.FullName,
+		Role:          models.RoleAdmin,
+		IsActive:      true,
+		EmailVerified: true,
+	}
+
+	if err := user.HashPassword(); err != nil {
+		return responses.SendError(c, apperrors.Internal("Error creating user", err))
+	}
+
+	err := h.users.CreateFirstAdmin(c.UserContext(), &user)
+	switch {
+	case errors.Is(err, repository.ErrAdminExists):
+		return responses.SendError(c, apperrors.Forbidden(apperrors.CodeAdminExists, "An admin already exists"))
+	case errors.Is(err, repository.ErrDuplicate):
+		return responses.SendError(c, errEmailTaken)
+	case err != nil:
+		return responses.SendError(c, apperrors.Internal("Error creating user", err))
+	}
+
+	tokens, err := h.tokens.IssueTokens(c.UserContext(), user)
+	if err != nil {
+		return responses.SendError(c, apperrors.Internal("Error generating token", err))
+	}
+
+	return responses.SendSuccess(c, "Admin created successfully", fiber.Map{
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
 		"user": fiber.Map{
-			"id":        user.ID,
-			"email":     user.Email,
-			"full_name": user.FullName,
-			"role":      user.Role,
+			"id":             user.ID,
+			"email":          user.Email,
+			"full_name":      user.FullName,
+			"role":           user.Role,
+			"email_verified": user.EmailVerified,
 		},
 	})
 }
@@ -80,38 +206,121 @@ func SignUp(c *fiber.Ctx) error {
 // @Failure 401 {object} responses.Response
 // @Failure 500 {object} responses.Response
 // @Router /api/v1/auth/signin [post]
-func SignIn(c *fiber.Ctx) error {
+func (h *AuthHandler) SignIn(c *fiber.Ctx) error {
 	input := new(models.LoginInput)
 
 	if err := c.BodyParser(input); err != nil {
-		return responses.SendError(c, fiber.StatusBadRequest, "Invalid input")
+		return responses.SendError(c, apperrors.InvalidInput(err))
 	}
 
-	if err := validate.Struct(input); err != nil {
-		return responses.SendError(c, fiber.StatusBadRequest, err.Error())
+	if err := h.validate.Struct(input); err != nil {
+		return responses.SendError(c, apperrors.Validation(err))
 	}
 
-	var user models.User
-	if err := config.DB.Where("email = ?", input.Email).First(&user).Error; err != nil {
-		return responses.SendError(c, fiber.StatusUnauthorized, "Invalid credentials")
+	user, err := h.users.FindByEmail(c.UserContext(), input.Email)
+	if err != nil {
+		return responses.SendError(c, lookupError(err, errInvalidCredentials))
 	}
 
 	if err := user.CheckPassword(input.Password); err != nil {
-		return responses.SendError(c, fiber.StatusUnauthorized, "Invalid credentials")
+		return responses.SendError(c, errInvalidCredentials)
+	}
+
+	if !user.IsActive {
+		return responses.SendError(c, apperrors.Forbidden(apperrors.CodeAccountDeactivated, "Account is deactivated"))
 	}
 
-	token, err := middleware.GenerateToken(user.ID, user.Role)
+	if h.auth.RequireEmailVerification && !user.EmailVerified {
+		return responses.SendError(c, apperrors.Forbidden(apperrors.CodeEmailNotVerified, "Email address is not verified"))
+	}
+
+	tokens, err := h.tokens.IssueTokens(c.UserContext(), user)
 	if err != nil {
-		return responses.SendError(c, fiber.StatusInternalServerError, "Error generating token")
+		return responses.SendError(c, apperrors.Internal("Error generating token", err))
 	}
 
 	return responses.SendSuccess(c, "Login successful", fiber.Map{
-		"token": token,
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
 		"user": fiber.Map{
-			"id":        user.ID,
-			"email":     user.Email,
-			"full_name": user.FullName,
-			"role":      user.Role,
+			"id":             user.ID,
+			"email":          user.Email,
+			"full_name":      user.FullName,
+			"role":           user.Role,
+			"email_verified": user.EmailVerified,
 		},
 	})
 }
+
+// RefreshToken godoc
+// @Summary Refresh the access token
+// @Description Exchange a refresh token for a new access token and a new refresh token. Each refresh token can be used once; reusing one revokes the whole session.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param input body models.RefreshInput true "Refresh token"
+// @Success 200 {object} responses.Response{data=middleware.TokenPair}
+// @Failure 400 {object} responses.Response
+// @Failure 401 {object} responses.Response
+// @Failure 403 {object} responses.Response
+// @Failure 500 {object} responses.Response
+// @Router /api/v1/auth/refresh [post]
+func (h *AuthHandler) RefreshToken(c *fiber.Ctx) error {
+	input := new(models.RefreshInput)
+
+	if err := c.BodyParser(input); err != nil {
+		return responses.SendError(c, apperrors.InvalidInput(err))
+	}
+
+	if err := h.validate.Struct(input); err != nil {
+		return responses.SendError(c, apperrors.Validation(err))
+	}
+
+	tokens, err := h.tokens.RotateRefreshToken(c.UserContext(), input.RefreshToken)
+	switch {
+	case errors.Is(err, middleware.ErrRefreshTokenReused):
+		return responses.SendError(c, apperrors.Unauthorized(apperrors.CodeRefreshTokenReused, "Refresh token already used, please sign in again"))
+	case errors.Is(err, middleware.ErrInvalidRefreshToken):
+		return responses.SendError(c, apperrors.Unauthorized(apperrors.CodeInvalidRefreshToken, "Invalid or expired refresh token"))
+	case errors.Is(err, middleware.ErrEmailNotVerified):
+		return responses.SendError(c, apperrors.Forbidden(apperrors.CodeEmailNotVerified, "Email address is not verified"))
+	case err != nil:
+		return responses.SendError(c, apperrors.Internal("Error refreshing token", err))
+	}
+
+	return responses.SendSuccess(c, "Token refreshed successfully", tokens)
+}
+
+// Logout godoc
+// @Summary Log out
+// @Description Revoke the session of the given refresh token, or every session of the user when all is true. Access tokens of revoked sessions stop working immediately.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param input body models.LogoutInput true "Refresh token to revoke"
+// @Success 200 {object} responses.Response
+// @Failure 400 {object} responses.Response
+// @Failure 401 {object} responses.Response
+// @Failure 500 {object} responses.Response
+// @Router /api/v1/auth/logout [post]
+func (h *AuthHandler) Logout(c *fiber.Ctx) error {
+	input := new(models.LogoutInput)
+
+	if err := c.BodyParser(input); err != nil {
+		return responses.SendError(c, apperrors.InvalidInput(err))
+	}
+
+	if err := h.validate.Struct(input); err != nil {
+		return responses.SendError(c, apperrors.Validation(err))
+	}
+
+	if _, err := h.tokens.RevokeRefreshToken(c.UserContext(), input.RefreshToken, input.All); err != nil {
+		if errors.Is(err, middleware.ErrInvalidRefreshToken) {
+			return responses.SendError(c, apperrors.Unauthorized(apperrors.CodeInvalidRefreshToken, "Invalid refresh token"))
+		}
+		return responses.SendError(c, apperrors.Internal("Error logging out", err))
+	}
+
+	return responses.SendSuccess(c, "Logged out successfully", nil)
+}
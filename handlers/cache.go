@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"context"
+	"course-api/responses"
+	"course-api/utils/cache"
+	"course-api/utils/query"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Cache tags. Items are tagged "<kind>:<id>" (see itemTag) and every list with the
+// tag of its resource, so a write invalidates the item it changed and the lists
+// that may contain it. Materials embed their topics and videos and curricula embed
+// courses and materials, so writes to those also invalidate the embedding entries.
+// Topics and videos are also tagged content and videos, which material writes
+// invalidate since they change a material's topics and videos in bulk.
+// Per-user data (users, enrollments, progress) and search results are not cached.
+const (
+	tagCourses   = "courses"
+	tagPrograms  = "programs"
+	tagMaterials = "materials"
+	tagContent   = "content"
+	tagVideos    = "videos"
+	tagCurricula = "curricula"
+)
+
+// itemTag is the tag of a single item, e.g. "material:7"
+func itemTag(kind string, id uint) string {
+	return fmt.Sprintf("%s:%d", kind, id)
+}
+
+// listKey is the cache key of a list page: prefix followed by a hash of the
+// page's parameters (see query.Params.Key), so equivalent queries share an entry
+// and parameters the list does not accept cannot add entries
+func listKey(prefix string, params *query.Params) string {
+	key := params.Key()
+	if key == "" {
+		return prefix + ":list"
+	}
+	sum := sha256.Sum256([]byte(key))
+	return prefix + ":list:" + hex.EncodeToString(sum[:8])
+}
+
+// found is the success message of a read, telling whether it came from the cache
+func found(name string, hit bool) string {
+	if hit {
+		return name + " found in cache"
+	}
+	return name + " found successfully"
+}
+
+// listPage is the cached form of a list response
+type listPage[T any] struct {
+	Rows []T        `json:"rows"`
+	Meta query.Meta `json:"meta"`
+}
+
+// sendCachedList is sendList read through the cache, answered with validators
+// (see responses.SendValidated). entry.Key is extended with the page's query
+// (see listKey); errors returned by list are not cached.
+func sendCachedList[T any](c *fiber.Ctx, store *cache.Cache, entry cache.Entry, opts query.Options, name string, list func(context.Context, *query.Params) ([]T, query.Meta, error)) error {
+	params, err := query.Parse(c, opts)
+	if err != nil {
+		return responses.SendError(c, queryError(err, "Error fetching "+strings.ToLower(name)))
+	}
+
+	entry.Key = listKey(entry.Key, params)
+	page, hit, err := cache.GetOrSet(c.UserContext(), store, entry, func(ctx context.Context) (listPage[T], error) {
+		rows, meta, err := list(ctx, params)
+		return listPage[T]{Rows: rows, Meta: meta}, err
+	})
+	if err != nil {
+		return responses.SendError(c, queryError(err, "Error fetching "+strings.ToLower(name)))
+	}
+
+	return responses.SendValidated(c, found(name, hit), page.Rows, page.Meta)
+}
+
+// invalidate drops the cache entries tagged with tags. A failure is logged by the
+// cache and does not fail the write, the entries then expire with their TTL.
+func invalidate(ctx context.Context, store *cache.Cache, tags ...string) {
+	_ = store.Invalidate(ctx, tags...)
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"course-api/apperrors"
+	"course-api/responses"
+	"course-api/utils/cache"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// CacheAdminHandler serves the endpoints administering the response cache
+type CacheAdminHandler struct {
+	cache *cache.Cache
+}
+
+// NewCacheAdminHandler returns a CacheAdminHandler
+func NewCacheAdminHandler(responseCache *cache.Cache) *CacheAdminHandler {
+	return &CacheAdminHandler{cache: responseCache}
+}
+
+// GetCacheStats godoc
+// @Summary Inspect the cache
+// @Description Count the cached keys with their memory and remaining TTLs, overall and per namespace (the part of the key before its first colon, e.g. courses). Tag sets are counted under the tag namespace. The in-process cache is that of the instance answering.
+// @Tags admin
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} responses.Response{data=cache.Stats}
+// @Failure 401 {object} responses.Response
+// @Failure 403 {object} responses.Response
+// @Router /admin/cache [get]
+func (h *CacheAdminHandler) GetCacheStats(c *fiber.Ctx) error {
+	stats, err := h.cache.Stats(c.UserContext())
+	if err != nil {
+		return responses.SendError(c, apperrors.Internal("Error reading cache stats", err))
+	}
+
+	return responses.SendSuccess(c, "Cache stats found successfully", stats)
+}
+
+// PurgeCache godoc
+// @Summary Purge the cache
+// @Description Delete every cached key, or only those of a namespace such as courses or materials. Other data sharing the Redis instance is left alone.
+// @Tags admin
+// @Produce json
+// @Param namespace path string false "Namespace to purge, the whole cache when omitted"
+// @Security ApiKeyAuth
+// @Success 200 {object} responses.Response{data=map[string]interface{}}
+// @Failure 400 {object} responses.Response
+// @Failure 401 {object} responses.Response
+// @Failure 403 {object} responses.Response
+// @Router /admin/cache/{namespace} [delete]
+func (h *CacheAdminHandler) PurgeCache(c *fiber.Ctx) error {
+	namespace := c.Params("namespace")
+
+	deleted, err := h.cache.Purge(c.UserContext(), namespace)
+	if errors.Is(err, cache.ErrTagNamespace) {
+		return responses.SendError(c, apperrors.BadRequest(apperrors.CodeInvalidCacheNamespace, "The tag namespace can only be purged with the whole cache"))
+	}
+	if err != nil {
+		return responses.SendError(c, apperrors.Internal("Error purging cache", err))
+	}
+
+	return responses.SendSuccess(c, "Cache purged successfully", fiber.Map{
+		"namespace": namespace,
+		"deleted":   deleted,
+	})
+}
@@ -1,15 +1,37 @@
 package handlers
 
 import (
-	"course-api/config"
+	"context"
+	"course-api/apperrors"
 	"course-api/models"
+	"course-api/repository"
 	"course-api/responses"
 	"course-api/types"
+	"course-api/utils/cache"
+	"course-api/utils/query"
+	"course-api/utils/search"
 	"course-api/validator"
+	"fmt"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
 
+// ContentTopicHandler serves the content topic endpoints
+type ContentTopicHandler struct {
+	repo     repository.ContentTopicRepository
+	cache    *cache.Cache
+	index    *search.Index
+	validate *validator.Validator
+	cacheTTL time.Duration
+}
+
+// NewContentTopicHandler returns a ContentTopicHandler that keeps topics in the search index
+// and caches topic reads for cacheTTL
+func NewContentTopicHandler(repo repository.ContentTopicRepository, responseCache *cache.Cache, index *search.Index, validate *validator.Validator, cacheTTL time.Duration) *ContentTopicHandler {
+	return &ContentTopicHandler{repo: repo, cache: responseCache, index: index, validate: validate, cacheTTL: cacheTTL}
+}
+
 // GetContentTopics godoc
 // @Summary Get all content topics for a material
 // @Description Retrieve all content topics for a specific material
@@ -17,20 +39,36 @@ import (
 // @Accept json
 // @Produce json
 // @Param material_id path int true "Material ID"
+// @Param page query int false "Page number, starting at 1"
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Param sort query string false "Comma separated sort keys (default order)"
 // @Security ApiKeyAuth
-// @Success 200 {object} responses.Response{data=[]models.ContentTopic}
+// @Success 200 {object} responses.Response{data=[]models.ContentTopic,meta=query.Meta}
 // @Failure 401 {object} responses.Response
 // @Failure 404 {object} responses.Response
 // @Router /materials/{material_id}/content [get]
-func GetContentTopics(c *fiber.Ctx) error {
-	materialID := c.Params("material_id")
-	var contentTopics []models.ContentTopic
+func (h *ContentTopicHandler) GetContentTopics(c *fiber.Ctx) error {
+	materialID := paramID(c, "material_id")
 
-	if err := config.DB.Where("material_id = ?", materialID).Order("order asc").Find(&contentTopics).Error; err != nil {
-		return responses.SendError(c, fiber.StatusNotFound, "Content topics not found")
-	}
+	entry := cache.Entry{Key: fmt.Sprintf("content:material:%d", materialID), TTL: h.cacheTTL, Tags: []string{itemTag("material", materialID)}}
+	return sendCachedList(c, h.cache, entry, contentTopicListOptions, "Content topics", func(ctx context.Context, params *query.Params) ([]models.ContentTopic, query.Meta, error) {
+		return h.repo.ListByMaterial(ctx, materialID, params)
+	})
+}
 
-	return responses.SendSuccess(c, "Content topics found successfully", contentTopics)
+// contentTopicListOptions lists the sort keys accepted by GetContentTopics
+var contentTopicListOptions = query.Options{
+	Sortable: map[string]string{
+		"id":         "id",
+		"order":      "order",
+		"title":      "title",
+		"created_at": "created_at",
+		"updated_at": "updated_at",
+	},
+	DefaultSort: "order",
+	Filters: []query.Filter{
+		query.Contains("q", "title"),
+	},
 }
 
 // GetContentTopic godoc
@@ -45,15 +83,18 @@ func GetContentTopics(c *fiber.Ctx) error {
 // @Failure 401 {object} responses.Response
 // @Failure 404 {object} responses.Response
 // @Router /content/{id} [get]
-func GetContentTopic(c *fiber.Ctx) error {
-	id := c.Params("id")
-	var contentTopic models.ContentTopic
-
-	if err := config.DB.First(&contentTopic, id).Error; err != nil {
-		return responses.SendError(c, fiber.StatusNotFound, "Content topic not found")
+func (h *ContentTopicHandler) GetContentTopic(c *fiber.Ctx) error {
+	id := paramID(c, "id")
+	entry := cache.Entry{Key: fmt.Sprintf("content:%d", id), TTL: h.cacheTTL, Tags: []string{itemTag("content", id), tagContent}}
+
+	contentTopic, hit, err := cache.GetOrSet(c.UserContext(), h.cache, entry, func(ctx context.Context) (models.ContentTopic, error) {
+		return h.repo.Get(ctx, id)
+	})
+	if err != nil {
+		return responses.SendError(c, lookupError(err, errContentTopicNotFound))
 	}
 
-	return responses.SendSuccess(c, "Content topic found successfully", contentTopic)
+	return responses.SendValidated(c, found("Content topic", hit), contentTopic, nil)
 }
 
 // CreateContentTopic godoc
@@ -68,15 +109,15 @@ func GetContentTopic(c *fiber.Ctx) error {
 // @Failure 400 {object} responses.Response
 // @Failure 401 {object} responses.Response
 // @Router /content [post]
-func CreateContentTopic(c *fiber.Ctx) error {
+func (h *ContentTopicHandler) CreateContentTopic(c *fiber.Ctx) error {
 	input := new(models.CreateContentTopicInput)
 
 	if err := c.BodyParser(input); err != nil {
-		return responses.SendError(c, fiber.StatusBadRequest, "Invalid input")
+		return responses.SendError(c, apperrors.InvalidInput(err))
 	}
 
-	if err := validator.Validate.Struct(input); err != nil {
-		return responses.SendError(c, fiber.StatusBadRequest, err.Error())
+	if err := h.validate.Struct(input); err != nil {
+		return responses.SendError(c, apperrors.Validation(err))
 	}
 
 	contentTopic := models.ContentTopic{
@@ -87,9 +128,11 @@ func CreateContentTopic(c *fiber.Ctx) error {
 		MaterialID: input.MaterialID,
 	}
 
-	if err := config.DB.Create(&contentTopic).Error; err != nil {
-		return responses.SendError(c, fiber.StatusInternalServerError, "Error creating content topic")
+	if err := h.repo.Create(c.UserContext(), &contentTopic); err != nil {
+		return responses.SendError(c, apperrors.Internal("Error creating content topic", err))
 	}
+	h.invalidateTopic(c.UserContext(), contentTopic)
+	h.index.IndexContentTopic(contentTopic)
 
 	return responses.SendSuccess(c, "Content topic created successfully", contentTopic)
 }
@@ -108,17 +151,16 @@ func CreateContentTopic(c *fiber.Ctx) error {
 // @Failure 401 {object} responses.Response
 // @Failure 404 {object} responses.Response
 // @Router /content/{id} [put]
-func UpdateContentTopic(c *fiber.Ctx) error {
-	id := c.Params("id")
+func (h *ContentTopicHandler) UpdateContentTopic(c *fiber.Ctx) error {
 	input := new(models.UpdateContentTopicInput)
 
 	if err := c.BodyParser(input); err != nil {
-		return responses.SendError(c, fiber.StatusBadRequest, "Invalid input")
+		return responses.SendError(c, apperrors.InvalidInput(err))
 	}
 
-	var contentTopic models.ContentTopic
-	if err := config.DB.First(&contentTopic, id).Error; err != nil {
-		return responses.SendError(c, fiber.StatusNotFound, "Content topic not found")
+	contentTopic, err := h.repo.Get(c.UserContext(), paramID(c, "id"))
+	if err != nil {
+		return responses.SendError(c, lookupError(err, errContentTopicNotFound))
 	}
 
 	if input.Title != "" {
@@ -134,9 +176,11 @@ func UpdateContentTopic(c *fiber.Ctx) error {
 		contentTopic.Order = input.Order
 	}
 
-	if err := config.DB.Save(&contentTopic).Error; err != nil {
-		return responses.SendError(c, fiber.StatusInternalServerError, "Error updating content topic")
+	if err := h.repo.Update(c.UserContext(), &contentTopic); err != nil {
+		return responses.SendError(c, apperrors.Internal("Error updating content topic", err))
 	}
+	h.invalidateTopic(c.UserContext(), contentTopic)
+	h.index.IndexContentTopic(contentTopic)
 
 	return responses.SendSuccess(c, "Content topic updated successfully", contentTopic)
 }
@@ -153,17 +197,23 @@ func UpdateContentTopic(c *fiber.Ctx) error {
 // @Failure 401 {object} responses.Response
 // @Failure 404 {object} responses.Response
 // @Router /content/{id} [delete]
-func DeleteContentTopic(c *fiber.Ctx) error {
-	id := c.Params("id")
-	var contentTopic models.ContentTopic
-
-	if err := config.DB.First(&contentTopic, id).Error; err != nil {
-		return responses.SendError(c, fiber.StatusNotFound, "Content topic not found")
+func (h *ContentTopicHandler) DeleteContentTopic(c *fiber.Ctx) error {
+	contentTopic, err := h.repo.Get(c.UserContext(), paramID(c, "id"))
+	if err != nil {
+		return responses.SendError(c, lookupError(err, errContentTopicNotFound))
 	}
 
-	if err := config.DB.Delete(&contentTopic).Error; err != nil {
-		return responses.SendError(c, fiber.StatusInternalServerError, "Error deleting content topic")
+	if err := h.repo.Delete(c.UserContext(), &contentTopic); err != nil {
+		return responses.SendError(c, apperrors.Internal("Error deleting content topic", err))
 	}
+	h.invalidateTopic(c.UserContext(), contentTopic)
+	h.index.RemoveContentTopic(contentTopic.ID)
 
 	return responses.SendSuccess(c, "Content topic deleted successfully", nil)
 }
+
+// invalidateTopic drops the cached topic and the entries embedding it: its
+// material, the material listing and the curricula
+func (h *ContentTopicHandler) invalidateTopic(ctx context.Context, topic models.ContentTopic) {
+	invalidate(ctx, h.cache, itemTag("content", topic.ID), itemTag("material", topic.MaterialID), tagMaterials, tagCurricula)
+}
@@ -2,53 +2,88 @@ package handlers
 
 import (
 	"context"
-	"course-api/config"
+	"course-api/apperrors"
 	"course-api/models"
+	"course-api/repository"
 	"course-api/responses"
 	"course-api/utils/cache"
+	"course-api/utils/query"
+	"course-api/utils/search"
+	"course-api/validator"
 	"fmt"
+	"strings"
+	"time"
 
-	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
-var validate = validator.New()
+// CourseHandler serves the course endpoints
+type CourseHandler struct {
+	repo     repository.CourseRepository
+	cache    *cache.Cache
+	index    *search.Index
+	validate *validator.Validator
+	cacheTTL time.Duration
+}
+
+// NewCourseHandler returns a CourseHandler; courses are kept in the search index and
+// course reads are cached for cacheTTL
+func NewCourseHandler(repo repository.CourseRepository, responseCache *cache.Cache, index *search.Index, validate *validator.Validator, cacheTTL time.Duration) *CourseHandler {
+	return &CourseHandler{repo: repo, cache: responseCache, index: index, validate: validate, cacheTTL: cacheTTL}
+}
+
+// courseListOptions lists the sort keys and filters accepted by GetAllCourses
+var courseListOptions = query.Options{
+	Sortable: map[string]string{
+		"id":         "id",
+		"title":      "title",
+		"instructor": "instructor",
+		"duration":   "duration",
+		"price":      "price",
+		"created_at": "created_at",
+		"updated_at": "updated_at",
+	},
+	DefaultSort: "id",
+	Filters: []query.Filter{
+		query.Custom("q", func(db *gorm.DB, value string) *gorm.DB {
+			like := "%" + strings.ToLower(value) + "%"
+			return db.Where("LOWER(title) LIKE ? OR LOWER(description) LIKE ?", like, like)
+		}),
+		query.Contains("instructor", "instructor"),
+		query.Min("price_min", "price"),
+		query.Max("price_max", "price"),
+		query.Min("duration_min", "duration"),
+		query.Max("duration_max", "duration"),
+	},
+}
 
 // GetAllCourses godoc
 // @Summary Get all courses
-// @Description Retrieve all courses from the system
+// @Description Retrieve courses with pagination, sorting and filtering
 // @Tags courses
 // @Accept json
 // @Produce json
+// @Param page query int false "Page number, starting at 1"
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Param cursor query string false "Cursor from meta.next_cursor; send empty to start cursor pagination"
+// @Param sort query string false "Comma separated sort keys, prefix with - for descending, e.g. price,-created_at"
+// @Param q query string false "Search in title and description"
+// @Param instructor query string false "Filter by instructor name"
+// @Param price_min query number false "Minimum price"
+// @Param price_max query number false "Maximum price"
+// @Param duration_min query int false "Minimum duration"
+// @Param duration_max query int false "Maximum duration"
 // @Security ApiKeyAuth
-// @Success 200 {object} responses.Response{data=[]models.Course}
+// @Success 200 {object} responses.Response{data=[]models.Course,meta=query.Meta}
+// @Failure 400 {object} responses.Response
 // @Failure 401 {object} responses.Response
 // @Failure 500 {object} responses.Response
 // @Router /courses [get]
-// GetAllCourses returns all courses with Redis caching
-func GetAllCourses(c *fiber.Ctx) error {
-	ctx := context.Background()
-	cacheKey := "courses:all"
-	var courses []models.Course
-
-	// Try to get courses from cache
-	err := cache.Get(ctx, cacheKey, &courses)
-	if err == nil {
-		return responses.SendSuccess(c, "Courses found in cache", courses)
-	}
-
-	// If not in cache, get from database
-	if err := config.DB.Find(&courses).Error; err != nil {
-		return responses.SendError(c, fiber.StatusInternalServerError, "Error fetching courses")
-	}
-
-	// Store in cache
-	if err := cache.Set(ctx, cacheKey, courses, cache.DefaultExpiration); err != nil {
-		// Log the error but don't fail the request
-		fmt.Printf("Error caching courses: %v\n", err)
-	}
-
-	return responses.SendSuccess(c, "Courses found successfully", courses)
+// GetAllCourses returns a page of courses through the cache
+func (h *CourseHandler) GetAllCourses(c *fiber.Ctx) error {
+	entry := cache.Entry{Key: "courses", TTL: h.cacheTTL, Tags: []string{tagCourses}}
+	return sendCachedList(c, h.cache, entry, courseListOptions, "Courses", h.repo.List)
 }
 
 // GetCourse godoc
@@ -63,30 +98,19 @@ func GetAllCourses(c *fiber.Ctx) error {
 // @Failure 401 {object} responses.Response
 // @Failure 404 {object} responses.Response
 // @Router /courses/{id} [get]
-// GetCourse returns a single course with Redis caching
-func GetCourse(c *fiber.Ctx) error {
-	id := c.Params("id")
-	ctx := context.Background()
-	cacheKey := fmt.Sprintf("courses:%s", id)
-	var course models.Course
-
-	// Try to get course from cache
-	err := cache.Get(ctx, cacheKey, &course)
-	if err == nil {
-		return responses.SendSuccess(c, "Course found in cache", course)
-	}
-
-	// If not in cache, get from database
-	if err := config.DB.First(&course, id).Error; err != nil {
-		return responses.SendError(c, fiber.StatusNotFound, "Course not found")
-	}
+// GetCourse returns a single course through the cache
+func (h *CourseHandler) GetCourse(c *fiber.Ctx) error {
+	id := paramID(c, "id")
+	entry := cache.Entry{Key: fmt.Sprintf("courses:%d", id), TTL: h.cacheTTL, Tags: []string{itemTag("course", id)}}
 
-	// Store in cache
-	if err := cache.Set(ctx, cacheKey, course, cache.DefaultExpiration); err != nil {
-		fmt.Printf("Error caching course: %v\n", err)
+	course, hit, err := cache.GetOrSet(c.UserContext(), h.cache, entry, func(ctx context.Context) (models.Course, error) {
+		return h.repo.Get(ctx, id)
+	})
+	if err != nil {
+		return responses.SendError(c, lookupError(err, errCourseNotFound))
 	}
 
-	return responses.SendSuccess(c, "Course found successfully", course)
+	return responses.SendValidated(c, found("Course", hit), course, nil)
 }
 
 // CreateCourse godoc
@@ -103,15 +127,15 @@ func GetCourse(c *fiber.Ctx) error {
 // @Failure 500 {object} responses.Response
 // @Router /courses [post]
 // CreateCourse creates a new course and invalidates cache
-func CreateCourse(c *fiber.Ctx) error {
+func (h *CourseHandler) CreateCourse(c *fiber.Ctx) error {
 	input := new(models.CreateCourseInput)
 
 	if err := c.BodyParser(input); err != nil {
-		return responses.SendError(c, fiber.StatusBadRequest, "Invalid input")
+		return responses.SendError(c, apperrors.InvalidInput(err))
 	}
 
-	if err := validate.Struct(input); err != nil {
-		return responses.SendError(c, fiber.StatusBadRequest, err.Error())
+	if err := h.validate.Struct(input); err != nil {
+		return responses.SendError(c, apperrors.Validation(err))
 	}
 
 	course := models.Course{
@@ -122,13 +146,12 @@ func CreateCourse(c *fiber.Ctx) error {
 		Price:       input.Price,
 	}
 
-	if err := config.DB.Create(&course).Error; err != nil {
-		return responses.SendError(c, fiber.StatusInternalServerError, "Error creating course")
+	if err := h.repo.Create(c.UserContext(), &course); err != nil {
+		return responses.SendError(c, apperrors.Internal("Error creating course", err))
 	}
 
-	// Invalidate the all courses cache
-	ctx := context.Background()
-	_ = cache.Delete(ctx, "courses:all")
+	invalidate(c.UserContext(), h.cache, tagCourses)
+	h.index.IndexCourse(course)
 
 	return responses.SendSuccess(c, "Course created successfully", course)
 }
@@ -148,17 +171,16 @@ func CreateCourse(c *fiber.Ctx) error {
 // @Failure 404 {object} responses.Response
 // @Router /courses/{id} [put]
 // UpdateCourse updates an existing course
-func UpdateCourse(c *fiber.Ctx) error {
-	id := c.Params("id")
+func (h *CourseHandler) UpdateCourse(c *fiber.Ctx) error {
 	input := new(models.UpdateCourseInput)
 
 	if err := c.BodyParser(input); err != nil {
-		return responses.SendError(c, fiber.StatusBadRequest, "Invalid input")
+		return responses.SendError(c, apperrors.InvalidInput(err))
 	}
 
-	var course models.Course
-	if err := config.DB.First(&course, id).Error; err != nil {
-		return responses.SendError(c, fiber.StatusNotFound, "Course not found")
+	course, err := h.repo.Get(c.UserContext(), paramID(c, "id"))
+	if err != nil {
+		return responses.SendError(c, lookupError(err, errCourseNotFound))
 	}
 
 	if input.Title != "" {
@@ -177,7 +199,11 @@ func UpdateCourse(c *fiber.Ctx) error {
 		course.Price = input.Price
 	}
 
-	config.DB.Save(&course)
+	if err := h.repo.Update(c.UserContext(), &course); err != nil {
+		return responses.SendError(c, apperrors.Internal("Error updating course", err))
+	}
+	h.invalidateCourse(c.UserContext(), course.ID)
+	h.index.IndexCourse(course)
 	return responses.SendSuccess(c, "Course updated successfully", course)
 }
 
@@ -194,14 +220,24 @@ func UpdateCourse(c *fiber.Ctx) error {
 // @Failure 404 {object} responses.Response
 // @Router /courses/{id} [delete]
 // DeleteCourse deletes a course
-func DeleteCourse(c *fiber.Ctx) error {
-	id := c.Params("id")
-	var course models.Course
+func (h *CourseHandler) DeleteCourse(c *fiber.Ctx) error {
+	course, err := h.repo.Get(c.UserContext(), paramID(c, "id"))
+	if err != nil {
+		return responses.SendError(c, lookupError(err, errCourseNotFound))
+	}
 
-	if err := config.DB.First(&course, id).Error; err != nil {
-		return responses.SendError(c, fiber.StatusNotFound, "Course not found")
+	// Deleting also detaches the course from programs and its materials
+	if err := h.repo.Delete(c.UserContext(), &course); err != nil {
+		return responses.SendError(c, apperrors.Internal("Error deleting course", err))
 	}
+	h.invalidateCourse(c.UserContext(), course.ID)
+	h.index.RemoveCourse(course.ID)
 
-	config.DB.Delete(&course)
 	return responses.SendSuccess(c, "Course deleted successfully", nil)
 }
+
+// invalidateCourse drops the cached course, the course lists and the curricula,
+// which embed courses
+func (h *CourseHandler) invalidateCourse(ctx context.Context, id uint) {
+	invalidate(ctx, h.cache, itemTag("course", id), tagCourses, tagCurricula)
+}
@@ -0,0 +1,390 @@
+package handlers
+
+import (
+	"context"
+	"course-api/apperrors"
+	"course-api/models"
+	"course-api/repository"
+	"course-api/responses"
+	"course-api/utils/cache"
+	"course-api/validator"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// CurriculumHandler serves the endpoints that order courses in programs and materials in courses
+type CurriculumHandler struct {
+	repo      repository.CurriculumRepository
+	programs  repository.ProgramRepository
+	courses   repository.CourseRepository
+	materials repository.MaterialRepository
+	cache     *cache.Cache
+	validate  *validator.Validator
+	cacheTTL  time.Duration
+}
+
+// NewCurriculumHandler returns a CurriculumHandler that caches curricula for cacheTTL
+func NewCurriculumHandler(repo repository.CurriculumRepository, programs repository.ProgramRepository, courses repository.CourseRepository, materials repository.MaterialRepository, responseCache *cache.Cache, validate *validator.Validator, cacheTTL time.Duration) *CurriculumHandler {
+	return &CurriculumHandler{repo: repo, programs: programs, courses: courses, materials: materials, cache: responseCache, validate: validate, cacheTTL: cacheTTL}
+}
+
+// GetProgramCurriculum godoc
+// @Summary Get a program curriculum
+// @Description Retrieve a program with its ordered courses, their ordered materials, topics and videos
+// @Tags curriculum
+// @Accept json
+// @Produce json
+// @Param id path int true "Program ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} responses.Response{data=models.ProgramCurriculum}
+// @Failure 401 {object} responses.Response
+// @Failure 404 {object} responses.Response
+// @Router /programs/{id}/curriculum [get]
+func (h *CurriculumHandler) GetProgramCurriculum(c *fiber.Ctx) error {
+	id := paramID(c, "id")
+	entry := cache.Entry{Key: fmt.Sprintf("curricula:program:%d", id), TTL: h.cacheTTL, Tags: []string{itemTag("program", id), tagCurricula}}
+
+	curriculum, hit, err := cache.GetOrSet(c.UserContext(), h.cache, entry, func(ctx context.Context) (models.ProgramCurriculum, error) {
+		program, err := h.programs.Get(ctx, id)
+		if err != nil {
+			return models.ProgramCurriculum{}, lookupError(err, errProgramNotFound)
+		}
+
+		links, err := h.repo.ProgramCourses(ctx, program.ID)
+		if err != nil {
+			return models.ProgramCurriculum{}, apperrors.Internal("Error fetching curriculum", err)
+		}
+
+		courses := make([]models.CurriculumCourse, 0, len(links))
+		for _, link := range links {
+			// Soft-deleted courses are not preloaded
+			if link.Course == nil {
+				continue
+			}
+			courses = append(courses, models.CurriculumCourse{Order: link.Order, Course: *link.Course})
+		}
+
+		if err := h.loadCurriculumMaterials(ctx, courses); err != nil {
+			return models.ProgramCurriculum{}, apperrors.Internal("Error fetching curriculum", err)
+		}
+		return models.ProgramCurriculum{Program: program, Courses: courses}, nil
+	})
+	if err != nil {
+		return responses.SendError(c, err)
+	}
+
+	return responses.SendValidated(c, found("Curriculum", hit), curriculum, nil)
+}
+
+// GetCourseCurriculum godoc
+// @Summary Get a course curriculum
+// @Description Retrieve a course with its ordered materials, topics and videos
+// @Tags curriculum
+// @Accept json
+// @Produce json
+// @Param id path int true "Course ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} responses.Response{data=models.CurriculumCourse}
+// @Failure 401 {object} responses.Response
+// @Failure 404 {object} responses.Response
+// @Router /courses/{id}/curriculum [get]
+func (h *CurriculumHandler) GetCourseCurriculum(c *fiber.Ctx) error {
+	id := paramID(c, "id")
+	entry := cache.Entry{Key: fmt.Sprintf("curricula:course:%d", id), TTL: h.cacheTTL, Tags: []string{itemTag("course", id), tagCurricula}}
+
+	curriculum, hit, err := cache.GetOrSet(c.UserContext(), h.cache, entry, func(ctx context.Context) (models.CurriculumCourse, error) {
+		course, err := h.courses.Get(ctx, id)
+		if err != nil {
+			return models.CurriculumCourse{}, lookupError(err, errCourseNotFound)
+		}
+
+		courses := []models.CurriculumCourse{{Course: course}}
+		if err := h.loadCurriculumMaterials(ctx, courses); err != nil {
+			return models.CurriculumCourse{}, apperrors.Internal("Error fetching curriculum", err)
+		}
+		return courses[0], nil
+	})
+	if err != nil {
+		return responses.SendError(c, err)
+	}
+
+	return responses.SendValidated(c, found("Curriculum", hit), curriculum, nil)
+}
+
+// AddProgramCourse godoc
+// @Summary Add a course to a program
+// @Description Attach a course to a program, appended at the end unless an order is given
+// @Tags curriculum
+// @Accept json
+// @Produce json
+// @Param id path int true "Program ID"
+// @Param input body models.AttachCourseInput true "Course to attach"
+// @Security ApiKeyAuth
+// @Success 200 {object} responses.Response{data=models.ProgramCourse}
+// @Failure 400 {object} responses.Response
+// @Failure 401 {object} responses.Response
+// @Failure 404 {object} responses.Response
+// @Router /programs/{id}/courses [post]
+func (h *CurriculumHandler) AddProgramCourse(c *fiber.Ctx) error {
+	input := new(models.AttachCourseInput)
+
+	if err := c.BodyParser(input); err != nil {
+		return responses.SendError(c, apperrors.InvalidInput(err))
+	}
+
+	if err := h.validate.Struct(input); err != nil {
+		return responses.SendError(c, apperrors.Validation(err))
+	}
+
+	program, err := h.programs.Get(c.UserContext(), paramID(c, "id"))
+	if err != nil {
+		return responses.SendError(c, lookupError(err, errProgramNotFound))
+	}
+
+	if _, err := h.courses.Get(c.UserContext(), input.CourseID); err != nil {
+		return responses.SendError(c, lookupError(err, errCourseNotFound))
+	}
+
+	exists, err := h.repo.HasProgramCourse(c.UserContext(), program.ID, input.CourseID)
+	if err != nil {
+		return responses.SendError(c, apperrors.Internal("Error adding course to program", err))
+	}
+	if exists {
+		return responses.SendError(c, apperrors.BadRequest(apperrors.CodeCourseInProgram, "Course already in program"))
+	}
+
+	link, err := h.repo.AddProgramCourse(c.UserContext(), program.ID, input.CourseID, input.Order)
+	if err != nil {
+		return responses.SendError(c, apperrors.Internal("Error adding course to program", err))
+	}
+	invalidate(c.UserContext(), h.cache, tagCurricula)
+
+	return responses.SendSuccess(c, "Course added to program successfully", link)
+}
+
+// RemoveProgramCourse godoc
+// @Summary Remove a course from a program
+// @Description Detach a course from a program without deleting the course
+// @Tags curriculum
+// @Accept json
+// @Produce json
+// @Param id path int true "Program ID"
+// @Param course_id path int true "Course ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} responses.Response
+// @Failure 401 {object} responses.Response
+// @Failure 404 {object} responses.Response
+// @Router /programs/{id}/courses/{course_id} [delete]
+func (h *CurriculumHandler) RemoveProgramCourse(c *fiber.Ctx) error {
+	removed, err := h.repo.RemoveProgramCourse(c.UserContext(), paramID(c, "id"), paramID(c, "course_id"))
+	if err != nil {
+		return responses.SendError(c, apperrors.Internal("Error removing course from program", err))
+	}
+	if !removed {
+		return responses.SendError(c, apperrors.NotFound(apperrors.CodeCourseNotInProgram, "Course not in program"))
+	}
+	invalidate(c.UserContext(), h.cache, tagCurricula)
+
+	return responses.SendSuccess(c, "Course removed from program successfully", nil)
+}
+
+// ReorderProgramCourses godoc
+// @Summary Reorder the courses of a program
+// @Description Set the order of every course in a program; ids must list all attached courses
+// @Tags curriculum
+// @Accept json
+// @Produce json
+// @Param id path int true "Program ID"
+// @Param input body models.ReorderInput true "Course IDs in their new order"
+// @Security ApiKeyAuth
+// @Success 200 {object} responses.Response{data=[]models.ProgramCourse}
+// @Failure 400 {object} responses.Response
+// @Failure 401 {object} responses.Response
+// @Failure 404 {object} responses.Response
+// @Router /programs/{id}/courses/order [put]
+func (h *CurriculumHandler) ReorderProgramCourses(c *fiber.Ctx) error {
+	input := new(models.ReorderInput)
+
+	if err := c.BodyParser(input); err != nil {
+		return responses.SendError(c, apperrors.InvalidInput(err))
+	}
+
+	if err := h.validate.Struct(input); err != nil {
+		return responses.SendError(c, apperrors.Validation(err))
+	}
+
+	program, err := h.programs.Get(c.UserContext(), paramID(c, "id"))
+	if err != nil {
+		return responses.SendError(c, lookupError(err, errProgramNotFound))
+	}
+
+	links, err := h.repo.ReorderProgramCourses(c.UserContext(), program.ID, input.IDs)
+	if err != nil {
+		return sendReorderError(c, err)
+	}
+	invalidate(c.UserContext(), h.cache, tagCurricula)
+
+	return responses.SendSuccess(c, "Program courses reordered successfully", links)
+}
+
+// AddCourseMaterial godoc
+// @Summary Add a material to a course
+// @Description Attach a material to a course, appended at the end unless an order is given
+// @Tags curriculum
+// @Accept json
+// @Produce json
+// @Param id path int true "Course ID"
+// @Param input body models.AttachMaterialInput true "Material to attach"
+// @Security ApiKeyAuth
+// @Success 200 {object} responses.Response{data=models.CourseMaterial}
+// @Failure 400 {object} responses.Response
+// @Failure 401 {object} responses.Response
+// @Failure 404 {object} responses.Response
+// @Router /courses/{id}/materials [post]
+func (h *CurriculumHandler) AddCourseMaterial(c *fiber.Ctx) error {
+	input := new(models.AttachMaterialInput)
+
+	if err := c.BodyParser(input); err != nil {
+		return responses.SendError(c, apperrors.InvalidInput(err))
+	}
+
+	if err := h.validate.Struct(input); err != nil {
+		return responses.SendError(c, apperrors.Validation(err))
+	}
+
+	course, err := h.courses.Get(c.UserContext(), paramID(c, "id"))
+	if err != nil {
+		return responses.SendError(c, lookupError(err, errCourseNotFound))
+	}
+
+	if _, err := h.materials.Get(c.UserContext(), input.MaterialID); err != nil {
+		return responses.SendError(c, lookupError(err, errMaterialNotFound))
+	}
+
+	exists, err := h.repo.HasCourseMaterial(c.UserContext(), course.ID, input.MaterialID)
+	if err != nil {
+		return responses.SendError(c, apperrors.Internal("Error adding material to course", err))
+	}
+	if exists {
+		return responses.SendError(c, apperrors.BadRequest(apperrors.CodeMaterialInCourse, "Material already in course"))
+	}
+
+	link, err := h.repo.AddCourseMaterial(c.UserContext(), course.ID, input.MaterialID, input.Order)
+	if err != nil {
+		return responses.SendError(c, apperrors.Internal("Error adding material to course", err))
+	}
+	invalidate(c.UserContext(), h.cache, tagCurricula)
+
+	return responses.SendSuccess(c, "Material added to course successfully", link)
+}
+
+// RemoveCourseMaterial godoc
+// @Summary Remove a material from a course
+// @Description Detach a material from a course without deleting the material
+// @Tags curriculum
+// @Accept json
+// @Produce json
+// @Param id path int true "Course ID"
+// @Param material_id path int true "Material ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} responses.Response
+// @Failure 401 {object} responses.Response
+// @Failure 404 {object} responses.Response
+// @Router /courses/{id}/materials/{material_id} [delete]
+func (h *CurriculumHandler) RemoveCourseMaterial(c *fiber.Ctx) error {
+	removed, err := h.repo.RemoveCourseMaterial(c.UserContext(), paramID(c, "id"), paramID(c, "material_id"))
+	if err != nil {
+		return responses.SendError(c, apperrors.Internal("Error removing material from course", err))
+	}
+	if !removed {
+		return responses.SendError(c, apperrors.NotFound(apperrors.CodeMaterialNotInCourse, "Material not in course"))
+	}
+	invalidate(c.UserContext(), h.cache, tagCurricula)
+
+	return responses.SendSuccess(c, "Material removed from course successfully", nil)
+}
+
+// ReorderCourseMaterials godoc
+// @Summary Reorder the materials of a course
+// @Description Set the order of every material in a course; ids must list all attached materials
+// @Tags curriculum
+// @Accept json
+// @Produce json
+// @Param id path int true "Course ID"
+// @Param input body models.ReorderInput true "Material IDs in their new order"
+// @Security ApiKeyAuth
+// @Success 200 {object} responses.Response{data=[]models.CourseMaterial}
+// @Failure 400 {object} responses.Response
+// @Failure 401 {object} responses.Response
+// @Failure 404 {object} responses.Response
+// @Router /courses/{id}/materials/order [put]
+func (h *CurriculumHandler) ReorderCourseMaterials(c *fiber.Ctx) error {
+	input := new(models.ReorderInput)
+
+	if err := c.BodyParser(input); err != nil {
+		return responses.SendError(c, apperrors.InvalidInput(err))
+	}
+
+	if err := h.validate.Struct(input); err != nil {
+		return responses.SendError(c, apperrors.Validation(err))
+	}
+
+	course, err := h.courses.Get(c.UserContext(), paramID(c, "id"))
+	if err != nil {
+		return responses.SendError(c, lookupError(err, errCourseNotFound))
+	}
+
+	links, err := h.repo.ReorderCourseMaterials(c.UserContext(), course.ID, input.IDs)
+	if err != nil {
+		return sendReorderError(c, err)
+	}
+	invalidate(c.UserContext(), h.cache, tagCurricula)
+
+	return responses.SendSuccess(c, "Course materials reordered successfully", links)
+}
+
+// loadCurriculumMaterials fills every course with its ordered materials,
+// each preloaded with ordered content topics and video courses
+func (h *CurriculumHandler) loadCurriculumMaterials(ctx context.Context, courses []models.CurriculumCourse) error {
+	if len(courses) == 0 {
+		return nil
+	}
+
+	courseIDs := make([]uint, len(courses))
+	for i := range courses {
+		courseIDs[i] = courses[i].ID
+		courses[i].Materials = []models.CurriculumMaterial{}
+	}
+
+	links, err := h.repo.CourseMaterials(ctx, courseIDs)
+	if err != nil {
+		return err
+	}
+
+	byCourse := make(map[uint]*models.CurriculumCourse, len(courses))
+	for i := range courses {
+		byCourse[courses[i].ID] = &courses[i]
+	}
+
+	for _, link := range links {
+		// Soft-deleted materials are not preloaded
+		if link.Material == nil {
+			continue
+		}
+		course := byCourse[link.CourseID]
+		course.Materials = append(course.Materials, models.CurriculumMaterial{Order: link.Order, Material: *link.Material})
+	}
+
+	return nil
+}
+
+// sendReorderError answers 400 when the ids do not match the attached items
+func sendReorderError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, repository.ErrInvalidOrder) {
+		return responses.SendError(c, apperrors.BadRequest(apperrors.CodeInvalidOrder, err.Error()))
+	}
+	return responses.SendError(c, apperrors.Internal("Error reordering items", err))
+}
@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"context"
+	"course-api/apperrors"
+	"course-api/middleware"
+	"course-api/models"
+	"course-api/repository"
+	"course-api/responses"
+	"course-api/utils/query"
+	"course-api/validator"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// EnrollmentHandler serves the enrollment endpoints
+type EnrollmentHandler struct {
+	repo     repository.EnrollmentRepository
+	courses  repository.CourseRepository
+	programs repository.ProgramRepository
+	validate *validator.Validator
+}
+
+// NewEnrollmentHandler returns an EnrollmentHandler
+func NewEnrollmentHandler(repo repository.EnrollmentRepository, courses repository.CourseRepository, programs repository.ProgramRepository, validate *validator.Validator) *EnrollmentHandler {
+	return &EnrollmentHandler{repo: repo, courses: courses, programs: programs, validate: validate}
+}
+
+// Enroll godoc
+// @Summary Enroll in a course or program
+// @Description Enroll the authenticated user in a course or a program
+// @Tags enrollments
+// @Accept json
+// @Produce json
+// @Param input body models.EnrollInput true "Course or program to enroll in"
+// @Security ApiKeyAuth
+// @Success 200 {object} responses.Response{data=models.Enrollment}
+// @Failure 400 {object} responses.Response
+// @Failure 401 {object} responses.Response
+// @Failure 404 {object} responses.Response
+// @Router /enrollments [post]
+func (h *EnrollmentHandler) Enroll(c *fiber.Ctx) error {
+	userID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		return responses.SendError(c, errNoUserInToken)
+	}
+
+	input := new(models.EnrollInput)
+
+	if err := c.BodyParser(input); err != nil {
+		return responses.SendError(c, apperrors.InvalidInput(err))
+	}
+
+	if err := h.validate.Struct(input); err != nil {
+		return responses.SendError(c, apperrors.Validation(err))
+	}
+
+	if input.CourseID != 0 {
+		if _, err := h.courses.Get(c.UserContext(), input.CourseID); err != nil {
+			return responses.SendError(c, lookupError(err, errCourseNotFound))
+		}
+	} else {
+		if _, err := h.programs.Get(c.UserContext(), input.ProgramID); err != nil {
+			return responses.SendError(c, lookupError(err, errProgramNotFound))
+		}
+	}
+
+	enrollment, err := h.repo.FindForUser(c.UserContext(), userID, input.CourseID, input.ProgramID)
+	if err == nil {
+		if enrollment.Status != models.EnrollmentCancelled {
+			return responses.SendError(c, errAlreadyEnrolled)
+		}
+
+		// Re-activate a previously cancelled enrollment instead of creating a duplicate
+		enrollment.SetStatus(models.EnrollmentActive)
+		if err := h.repo.Update(c.UserContext(), &enrollment); err != nil {
+			return responses.SendError(c, apperrors.Internal("Error enrolling", err))
+		}
+		return responses.SendSuccess(c, "Enrolled successfully", enrollment)
+	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		return responses.SendError(c, apperrors.Internal("Error enrolling", err))
+	}
+
+	enrollment = models.Enrollment{UserID: userID}
+	if input.CourseID != 0 {
+		enrollment.CourseID = &input.CourseID
+	} else {
+		enrollment.ProgramID = &input.ProgramID
+	}
+	enrollment.SetStatus(models.EnrollmentActive)
+
+	// A concurrent request may have enrolled the user since the lookup above
+	if err := h.repo.Create(c.UserContext(), &enrollment); errors.Is(err, repository.ErrDuplicate) {
+		return responses.SendError(c, errAlreadyEnrolled)
+	} else if err != nil {
+		return responses.SendError(c, apperrors.Internal("Error enrolling", err))
+	}
+
+	return responses.SendSuccess(c, "Enrolled successfully", enrollment)
+}
+
+// GetMyEnrollments godoc
+// @Summary Get my enrollments
+// @Description Retrieve all enrollments of the authenticated user
+// @Tags enrollments
+// @Accept json
+// @Produce json
+// @Param status query string false "Filter by status (active, completed, cancelled)"
+// @Param page query int false "Page number, starting at 1"
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Param sort query string false "Comma separated sort keys, prefix with - for descending"
+// @Security ApiKeyAuth
+// @Success 200 {object} responses.Response{data=[]models.Enrollment,meta=query.Meta}
+// @Failure 401 {object} responses.Response
+// @Failure 500 {object} responses.Response
+// @Router /enrollments/me [get]
+func (h *EnrollmentHandler) GetMyEnrollments(c *fiber.Ctx) error {
+	userID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		return responses.SendError(c, errNoUserInToken)
+	}
+
+	opts := enrollmentListOptions
+	opts.DefaultSort = "-enrolled_at"
+	return sendList(c, opts, "Enrollments", func(ctx context.Context, params *query.Params) ([]models.Enrollment, query.Meta, error) {
+		return h.repo.ListByUser(ctx, userID, params)
+	})
+}
+
+// Unenroll godoc
+// @Summary Cancel an enrollment
+// @Description Cancel one of the authenticated user's enrollments
+// @Tags enrollments
+// @Accept json
+// @Produce json
+// @Param id path int true "Enrollment ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} responses.Response{data=models.Enrollment}
+// @Failure 401 {object} responses.Response
+// @Failure 403 {object} responses.Response
+// @Failure 404 {object} responses.Response
+// @Router /enrollments/{id} [delete]
+func (h *EnrollmentHandler) Unenroll(c *fiber.Ctx) error {
+	userID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		return responses.SendError(c, errNoUserInToken)
+	}
+
+	enrollment, err := h.repo.Get(c.UserContext(), paramID(c, "id"))
+	if err != nil {
+		return responses.SendError(c, lookupError(err, errEnrollmentNotFound))
+	}
+
+	if enrollment.UserID != userID && middleware.CurrentUserRole(c) != models.RoleAdmin {
+		return responses.SendError(c, apperrors.Forbidden(apperrors.CodeInsufficientPermissions, "Insufficient permissions"))
+	}
+
+	if enrollment.Status == models.EnrollmentCancelled {
+		return responses.SendError(c, apperrors.BadRequest(apperrors.CodeEnrollmentCancelled, "Enrollment already cancelled"))
+	}
+
+	enrollment.SetStatus(models.EnrollmentCancelled)
+	if err := h.repo.Update(c.UserContext(), &enrollment); err != nil {
+		return responses.SendError(c, apperrors.Internal("Error cancelling enrollment", err))
+	}
+
+	return responses.SendSuccess(c, "Enrollment cancelled successfully", enrollment)
+}
+
+// UpdateEnrollmentStatus godoc
+// @Summary Update an enrollment status
+// @Description Set an enrollment to active, completed or cancelled
+// @Tags enrollments
+// @Accept json
+// @Produce json
+// @Param id path int true "Enrollment ID"
+// @Param input body models.UpdateEnrollmentStatusInput true "New enrollment status"
+// @Security ApiKeyAuth
+// @Success 200 {object} responses.Response{data=models.Enrollment}
+// @Failure 400 {object} responses.Response
+// @Failure 401 {object} responses.Response
+// @Failure 404 {object} responses.Response
+// @Router /enrollments/{id}/status [put]
+func (h *EnrollmentHandler) UpdateEnrollmentStatus(c *fiber.Ctx) error {
+	input := new(models.UpdateEnrollmentStatusInput)
+
+	if err := c.BodyParser(input); err != nil {
+		return responses.SendError(c, apperrors.InvalidInput(err))
+	}
+
+	if err := h.validate.Struct(input); err != nil {
+		return responses.SendError(c, apperrors.Validation(err))
+	}
+
+	enrollment, err := h.repo.Get(c.UserContext(), paramID(c, "id"))
+	if err != nil {
+		return responses.SendError(c, lookupError(err, errEnrollmentNotFound))
+	}
+
+	enrollment.SetStatus(input.Status)
+	if err := h.repo.Update(c.UserContext(), &enrollment); err != nil {
+		return responses.SendError(c, apperrors.Internal("Error updating enrollment", err))
+	}
+
+	return responses.SendSuccess(c, "Enrollment updated successfully", enrollment)
+}
+
+// GetCourseEnrollments godoc
+// @Summary Get enrollees of a course
+// @Description Retrieve all enrollments for a specific course
+// @Tags enrollments
+// @Accept json
+// @Produce json
+// @Param id path int true "Course ID"
+// @Param status query string false "Filter by status (active, completed, cancelled)"
+// @Param page query int false "Page number, starting at 1"
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Param sort query string false "Comma separated sort keys, prefix with - for descending"
+// @Security ApiKeyAuth
+// @Success 200 {object} responses.Response{data=[]models.Enrollment,meta=query.Meta}
+// @Failure 401 {object} responses.Response
+// @Failure 403 {object} responses.Response
+// @Failure 404 {object} responses.Response
+// @Router /courses/{id}/enrollments [get]
+func (h *EnrollmentHandler) GetCourseEnrollments(c *fiber.Ctx) error {
+	course, err := h.courses.Get(c.UserContext(), paramID(c, "id"))
+	if err != nil {
+		return responses.SendError(c, lookupError(err, errCourseNotFound))
+	}
+
+	return sendList(c, enrollmentListOptions, "Enrollments", func(ctx context.Context, params *query.Params) ([]models.Enrollment, query.Meta, error) {
+		return h.repo.ListByCourse(ctx, course.ID, params)
+	})
+}
+
+// GetProgramEnrollments godoc
+// @Summary Get enrollees of a program
+// @Description Retrieve all enrollments for a specific program
+// @Tags enrollments
+// @Accept json
+// @Produce json
+// @Param id path int true "Program ID"
+// @Param status query string false "Filter by status (active, completed, cancelled)"
+// @Param page query int false "Page number, starting at 1"
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Param sort query string false "Comma separated sort keys, prefix with - for descending"
+// @Security ApiKeyAuth
+// @Success 200 {object} responses.Response{data=[]models.Enrollment,meta=query.Meta}
+// @Failure 401 {object} responses.Response
+// @Failure 403 {object} responses.Response
+// @Failure 404 {object} responses.Response
+// @Router /programs/{id}/enrollments [get]
+func (h *EnrollmentHandler) GetProgramEnrollments(c *fiber.Ctx) error {
+	program, err := h.programs.Get(c.UserContext(), paramID(c, "id"))
+	if err != nil {
+		return responses.SendError(c, lookupError(err, errProgramNotFound))
+	}
+
+	return sendList(c, enrollmentListOptions, "Enrollments", func(ctx context.Context, params *query.Params) ([]models.Enrollment, query.Meta, error) {
+		return h.repo.ListByProgram(ctx, program.ID, params)
+	})
+}
+
+// enrollmentListOptions lists the sort keys and filters accepted by enrollment lists
+var enrollmentListOptions = query.Options{
+	Sortable: map[string]string{
+		"id":          "id",
+		"status":      "status",
+		"enrolled_at": "enrolled_at",
+	},
+	DefaultSort: "enrolled_at",
+	Filters: []query.Filter{
+		query.OneOf("status", "status",
+			string(models.EnrollmentActive), string(models.EnrollmentCompleted), string(models.EnrollmentCancelled)),
+	},
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"course-api/apperrors"
+	"course-api/repository"
+	"course-api/utils/query"
+	"errors"
+	"strings"
+)
+
+// Errors answered by several handlers
+var (
+	errCourseNotFound       = apperrors.NotFound(apperrors.CodeCourseNotFound, "Course not found")
+	errProgramNotFound      = apperrors.NotFound(apperrors.CodeProgramNotFound, "Program not found")
+	errMaterialNotFound     = apperrors.NotFound(apperrors.CodeMaterialNotFound, "Material not found")
+	errContentTopicNotFound = apperrors.NotFound(apperrors.CodeContentTopicNotFound, "Content topic not found")
+	errVideoNotFound        = apperrors.NotFound(apperrors.CodeVideoNotFound, "Video course not found")
+	errUserNotFound         = apperrors.NotFound(apperrors.CodeUserNotFound, "User not found")
+	errEnrollmentNotFound   = apperrors.NotFound(apperrors.CodeEnrollmentNotFound, "Enrollment not found")
+	errAlreadyEnrolled      = apperrors.BadRequest(apperrors.CodeAlreadyEnrolled, "Already enrolled")
+	errNotEnrolled          = apperrors.Forbidden(apperrors.CodeNotEnrolled, "Not enrolled in a course containing this material")
+	errNoUserInToken        = apperrors.Unauthorized(apperrors.CodeInvalidToken, "User not found in token")
+	errEmailTaken           = apperrors.BadRequest(apperrors.CodeEmailTaken, "Email already registered")
+	errInvalidCredentials   = apperrors.Unauthorized(apperrors.CodeInvalidCredentials, "Invalid credentials")
+)
+
+// lookupError maps the error of loading a row: a missing row is answered with
+// notFound, any other failure is an internal error rather than a misleading 404
+func lookupError(err error, notFound *apperrors.Error) error {
+	if errors.Is(err, repository.ErrNotFound) {
+		return notFound
+	}
+	// "Course not found" is answered as "Error loading course"
+	return apperrors.Internal("Error loading "+strings.ToLower(strings.TrimSuffix(notFound.Message, " not found")), err)
+}
+
+// queryError describes an invalid list query parameter; other errors of a list
+// query are internal errors unless they already are application errors
+func queryError(err error, message string) error {
+	var paramErr *query.ParamError
+	if errors.As(err, &paramErr) {
+		return invalidQuery(paramErr.Param, paramErr.Message)
+	}
+	var appErr *apperrors.Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	return apperrors.Internal(message, err)
+}
+
+// invalidQuery returns the INVALID_QUERY error of the query parameter param
+func invalidQuery(param, message string) *apperrors.Error {
+	return apperrors.BadRequest(apperrors.CodeInvalidQuery, "Invalid query parameter "+param).
+		WithDetails(apperrors.FieldError{Field: param, Message: message})
+}
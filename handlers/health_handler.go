@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"context"
+	"course-api/apperrors"
+	"course-api/responses"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// HealthHandler serves the liveness and readiness probes
+type HealthHandler struct {
+	db    *gorm.DB
+	redis *redis.Client
+}
+
+// NewHealthHandler returns a HealthHandler checking db and redis; redis may be nil
+func NewHealthHandler(db *gorm.DB, redis *redis.Client) *HealthHandler {
+	return &HealthHandler{db: db, redis: redis}
+}
+
+// healthCheckTimeout bounds each dependency ping of a readiness probe
+const healthCheckTimeout = 2 * time.Second
+
+// Status of the service and of each dependency in a readiness report
+const (
+	statusOK       = "ok"
+	statusDegraded = "degraded" // Serving without the optional cache
+	statusDown     = "down"
+	statusDisabled = "disabled" // Not configured
+)
+
+// ReadinessReport is the readiness report of the service and its dependencies
+type ReadinessReport struct {
+	Status   string `json:"status"`
+	Database string `json:"database"`
+	Redis    string `json:"redis"`
+}
+
+// Liveness godoc
+// @Summary Liveness probe
+// @Description Reports that the process is up, without checking its dependencies
+// @Tags health
+// @Produce json
+// @Success 200 {object} responses.Response
+// @Router /healthz [get]
+func (h *HealthHandler) Liveness(c *fiber.Ctx) error {
+	return responses.SendSuccess(c, "Service is alive", fiber.Map{"status": statusOK})
+}
+
+// Readiness godoc
+// @Summary Readiness probe
+// @Description Pings the database and Redis. The service is not ready without its database; without Redis it is degraded but still serves requests, uncached.
+// @Tags health
+// @Produce json
+// @Success 200 {object} responses.Response{data=handlers.ReadinessReport}
+// @Failure 503 {object} responses.Response{data=handlers.ReadinessReport}
+// @Router /readyz [get]
+func (h *HealthHandler) Readiness(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(c.Context(), healthCheckTimeout)
+	defer cancel()
+
+	report := ReadinessReport{Status: statusOK, Database: statusOK, Redis: statusOK}
+
+	if err := h.pingDB(ctx); err != nil {
+		report.Database = statusDown
+	}
+
+	switch {
+	case h.redis == nil:
+		report.Redis = statusDisabled
+	case h.redis.Ping(ctx).Err() != nil:
+		report.Redis = statusDown
+	}
+
+	if report.Database != statusOK {
+		report.Status = statusDown
+		return c.Status(fiber.StatusServiceUnavailable).JSON(responses.Response{
+			Success: false,
+			Message: "Service is not ready",
+			Data:    report,
+			Error:   &responses.ErrorBody{Code: apperrors.CodeUnavailable},
+		})
+	}
+	if report.Redis != statusOK {
+		report.Status = statusDegraded
+		return responses.SendSuccess(c, "Service is ready without cache", report)
+	}
+	return responses.SendSuccess(c, "Service is ready", report)
+}
+
+// pingDB checks that the database accepts connections
+func (h *HealthHandler) pingDB(ctx context.Context) error {
+	sqlDB, err := h.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"context"
+	"course-api/responses"
+	"course-api/utils/query"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// sendList parses the list parameters, loads one page with list and responds with it.
+// name is the plural resource name used in messages, e.g. "Courses".
+func sendList[T any](c *fiber.Ctx, opts query.Options, name string, list func(context.Context, *query.Params) ([]T, query.Meta, error)) error {
+	params, err := query.Parse(c, opts)
+	if err != nil {
+		return responses.SendError(c, queryError(err, "Error fetching "+strings.ToLower(name)))
+	}
+
+	rows, meta, err := list(c.UserContext(), params)
+	if err != nil {
+		return responses.SendError(c, queryError(err, "Error fetching "+strings.ToLower(name)))
+	}
+
+	return responses.SendList(c, name+" found successfully", rows, meta)
+}
+
+// paramID reads a numeric path parameter. Anything else yields 0, which matches no row.
+func paramID(c *fiber.Ctx, key string) uint {
+	id, err := strconv.ParseUint(c.Params(key), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return uint(id)
+}
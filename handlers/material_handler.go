@@ -1,51 +1,120 @@
 package handlers
 
 import (
-	"course-api/config"
+	"context"
+	"course-api/apperrors"
 	"course-api/models"
+	"course-api/repository"
 	"course-api/responses"
 	"course-api/types"
+	"course-api/utils/cache"
+	"course-api/utils/query"
+	"course-api/utils/search"
 	"course-api/validator"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
-// GetAllMaterials returns all materials with their related content and video courses
-func GetAllMaterials(c *fiber.Ctx) error {
-	var materials []models.Material
-	if err := config.DB.Preload("Content").Preload("VideoCourses").Find(&materials).Error; err != nil {
-		return responses.SendError(c, fiber.StatusInternalServerError, "Error fetching materials")
+// MaterialHandler serves the material endpoints
+type MaterialHandler struct {
+	repo     repository.MaterialRepository
+	cache    *cache.Cache
+	index    *search.Index
+	validate *validator.Validator
+	cacheTTL time.Duration
+}
+
+// NewMaterialHandler returns a MaterialHandler that keeps materials in the search index
+// and caches material reads for cacheTTL
+func NewMaterialHandler(repo repository.MaterialRepository, responseCache *cache.Cache, index *search.Index, validate *validator.Validator, cacheTTL time.Duration) *MaterialHandler {
+	return &MaterialHandler{repo: repo, cache: responseCache, index: index, validate: validate, cacheTTL: cacheTTL}
+}
+
+// materialListOptions lists the sort keys and filters accepted by GetAllMaterials
+var materialListOptions = query.Options{
+	Sortable: map[string]string{
+		"id":         "id",
+		"title":      "title",
+		"duration":   "duration",
+		"lessons":    "lessons",
+		"created_at": "created_at",
+		"updated_at": "updated_at",
+	},
+	DefaultSort: "id",
+	Filters: []query.Filter{
+		query.Contains("q", "title"),
+		query.Min("duration_min", "duration"),
+		query.Max("duration_max", "duration"),
+		// Materials offering at least one video of the level
+		query.Custom("level", func(db *gorm.DB, value string) *gorm.DB {
+			videos := db.Session(&gorm.Session{NewDB: true}).Model(&models.VideoCourse{}).Select("material_id").Where("level = ?", value)
+			return db.Where("id IN (?)", videos)
+		}),
+	},
+}
+
+// GetAllMaterials returns a page of materials. Content topics and video courses
+// are only included when asked for with include=content,videoCourses
+func (h *MaterialHandler) GetAllMaterials(c *fiber.Ctx) error {
+	var associations []string
+	for _, include := range strings.Split(c.Query("include"), ",") {
+		switch strings.TrimSpace(include) {
+		case "":
+		case "content":
+			associations = append(associations, "Content")
+		case "videoCourses":
+			associations = append(associations, "VideoCourses")
+		default:
+			return responses.SendError(c, invalidQuery("include", "must be content or videoCourses"))
+		}
 	}
-	return responses.SendSuccess(c, "Materials found successfully", materials)
+
+	entry := cache.Entry{Key: "materials", TTL: h.cacheTTL, Tags: []string{tagMaterials}}
+	if len(associations) > 0 {
+		// Lists including the same associations share entries, however they were asked for
+		slices.Sort(associations)
+		associations = slices.Compact(associations)
+		entry.Key += ":include:" + strings.Join(associations, ",")
+	}
+	return sendCachedList(c, h.cache, entry, materialListOptions, "Materials", func(ctx context.Context, params *query.Params) ([]models.Material, query.Meta, error) {
+		return h.repo.List(ctx, params, associations...)
+	})
 }
 
 // GetMaterial returns a single material with its related content and video courses
-func GetMaterial(c *fiber.Ctx) error {
-	id := c.Params("id")
-	var material models.Material
-
-	if err := config.DB.Preload("Content").Preload("VideoCourses").First(&material, id).Error; err != nil {
-		return responses.SendError(c, fiber.StatusNotFound, "Material not found")
+// through the cache
+func (h *MaterialHandler) GetMaterial(c *fiber.Ctx) error {
+	id := paramID(c, "id")
+	entry := cache.Entry{Key: fmt.Sprintf("materials:%d", id), TTL: h.cacheTTL, Tags: []string{itemTag("material", id)}}
+
+	material, hit, err := cache.GetOrSet(c.UserContext(), h.cache, entry, func(ctx context.Context) (models.Material, error) {
+		return h.repo.GetWithChildren(ctx, id)
+	})
+	if err != nil {
+		return responses.SendError(c, lookupError(err, errMaterialNotFound))
 	}
 
-	return responses.SendSuccess(c, "Material found successfully", material)
+	return responses.SendValidated(c, found("Material", hit), material, nil)
 }
 
 // CreateMaterial creates a new material with its related content and video courses
-func CreateMaterial(c *fiber.Ctx) error {
+func (h *MaterialHandler) CreateMaterial(c *fiber.Ctx) error {
 	input := new(models.CreateMaterialInput)
 
 	if err := c.BodyParser(input); err != nil {
-		return responses.SendError(c, fiber.StatusBadRequest, "Invalid input")
+		return responses.SendError(c, apperrors.InvalidInput(err))
 	}
 
-	if err := validator.Validate.Struct(input); err != nil {
-		return responses.SendError(c, fiber.StatusBadRequest, err.Error())
+	if err := h.validate.Struct(input); err != nil {
+		return responses.SendError(c, apperrors.Validation(err))
 	}
 
-	// Begin transaction
-	tx := config.DB.Begin()
-
 	material := models.Material{
 		Title:          input.Title,
 		Description:    input.Description,
@@ -55,71 +124,78 @@ func CreateMaterial(c *fiber.Ctx) error {
 		LearningPoints: types.LearningPoint(input.LearningPoints),
 	}
 
-	if err := tx.Create(&material).Error; err != nil {
-		tx.Rollback()
-		return responses.SendError(c, fiber.StatusInternalServerError, "Error creating material")
-	}
-
-	// Create associated content topics
 	for _, contentInput := range input.Content {
-		content := models.ContentTopic{
-			Title:      contentInput.Title,
-			Topics:     contentInput.Topics,
-			MaterialID: material.ID,
-		}
-		if err := tx.Create(&content).Error; err != nil {
-			tx.Rollback()
-			return responses.SendError(c, fiber.StatusInternalServerError, "Error creating content")
-		}
+		material.Content = append(material.Content, models.ContentTopic{
+			Title:   contentInput.Title,
+			Content: contentInput.Content,
+			Topics:  contentInput.Topics,
+			Order:   contentInput.Order,
+		})
 	}
 
-	// Create associated video courses
-	for _, courseInput := range input.VideoCourses {
-		course := models.VideoCourse{
+	// Videos keep the order they were listed in
+	for i, courseInput := range input.VideoCourses {
+		material.VideoCourses = append(material.VideoCourses, models.VideoCourse{
 			Title:       courseInput.Title,
 			Description: courseInput.Description,
 			YoutubeID:   courseInput.YoutubeID,
 			Duration:    courseInput.Duration,
 			Instructor:  courseInput.Instructor,
 			Level:       courseInput.Level,
-			MaterialID:  material.ID,
-		}
-		if err := tx.Create(&course).Error; err != nil {
-			tx.Rollback()
-			return responses.SendError(c, fiber.StatusInternalServerError, "Error creating video course")
-		}
+			Order:       i,
+		})
 	}
 
-	// Commit transaction
-	if err := tx.Commit().Error; err != nil {
-		return responses.SendError(c, fiber.StatusInternalServerError, "Error saving material")
+	if err := h.repo.Create(c.UserContext(), &material); err != nil {
+		return responses.SendError(c, apperrors.Internal("Error creating material", err))
 	}
+	// The new videos show up in the video listing
+	invalidate(c.UserContext(), h.cache, tagMaterials, tagVideos)
 
 	// Fetch the complete material with associations
-	var completeMaterial models.Material
-	if err := config.DB.Preload("Content").Preload("VideoCourses").First(&completeMaterial, material.ID).Error; err != nil {
-		return responses.SendError(c, fiber.StatusInternalServerError, "Error fetching created material")
+	completeMaterial, err := h.repo.GetWithChildren(c.UserContext(), material.ID)
+	if err != nil {
+		return responses.SendError(c, apperrors.Internal("Error fetching created material", err))
 	}
+	h.index.IndexMaterial(completeMaterial)
 
 	return responses.SendSuccess(c, "Material created successfully", completeMaterial)
 }
 
-// UpdateMaterial updates an existing material and its related content and video courses
-func UpdateMaterial(c *fiber.Ctx) error {
-	id := c.Params("id")
+// UpdateMaterial updates an existing material and reconciles its content topics and
+// video courses by ID: listed rows with an ID are updated, rows without one are created,
+// and rows flagged with delete (or, in replace mode, left out of the list) are deleted
+func (h *MaterialHandler) UpdateMaterial(c *fiber.Ctx) error {
 	input := new(models.UpdateMaterialInput)
 
 	if err := c.BodyParser(input); err != nil {
-		return responses.SendError(c, fiber.StatusBadRequest, "Invalid input")
+		return responses.SendError(c, apperrors.InvalidInput(err))
+	}
+
+	if err := h.validate.Struct(input); err != nil {
+		return responses.SendError(c, apperrors.Validation(err))
 	}
 
-	var material models.Material
-	if err := config.DB.Preload("Content").Preload("VideoCourses").First(&material, id).Error; err != nil {
-		return responses.SendError(c, fiber.StatusNotFound, "Material not found")
+	material, err := h.repo.Get(c.UserContext(), paramID(c, "id"))
+	if err != nil {
+		return responses.SendError(c, lookupError(err, errMaterialNotFound))
 	}
 
-	// Begin transaction
-	tx := config.DB.Begin()
+	// New rows must be complete, unlike updates which only change the fields given
+	for i, item := range input.Content {
+		if item.ID == 0 && !item.Delete {
+			if err := h.validate.Struct(item.NewContentTopic(material.ID)); err != nil {
+				return responses.SendError(c, apperrors.ValidationAt(fmt.Sprintf("content[%d]", i), err))
+			}
+		}
+	}
+	for i, item := range input.VideoCourses {
+		if item.ID == 0 && !item.Delete {
+			if err := h.validate.Struct(item.NewVideoCourse(material.ID)); err != nil {
+				return responses.SendError(c, apperrors.ValidationAt(fmt.Sprintf("videoCourses[%d]", i), err))
+			}
+		}
+	}
 
 	if input.Title != "" {
 		material.Title = input.Title
@@ -140,107 +216,49 @@ func UpdateMaterial(c *fiber.Ctx) error {
 		material.LearningPoints = types.LearningPoint(input.LearningPoints)
 	}
 
-	if err := tx.Save(&material).Error; err != nil {
-		tx.Rollback()
-		return responses.SendError(c, fiber.StatusInternalServerError, "Error updating material")
-	}
-
-	// Update content topics if provided
-	if len(input.Content) > 0 {
-		// Delete existing content
-		if err := tx.Where("material_id = ?", material.ID).Delete(&models.ContentTopic{}).Error; err != nil {
-			tx.Rollback()
-			return responses.SendError(c, fiber.StatusInternalServerError, "Error updating content")
-		}
-
-		// Create new content
-		for _, contentInput := range input.Content {
-			content := models.ContentTopic{
-				Title:      contentInput.Title,
-				Topics:     contentInput.Topics,
-				MaterialID: material.ID,
-			}
-			if err := tx.Create(&content).Error; err != nil {
-				tx.Rollback()
-				return responses.SendError(c, fiber.StatusInternalServerError, "Error creating content")
-			}
-		}
-	}
-
-	// Update video courses if provided
-	if len(input.VideoCourses) > 0 {
-		// Delete existing video courses
-		if err := tx.Where("material_id = ?", material.ID).Delete(&models.VideoCourse{}).Error; err != nil {
-			tx.Rollback()
-			return responses.SendError(c, fiber.StatusInternalServerError, "Error updating video courses")
-		}
-
-		// Create new video courses
-		for _, courseInput := range input.VideoCourses {
-			course := models.VideoCourse{
-				Title:       courseInput.Title,
-				Description: courseInput.Description,
-				YoutubeID:   courseInput.YoutubeID,
-				Duration:    courseInput.Duration,
-				Instructor:  courseInput.Instructor,
-				Level:       courseInput.Level,
-				MaterialID:  material.ID,
-			}
-			if err := tx.Create(&course).Error; err != nil {
-				tx.Rollback()
-				return responses.SendError(c, fiber.StatusInternalServerError, "Error creating video course")
-			}
+	replace := input.Mode != models.SyncMerge
+	changes, err := h.repo.Update(c.UserContext(), &material, input.Content, input.VideoCourses, replace)
+	if err != nil {
+		var syncErr repository.SyncError
+		if errors.As(err, &syncErr) {
+			return responses.SendError(c, apperrors.BadRequest(apperrors.CodeInvalidMaterialItem, syncErr.Error()))
 		}
+		return responses.SendError(c, apperrors.Internal("Error updating material", err))
 	}
-
-	// Commit transaction
-	if err := tx.Commit().Error; err != nil {
-		return responses.SendError(c, fiber.StatusInternalServerError, "Error saving material")
-	}
+	h.invalidateMaterial(c.UserContext(), material.ID)
 
 	// Fetch the updated material with associations
-	var updatedMaterial models.Material
-	if err := config.DB.Preload("Content").Preload("VideoCourses").First(&updatedMaterial, material.ID).Error; err != nil {
-		return responses.SendError(c, fiber.StatusInternalServerError, "Error fetching updated material")
+	updatedMaterial, err := h.repo.GetWithChildren(c.UserContext(), material.ID)
+	if err != nil {
+		return responses.SendError(c, apperrors.Internal("Error fetching updated material", err))
 	}
+	h.index.IndexMaterial(updatedMaterial)
 
-	return responses.SendSuccess(c, "Material updated successfully", updatedMaterial)
+	return responses.SendSuccess(c, "Material updated successfully", models.MaterialUpdateResult{
+		Material: updatedMaterial,
+		Changes:  changes,
+	})
 }
 
 // DeleteMaterial deletes a material and its related content and video courses
-func DeleteMaterial(c *fiber.Ctx) error {
-	id := c.Params("id")
-	var material models.Material
-
-	if err := config.DB.First(&material, id).Error; err != nil {
-		return responses.SendError(c, fiber.StatusNotFound, "Material not found")
-	}
-
-	// Begin transaction
-	tx := config.DB.Begin()
-
-	// Delete related content topics
-	if err := tx.Where("material_id = ?", material.ID).Delete(&models.ContentTopic{}).Error; err != nil {
-		tx.Rollback()
-		return responses.SendError(c, fiber.StatusInternalServerError, "Error deleting content")
+func (h *MaterialHandler) DeleteMaterial(c *fiber.Ctx) error {
+	material, err := h.repo.Get(c.UserContext(), paramID(c, "id"))
+	if err != nil {
+		return responses.SendError(c, lookupError(err, errMaterialNotFound))
 	}
 
-	// Delete related video courses
-	if err := tx.Where("material_id = ?", material.ID).Delete(&models.VideoCourse{}).Error; err != nil {
-		tx.Rollback()
-		return responses.SendError(c, fiber.StatusInternalServerError, "Error deleting video courses")
-	}
-
-	// Delete the material
-	if err := tx.Delete(&material).Error; err != nil {
-		tx.Rollback()
-		return responses.SendError(c, fiber.StatusInternalServerError, "Error deleting material")
-	}
-
-	// Commit transaction
-	if err := tx.Commit().Error; err != nil {
-		return responses.SendError(c, fiber.StatusInternalServerError, "Error completing deletion")
+	// Deleting also detaches the material from courses
+	if err := h.repo.Delete(c.UserContext(), &material); err != nil {
+		return responses.SendError(c, apperrors.Internal("Error deleting material", err))
 	}
+	h.invalidateMaterial(c.UserContext(), material.ID)
+	h.index.RemoveMaterial(material.ID)
 
 	return responses.SendSuccess(c, "Material deleted successfully", nil)
 }
+
+// invalidateMaterial drops the cached material and everything embedding it or its
+// topics and videos, which an update or delete may have changed in bulk
+func (h *MaterialHandler) invalidateMaterial(ctx context.Context, id uint) {
+	invalidate(ctx, h.cache, itemTag("material", id), tagMaterials, tagContent, tagVideos, tagCurricula)
+}
@@ -1,31 +1,77 @@
 package handlers
 
 import (
-	"course-api/config"
+	"context"
+	"course-api/apperrors"
 	"course-api/models"
+	"course-api/repository"
 	"course-api/responses"
 	"course-api/types"
+	"course-api/utils/cache"
+	"course-api/utils/query"
 	"course-api/validator"
+	"fmt"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
 
+// ProgramHandler serves the program endpoints
+type ProgramHandler struct {
+	repo     repository.ProgramRepository
+	cache    *cache.Cache
+	validate *validator.Validator
+	cacheTTL time.Duration
+}
+
+// NewProgramHandler returns a ProgramHandler that caches program reads for cacheTTL
+func NewProgramHandler(repo repository.ProgramRepository, responseCache *cache.Cache, validate *validator.Validator, cacheTTL time.Duration) *ProgramHandler {
+	return &ProgramHandler{repo: repo, cache: responseCache, validate: validate, cacheTTL: cacheTTL}
+}
+
+// programListOptions lists the sort keys and filters accepted by GetAllPrograms
+var programListOptions = query.Options{
+	Sortable: map[string]string{
+		"id":         "id",
+		"title":      "title",
+		"type":       "type",
+		"price":      "price",
+		"created_at": "created_at",
+		"updated_at": "updated_at",
+	},
+	DefaultSort: "id",
+	Filters: []query.Filter{
+		query.Contains("q", "title"),
+		query.OneOf("type", "type", "regular", "intensive"),
+		query.Min("price_min", "price"),
+		query.Max("price_max", "price"),
+	},
+}
+
 // GetAllPrograms godoc
 // @Summary Get all programs
-// @Description Retrieve all programs from the system
+// @Description Retrieve programs with pagination, sorting and filtering
 // @Tags programs
 // @Accept json
 // @Produce json
+// @Param page query int false "Page number, starting at 1"
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Param cursor query string false "Cursor from meta.next_cursor; send empty to start cursor pagination"
+// @Param sort query string false "Comma separated sort keys, prefix with - for descending, e.g. price,-created_at"
+// @Param q query string false "Search in title"
+// @Param type query string false "Filter by type (regular, intensive)"
+// @Param price_min query number false "Minimum price"
+// @Param price_max query number false "Maximum price"
 // @Security ApiKeyAuth
-// @Success 200 {object} responses.Response{data=[]models.Program}
+// @Success 200 {object} responses.Response{data=[]models.Program,meta=query.Meta}
+// @Failure 400 {object} responses.Response
 // @Failure 401 {object} responses.Response
 // @Failure 500 {object} responses.Response
 // @Router /programs [get]
-// GetAllPrograms returns all programs
-func GetAllPrograms(c *fiber.Ctx) error {
-	var programs []models.Program
-	config.DB.Find(&programs)
-	return responses.SendSuccess(c, "Programs found successfully", programs)
+// GetAllPrograms returns a page of programs through the cache
+func (h *ProgramHandler) GetAllPrograms(c *fiber.Ctx) error {
+	entry := cache.Entry{Key: "programs", TTL: h.cacheTTL, Tags: []string{tagPrograms}}
+	return sendCachedList(c, h.cache, entry, programListOptions, "Programs", h.repo.List)
 }
 
 // GetProgram godoc
@@ -40,16 +86,19 @@ func GetAllPrograms(c *fiber.Ctx) error {
 // @Failure 401 {object} responses.Response
 // @Failure 404 {object} responses.Response
 // @Router /programs/{id} [get]
-// GetProgram returns a single program
-func GetProgram(c *fiber.Ctx) error {
-	id := c.Params("id")
-	var program models.Program
-
-	if err := config.DB.First(&program, id).Error; err != nil {
-		return responses.SendError(c, fiber.StatusNotFound, "Program not found")
+// GetProgram returns a single program through the cache
+func (h *ProgramHandler) GetProgram(c *fiber.Ctx) error {
+	id := paramID(c, "id")
+	entry := cache.Entry{Key: fmt.Sprintf("programs:%d", id), TTL: h.cacheTTL, Tags: []string{itemTag("program", id)}}
+
+	program, hit, err := cache.GetOrSet(c.UserContext(), h.cache, entry, func(ctx context.Context) (models.Program, error) {
+		return h.repo.Get(ctx, id)
+	})
+	if err != nil {
+		return responses.SendError(c, lookupError(err, errProgramNotFound))
 	}
 
-	return responses.SendSuccess(c, "Program found successfully", program)
+	return responses.SendValidated(c, found("Program", hit), program, nil)
 }
 
 // CreateProgram godoc
@@ -66,15 +115,15 @@ func GetProgram(c *fiber.Ctx) error {
 // @Failure 500 {object} responses.Response
 // @Router /programs [post]
 // CreateProgram creates a new program
-func CreateProgram(c *fiber.Ctx) error {
+func (h *ProgramHandler) CreateProgram(c *fiber.Ctx) error {
 	input := new(models.CreateProgramInput)
 
 	if err := c.BodyParser(input); err != nil {
-		return responses.SendError(c, fiber.StatusBadRequest, "Invalid input")
+		return responses.SendError(c, apperrors.InvalidInput(err))
 	}
 
-	if err := validator.Validate.Struct(input); err != nil {
-		return responses.SendError(c, fiber.StatusBadRequest, err.Error())
+	if err := h.validate.Struct(input); err != nil {
+		return responses.SendError(c, apperrors.Validation(err))
 	}
 
 	program := models.Program{
@@ -85,9 +134,10 @@ func CreateProgram(c *fiber.Ctx) error {
 		Features: types.StringArray(input.Features),
 	}
 
-	if err := config.DB.Create(&program).Error; err != nil {
-		return responses.SendError(c, fiber.StatusInternalServerError, "Error creating program")
+	if err := h.repo.Create(c.UserContext(), &program); err != nil {
+		return responses.SendError(c, apperrors.Internal("Error creating program", err))
 	}
+	invalidate(c.UserContext(), h.cache, tagPrograms)
 
 	return responses.SendSuccess(c, "Program created successfully", program)
 }
@@ -107,17 +157,16 @@ func CreateProgram(c *fiber.Ctx) error {
 // @Failure 404 {object} responses.Response
 // @Router /programs/{id} [put]
 // UpdateProgram updates an existing program
-func UpdateProgram(c *fiber.Ctx) error {
-	id := c.Params("id")
+func (h *ProgramHandler) UpdateProgram(c *fiber.Ctx) error {
 	input := new(models.UpdateProgramInput)
 
 	if err := c.BodyParser(input); err != nil {
-		return responses.SendError(c, fiber.StatusBadRequest, "Invalid input")
+		return responses.SendError(c, apperrors.InvalidInput(err))
 	}
 
-	var program models.Program
-	if err := config.DB.First(&program, id).Error; err != nil {
-		return responses.SendError(c, fiber.StatusNotFound, "Program not found")
+	program, err := h.repo.Get(c.UserContext(), paramID(c, "id"))
+	if err != nil {
+		return responses.SendError(c, lookupError(err, errProgramNotFound))
 	}
 
 	if input.Title != "" {
@@ -136,9 +185,10 @@ func UpdateProgram(c *fiber.Ctx) error {
 		program.Features = types.StringArray(input.Features)
 	}
 
-	if err := config.DB.Save(&program).Error; err != nil {
-		return responses.SendError(c, fiber.StatusInternalServerError, "Error updating program")
+	if err := h.repo.Update(c.UserContext(), &program); err != nil {
+		return responses.SendError(c, apperrors.Internal("Error updating program", err))
 	}
+	invalidate(c.UserContext(), h.cache, itemTag("program", program.ID), tagPrograms)
 
 	return responses.SendSuccess(c, "Program updated successfully", program)
 }
@@ -156,17 +206,17 @@ func UpdateProgram(c *fiber.Ctx) error {
 // @Failure 404 {object} responses.Response
 // @Router /programs/{id} [delete]
 // DeleteProgram deletes a program
-func DeleteProgram(c *fiber.Ctx) error {
-	id := c.Params("id")
-	var program models.Program
-
-	if err := config.DB.First(&program, id).Error; err != nil {
-		return responses.SendError(c, fiber.StatusNotFound, "Program not found")
+func (h *ProgramHandler) DeleteProgram(c *fiber.Ctx) error {
+	program, err := h.repo.Get(c.UserContext(), paramID(c, "id"))
+	if err != nil {
+		return responses.SendError(c, lookupError(err, errProgramNotFound))
 	}
 
-	if err := config.DB.Delete(&program).Error; err != nil {
-		return responses.SendError(c, fiber.StatusInternalServerError, "Error deleting program")
+	// Deleting also detaches the program's courses
+	if err := h.repo.Delete(c.UserContext(), &program); err != nil {
+		return responses.SendError(c, apperrors.Internal("Error deleting program", err))
 	}
+	invalidate(c.UserContext(), h.cache, itemTag("program", program.ID), tagPrograms)
 
 	return responses.SendSuccess(c, "Program deleted successfully", nil)
 }
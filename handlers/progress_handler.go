@@ -0,0 +1,419 @@
+package handlers
+
+import (
+	"context"
+	"course-api/apperrors"
+	"course-api/middleware"
+	"course-api/models"
+	"course-api/repository"
+	"course-api/responses"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ProgressHandler serves the endpoints that track completed content topics and videos
+type ProgressHandler struct {
+	repo        repository.ProgressRepository
+	enrollments repository.EnrollmentRepository
+	users       repository.UserRepository
+	courses     repository.CourseRepository
+	materials   repository.MaterialRepository
+	topics      repository.ContentTopicRepository
+	videos      repository.VideoRepository
+}
+
+// NewProgressHandler returns a ProgressHandler
+func NewProgressHandler(repo repository.ProgressRepository, enrollments repository.EnrollmentRepository, users repository.UserRepository, courses repository.CourseRepository, materials repository.MaterialRepository, topics repository.ContentTopicRepository, videos repository.VideoRepository) *ProgressHandler {
+	return &ProgressHandler{repo: repo, enrollments: enrollments, users: users, courses: courses, materials: materials, topics: topics, videos: videos}
+}
+
+// CompleteContentTopic godoc
+// @Summary Mark a content topic as completed
+// @Description Record that the authenticated user finished a content topic of a course they are actively enrolled in, directly or through a program
+// @Tags progress
+// @Accept json
+// @Produce json
+// @Param id path int true "Content Topic ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} responses.Response{data=models.MaterialProgress}
+// @Failure 401 {object} responses.Response
+// @Failure 403 {object} responses.Response
+// @Failure 404 {object} responses.Response
+// @Router /me/progress/topics/{id} [post]
+func (h *ProgressHandler) CompleteContentTopic(c *fiber.Ctx) error {
+	topic, err := h.topics.Get(c.UserContext(), paramID(c, "id"))
+	if err != nil {
+		return responses.SendError(c, lookupError(err, errContentTopicNotFound))
+	}
+
+	return h.markCompleted(c, models.ProgressContentTopic, topic.ID, topic.MaterialID)
+}
+
+// UncompleteContentTopic godoc
+// @Summary Unmark a completed content topic
+// @Description Remove the completion record of a content topic for the authenticated user
+// @Tags progress
+// @Accept json
+// @Produce json
+// @Param id path int true "Content Topic ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} responses.Response{data=models.MaterialProgress}
+// @Failure 401 {object} responses.Response
+// @Failure 404 {object} responses.Response
+// @Router /me/progress/topics/{id} [delete]
+func (h *ProgressHandler) UncompleteContentTopic(c *fiber.Ctx) error {
+	topic, err := h.topics.Get(c.UserContext(), paramID(c, "id"))
+	if err != nil {
+		return responses.SendError(c, lookupError(err, errContentTopicNotFound))
+	}
+
+	return h.unmarkCompleted(c, models.ProgressContentTopic, topic.ID, topic.MaterialID)
+}
+
+// CompleteVideoCourse godoc
+// @Summary Mark a video as watched
+// @Description Record that the authenticated user watched a video course of a course they are actively enrolled in, directly or through a program
+// @Tags progress
+// @Accept json
+// @Produce json
+// @Param id path int true "Video Course ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} responses.Response{data=models.MaterialProgress}
+// @Failure 401 {object} responses.Response
+// @Failure 403 {object} responses.Response
+// @Failure 404 {object} responses.Response
+// @Router /me/progress/videos/{id} [post]
+func (h *ProgressHandler) CompleteVideoCourse(c *fiber.Ctx) error {
+	video, err := h.videos.Get(c.UserContext(), paramID(c, "id"))
+	if err != nil {
+		return responses.SendError(c, lookupError(err, errVideoNotFound))
+	}
+
+	return h.markCompleted(c, models.ProgressVideoCourse, video.ID, video.MaterialID)
+}
+
+// UncompleteVideoCourse godoc
+// @Summary Unmark a watched video
+// @Description Remove the completion record of a video course for the authenticated user
+// @Tags progress
+// @Accept json
+// @Produce json
+// @Param id path int true "Video Course ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} responses.Response{data=models.MaterialProgress}
+// @Failure 401 {object} responses.Response
+// @Failure 404 {object} responses.Response
+// @Router /me/progress/videos/{id} [delete]
+func (h *ProgressHandler) UncompleteVideoCourse(c *fiber.Ctx) error {
+	video, err := h.videos.Get(c.UserContext(), paramID(c, "id"))
+	if err != nil {
+		return responses.SendError(c, lookupError(err, errVideoNotFound))
+	}
+
+	return h.unmarkCompleted(c, models.ProgressVideoCourse, video.ID, video.MaterialID)
+}
+
+// GetMyProgress godoc
+// @Summary Get my progress
+// @Description Retrieve the authenticated user's progress across every material they started
+// @Tags progress
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} responses.Response{data=models.ProgressSummary}
+// @Failure 401 {object} responses.Response
+// @Failure 500 {object} responses.Response
+// @Router /me/progress [get]
+func (h *ProgressHandler) GetMyProgress(c *fiber.Ctx) error {
+	userID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		return responses.SendError(c, errNoUserInToken)
+	}
+
+	return h.sendProgressSummary(c, userID)
+}
+
+// GetMyMaterialProgress godoc
+// @Summary Get my progress for a material
+// @Description Retrieve the authenticated user's progress for a specific material
+// @Tags progress
+// @Accept json
+// @Produce json
+// @Param id path int true "Material ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} responses.Response{data=models.MaterialProgress}
+// @Failure 401 {object} responses.Response
+// @Failure 404 {object} responses.Response
+// @Router /me/progress/materials/{id} [get]
+func (h *ProgressHandler) GetMyMaterialProgress(c *fiber.Ctx) error {
+	userID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		return responses.SendError(c, errNoUserInToken)
+	}
+
+	material, err := h.materials.Get(c.UserContext(), paramID(c, "id"))
+	if err != nil {
+		return responses.SendError(c, lookupError(err, errMaterialNotFound))
+	}
+
+	progress, err := h.buildMaterialProgress(c.UserContext(), userID, []models.Material{material})
+	if err != nil {
+		return responses.SendError(c, apperrors.Internal("Error calculating progress", err))
+	}
+
+	return responses.SendSuccess(c, "Progress found successfully", progress[0])
+}
+
+// GetMyCourseProgress godoc
+// @Summary Get my progress for a course
+// @Description Retrieve the authenticated user's progress across the materials of a course
+// @Tags progress
+// @Accept json
+// @Produce json
+// @Param id path int true "Course ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} responses.Response{data=models.CourseProgress}
+// @Failure 401 {object} responses.Response
+// @Failure 404 {object} responses.Response
+// @Router /me/progress/courses/{id} [get]
+func (h *ProgressHandler) GetMyCourseProgress(c *fiber.Ctx) error {
+	userID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		return responses.SendError(c, errNoUserInToken)
+	}
+
+	return h.sendCourseProgress(c, userID, paramID(c, "id"))
+}
+
+// GetUserProgress godoc
+// @Summary Get a student's progress
+// @Description Retrieve the progress of a specific user across every material they started
+// @Tags progress
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} responses.Response{data=models.ProgressSummary}
+// @Failure 401 {object} responses.Response
+// @Failure 403 {object} responses.Response
+// @Failure 404 {object} responses.Response
+// @Router /users/{id}/progress [get]
+func (h *ProgressHandler) GetUserProgress(c *fiber.Ctx) error {
+	user, err := h.users.Get(c.UserContext(), paramID(c, "id"))
+	if err != nil {
+		return responses.SendError(c, lookupError(err, errUserNotFound))
+	}
+
+	return h.sendProgressSummary(c, user.ID)
+}
+
+// GetUserCourseProgress godoc
+// @Summary Get a student's progress for a course
+// @Description Retrieve the progress of a specific user across the materials of a course
+// @Tags progress
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param course_id path int true "Course ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} responses.Response{data=models.CourseProgress}
+// @Failure 401 {object} responses.Response
+// @Failure 403 {object} responses.Response
+// @Failure 404 {object} responses.Response
+// @Router /users/{id}/progress/courses/{course_id} [get]
+func (h *ProgressHandler) GetUserCourseProgress(c *fiber.Ctx) error {
+	user, err := h.users.Get(c.UserContext(), paramID(c, "id"))
+	if err != nil {
+		return responses.SendError(c, lookupError(err, errUserNotFound))
+	}
+
+	return h.sendCourseProgress(c, user.ID, paramID(c, "course_id"))
+}
+
+// markCompleted records completion of an item and responds with the updated
+// material progress. Only items of a course the user is actively enrolled in,
+// directly or through a program, can be completed.
+func (h *ProgressHandler) markCompleted(c *fiber.Ctx, itemType models.ProgressItemType, itemID, materialID uint) error {
+	userID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		return responses.SendError(c, errNoUserInToken)
+	}
+
+	enrolled, err := h.enrollments.CoversMaterial(c.UserContext(), userID, materialID)
+	if err != nil {
+		return responses.SendError(c, apperrors.Internal("Error checking enrollment", err))
+	}
+	if !enrolled {
+		return responses.SendError(c, errNotEnrolled)
+	}
+
+	progress := models.Progress{
+		UserID:      userID,
+		ItemType:    itemType,
+		ItemID:      itemID,
+		MaterialID:  materialID,
+		CompletedAt: time.Now(),
+	}
+
+	// Completing an item twice keeps the original completion time
+	if err := h.repo.Complete(c.UserContext(), &progress); err != nil {
+		return responses.SendError(c, apperrors.Internal("Error saving progress", err))
+	}
+
+	return h.sendMaterialProgress(c, userID, materialID, "Progress saved successfully")
+}
+
+// unmarkCompleted removes the completion record of an item and responds with the updated material progress
+func (h *ProgressHandler) unmarkCompleted(c *fiber.Ctx, itemType models.ProgressItemType, itemID, materialID uint) error {
+	userID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		return responses.SendError(c, errNoUserInToken)
+	}
+
+	if err := h.repo.Uncomplete(c.UserContext(), userID, itemType, itemID); err != nil {
+		return responses.SendError(c, apperrors.Internal("Error removing progress", err))
+	}
+
+	return h.sendMaterialProgress(c, userID, materialID, "Progress removed successfully")
+}
+
+func (h *ProgressHandler) sendMaterialProgress(c *fiber.Ctx, userID, materialID uint, message string) error {
+	material, err := h.materials.Get(c.UserContext(), materialID)
+	if err != nil {
+		return responses.SendError(c, lookupError(err, errMaterialNotFound))
+	}
+
+	progress, err := h.buildMaterialProgress(c.UserContext(), userID, []models.Material{material})
+	if err != nil {
+		return responses.SendError(c, apperrors.Internal("Error calculating progress", err))
+	}
+
+	return responses.SendSuccess(c, message, progress[0])
+}
+
+func (h *ProgressHandler) sendCourseProgress(c *fiber.Ctx, userID, courseID uint) error {
+	course, err := h.courses.Get(c.UserContext(), courseID)
+	if err != nil {
+		return responses.SendError(c, lookupError(err, errCourseNotFound))
+	}
+
+	materials, err := h.materials.ListByCourse(c.UserContext(), course.ID)
+	if err != nil {
+		return responses.SendError(c, apperrors.Internal("Error fetching materials", err))
+	}
+
+	result := models.CourseProgress{CourseID: course.ID, Title: course.Title, Materials: []models.MaterialProgress{}}
+
+	if len(materials) > 0 {
+		progress, err := h.buildMaterialProgress(c.UserContext(), userID, materials)
+		if err != nil {
+			return responses.SendError(c, apperrors.Internal("Error calculating progress", err))
+		}
+		result.Materials = progress
+	}
+
+	completed, total := 0, 0
+	for _, p := range result.Materials {
+		completed += p.CompletedTopics + p.CompletedVideos
+		total += p.TotalTopics + p.TotalVideos
+	}
+	result.Percent = models.CompletionPercent(completed, total)
+
+	return responses.SendSuccess(c, "Progress found successfully", result)
+}
+
+func (h *ProgressHandler) sendProgressSummary(c *fiber.Ctx, userID uint) error {
+	materialIDs, err := h.repo.MaterialIDs(c.UserContext(), userID)
+	if err != nil {
+		return responses.SendError(c, apperrors.Internal("Error fetching progress", err))
+	}
+
+	summary := models.ProgressSummary{UserID: userID, Materials: []models.MaterialProgress{}}
+
+	if len(materialIDs) > 0 {
+		materials, err := h.materials.ListByIDs(c.UserContext(), materialIDs)
+		if err != nil {
+			return responses.SendError(c, apperrors.Internal("Error fetching materials", err))
+		}
+
+		progress, err := h.buildMaterialProgress(c.UserContext(), userID, materials)
+		if err != nil {
+			return responses.SendError(c, apperrors.Internal("Error calculating progress", err))
+		}
+		summary.Materials = progress
+	}
+
+	completed, total := 0, 0
+	for _, p := range summary.Materials {
+		completed += p.CompletedTopics + p.CompletedVideos
+		total += p.TotalTopics + p.TotalVideos
+	}
+	summary.Percent = models.CompletionPercent(completed, total)
+
+	return responses.SendSuccess(c, "Progress found successfully", summary)
+}
+
+// buildMaterialProgress computes progress for the given materials, ignoring
+// completion records whose topic or video has since been deleted
+func (h *ProgressHandler) buildMaterialProgress(ctx context.Context, userID uint, materials []models.Material) ([]models.MaterialProgress, error) {
+	materialIDs := make([]uint, 0, len(materials))
+	for _, m := range materials {
+		materialIDs = append(materialIDs, m.ID)
+	}
+
+	topics, videos, err := h.repo.Items(ctx, materialIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := h.repo.Records(ctx, userID, materialIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	completed := make(map[models.ProgressItemType]map[uint]bool)
+	for _, r := range records {
+		if completed[r.ItemType] == nil {
+			completed[r.ItemType] = make(map[uint]bool)
+		}
+		completed[r.ItemType][r.ItemID] = true
+	}
+
+	byMaterial := make(map[uint]*models.MaterialProgress, len(materials))
+	result := make([]models.MaterialProgress, len(materials))
+	for i, m := range materials {
+		result[i] = models.MaterialProgress{
+			MaterialID:        m.ID,
+			Title:             m.Title,
+			CompletedTopicIDs: []uint{},
+			CompletedVideoIDs: []uint{},
+		}
+		byMaterial[m.ID] = &result[i]
+	}
+
+	for _, t := range topics {
+		p := byMaterial[t.MaterialID]
+		p.TotalTopics++
+		if completed[models.ProgressContentTopic][t.ID] {
+			p.CompletedTopics++
+			p.CompletedTopicIDs = append(p.CompletedTopicIDs, t.ID)
+		}
+	}
+
+	for _, v := range videos {
+		p := byMaterial[v.MaterialID]
+		p.TotalVideos++
+		if completed[models.ProgressVideoCourse][v.ID] {
+			p.CompletedVideos++
+			p.CompletedVideoIDs = append(p.CompletedVideoIDs, v.ID)
+		}
+	}
+
+	for i := range result {
+		p := &result[i]
+		p.Percent = models.CompletionPercent(p.CompletedTopics+p.CompletedVideos, p.TotalTopics+p.TotalVideos)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"course-api/responses"
+	"course-api/utils/query"
+	"course-api/utils/search"
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SearchHandler serves full-text search over the in-memory index
+type SearchHandler struct {
+	index *search.Index
+}
+
+// NewSearchHandler returns a SearchHandler querying index
+func NewSearchHandler(index *search.Index) *SearchHandler {
+	return &SearchHandler{index: index}
+}
+
+// maxSearchQueryLength bounds the work a single search can cause
+const maxSearchQueryLength = 200
+
+// Search godoc
+// @Summary Search courses, materials and content topics
+// @Description Full-text search over course titles and descriptions, material titles, descriptions and learning points, and content topic titles, topics and text. Hits are ranked by relevance and matched words are wrapped in <mark> in the snippet.
+// @Tags search
+// @Accept json
+// @Produce json
+// @Param q query string true "Search terms; the last term also matches as a prefix"
+// @Param type query string false "Comma separated types to include (course, material, content_topic)"
+// @Param page query int false "Page number, starting at 1"
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Security ApiKeyAuth
+// @Success 200 {object} responses.Response{data=[]search.Hit,meta=query.Meta}
+// @Failure 400 {object} responses.Response
+// @Failure 401 {object} responses.Response
+// @Router /search [get]
+func (h *SearchHandler) Search(c *fiber.Ctx) error {
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		return responses.SendError(c, invalidQuery("q", "is required"))
+	}
+	if len(q) > maxSearchQueryLength {
+		return responses.SendError(c, invalidQuery("q", fmt.Sprintf("must be at most %d characters long", maxSearchQueryLength)))
+	}
+
+	var types []string
+	for _, t := range strings.Split(c.Query("type"), ",") {
+		switch t = strings.TrimSpace(t); t {
+		case "":
+		case search.TypeCourse, search.TypeMaterial, search.TypeContentTopic:
+			types = append(types, t)
+		default:
+			return responses.SendError(c, invalidQuery("type", "must be course, material or content_topic"))
+		}
+	}
+
+	// Only page and limit apply; hits are always ranked by relevance
+	params, err := query.Parse(c, query.Options{})
+	if err != nil {
+		return responses.SendError(c, queryError(err, "Error searching"))
+	}
+
+	hits := h.index.Search(q, types...)
+	total := len(hits)
+	meta := query.Meta{
+		Page:       params.Page,
+		Limit:      params.Limit,
+		Total:      int64(total),
+		TotalPages: (total + params.Limit - 1) / params.Limit,
+	}
+
+	from := min((params.Page-1)*params.Limit, total)
+	to := min(from+params.Limit, total)
+	meta.HasMore = to < total
+
+	page := hits[from:to]
+	if page == nil {
+		page = []search.Hit{}
+	}
+	return responses.SendList(c, "Search results found successfully", page, meta)
+}
@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"context"
+	"course-api/apperrors"
+	"course-api/middleware"
+	"course-api/models"
+	"course-api/repository"
+	"course-api/responses"
+	"course-api/utils/query"
+	"course-api/validator"
+	"log/slog"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// UserHandler serves the admin endpoints that manage user accounts
+type UserHandler struct {
+	users    repository.UserRepository
+	tokens   *middleware.TokenService
+	validate *validator.Validator
+}
+
+// NewUserHandler returns a UserHandler; tokens revokes the sessions of changed users
+func NewUserHandler(users repository.UserRepository, tokens *middleware.TokenService, validate *validator.Validator) *UserHandler {
+	return &UserHandler{users: users, tokens: tokens, validate: validate}
+}
+
+// userListOptions lists the sort keys and filters accepted by GetAllUsers
+var userListOptions = query.Options{
+	Sortable: map[string]string{
+		"id":         "id",
+		"email":      "email",
+		"full_name":  "full_name",
+		"role":       "role",
+		"created_at": "created_at",
+	},
+	DefaultSort: "id",
+	Filters: []query.Filter{
+		query.Custom("q", func(db *gorm.DB, value string) *gorm.DB {
+			like := "%" + strings.ToLower(strings.TrimSpace(value)) + "%"
+			return db.Where("LOWER(email) LIKE ? OR LOWER(full_name) LIKE ?", like, like)
+		}),
+		query.OneOf("role", "role", string(models.RoleAdmin), string(models.RoleStudent), string(models.RoleMentor)),
+		query.Bool("active", "is_active"),
+		query.Bool("email_verified", "email_verified"),
+	},
+}
+
+// GetAllUsers godoc
+// @Summary List users
+// @Description List users, optionally searching by email or name and filtering by role or status
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param page query int false "Page number, starting at 1"
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Param cursor query string false "Cursor from meta.next_cursor; send empty to start cursor pagination"
+// @Param sort query string false "Comma separated sort keys, prefix with - for descending, e.g. -created_at"
+// @Param q query string false "Search in email and full name"
+// @Param role query string false "Filter by role (admin, student, mentor)"
+// @Param active query bool false "Filter by active status"
+// @Param email_verified query bool false "Filter by email verification"
+// @Security ApiKeyAuth
+// @Success 200 {object} responses.Response{data=[]models.User,meta=query.Meta}
+// @Failure 400 {object} responses.Response
+// @Failure 401 {object} responses.Response
+// @Failure 403 {object} responses.Response
+// @Failure 500 {object} responses.Response
+// @Router /users [get]
+func (h *UserHandler) GetAllUsers(c *fiber.Ctx) error {
+	return sendList(c, userListOptions, "Users", h.users.List)
+}
+
+// GetUser godoc
+// @Summary Get a user by ID
+// @Description Retrieve a specific user by its ID
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} responses.Response{data=models.User}
+// @Failure 401 {object} responses.Response
+// @Failure 403 {object} responses.Response
+// @Failure 404 {object} responses.Response
+// @Router /users/{id} [get]
+func (h *UserHandler) GetUser(c *fiber.Ctx) error {
+	user, err := h.users.Get(c.UserContext(), paramID(c, "id"))
+	if err != nil {
+		return responses.SendError(c, lookupError(err, errUserNotFound))
+	}
+
+	return responses.SendSuccess(c, "User found successfully", user)
+}
+
+// UpdateUserRole godoc
+// @Summary Change a user's role
+// @Description Promote or demote a user. The user's sessions are revoked so the new role applies on next sign in.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param input body models.UpdateUserRoleInput true "New role"
+// @Security ApiKeyAuth
+// @Success 200 {object} responses.Response{data=models.User}
+// @Failure 400 {object} responses.Response
+// @Failure 401 {object} responses.Response
+// @Failure 403 {object} responses.Response
+// @Failure 404 {object} responses.Response
+// @Router /users/{id}/role [put]
+func (h *UserHandler) UpdateUserRole(c *fiber.Ctx) error {
+	input := new(models.UpdateUserRoleInput)
+
+	if err := c.BodyParser(input); err != nil {
+		return responses.SendError(c, apperrors.InvalidInput(err))
+	}
+
+	if err := h.validate.Struct(input); err != nil {
+		return responses.SendError(c, apperrors.Validation(err))
+	}
+
+	user, err := h.users.Get(c.UserContext(), paramID(c, "id"))
+	if err != nil {
+		return responses.SendError(c, lookupError(err, errUserNotFound))
+	}
+
+	if user.Role == input.Role {
+		return responses.SendSuccess(c, "User role unchanged", user)
+	}
+
+	if user.Role == models.RoleAdmin && h.isLastActiveAdmin(c.UserContext(), user.ID) {
+		return responses.SendError(c, apperrors.BadRequest(apperrors.CodeLastAdmin, "Cannot demote the last active admin"))
+	}
+
+	if err := h.users.UpdateRole(c.UserContext(), user.ID, input.Role); err != nil {
+		return responses.SendError(c, apperrors.Internal("Error updating user role", err))
+	}
+	user.Role = input.Role
+
+	h.revokeSessions(c.UserContext(), user.ID)
+
+	return responses.SendSuccess(c, "User role updated successfully", user)
+}
+
+// UpdateUserStatus godoc
+// @Summary Activate or deactivate a user
+// @Description Deactivated users cannot sign in and their sessions are revoked immediately
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param input body models.UpdateUserStatusInput true "New status"
+// @Security ApiKeyAuth
+// @Success 200 {object} responses.Response{data=models.User}
+// @Failure 400 {object} responses.Response
+// @Failure 401 {object} responses.Response
+// @Failure 403 {object} responses.Response
+// @Failure 404 {object} responses.Response
+// @Router /users/{id}/status [put]
+func (h *UserHandler) UpdateUserStatus(c *fiber.Ctx) error {
+	input := new(models.UpdateUserStatusInput)
+
+	if err := c.BodyParser(input); err != nil {
+		return responses.SendError(c, apperrors.InvalidInput(err))
+	}
+
+	if err := h.validate.Struct(input); err != nil {
+		return responses.SendError(c, apperrors.Validation(err))
+	}
+
+	user, err := h.users.Get(c.UserContext(), paramID(c, "id"))
+	if err != nil {
+		return responses.SendError(c, lookupError(err, errUserNotFound))
+	}
+
+	if !*input.IsActive {
+		if currentUserID, _ := middleware.CurrentUserID(c); currentUserID == user.ID {
+			return responses.SendError(c, apperrors.BadRequest(apperrors.CodeSelfDeactivation, "Cannot deactivate your own account"))
+		}
+		if user.Role == models.RoleAdmin && h.isLastActiveAdmin(c.UserContext(), user.ID) {
+			return responses.SendError(c, apperrors.BadRequest(apperrors.CodeLastAdmin, "Cannot deactivate the last active admin"))
+		}
+	}
+
+	if err := h.users.UpdateStatus(c.UserContext(), user.ID, *input.IsActive); err != nil {
+		return responses.SendError(c, apperrors.Internal("Error updating user status", err))
+	}
+	user.IsActive = *input.IsActive
+
+	if !user.IsActive {
+		h.revokeSessions(c.UserContext(), user.ID)
+	}
+
+	return responses.SendSuccess(c, "User status updated successfully", user)
+}
+
+// ResetUserPassword godoc
+// @Summary Reset a user's password
+// @Description Set a new password for a user and revoke all of their sessions
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param input body models.ResetUserPasswordInput true "New password"
+// @Security ApiKeyAuth
+// @Success 200 {object} responses.Response
+// @Failure 400 {object} responses.Response
+// @Failure 401 {object} responses.Response
+// @Failure 403 {object} responses.Response
+// @Failure 404 {object} responses.Response
+// @Router /users/{id}/password [put]
+func (h *UserHandler) ResetUserPassword(c *fiber.Ctx) error {
+	input := new(models.ResetUserPasswordInput)
+
+	if err := c.BodyParser(input); err != nil {
+		return responses.SendError(c, apperrors.InvalidInput(err))
+	}
+
+	if err := h.validate.Struct(input); err != nil {
+		return responses.SendError(c, apperrors.Validation(err))
+	}
+
+	user, err := h.users.Get(c.UserContext(), paramID(c, "id"))
+	if err != nil {
+		return responses.SendError(c, lookupError(err, errUserNotFound))
+	}
+
+	user.Password = input.Password
+	if err := user.HashPassword(); err != nil {
+		return responses.SendError(c, apperrors.Internal("Error resetting password", err))
+	}
+
+	if err := h.users.UpdatePassword(c.UserContext(), user.ID, user.Password); err != nil {
+		return responses.SendError(c, apperrors.Internal("Error resetting password", err))
+	}
+
+	h.revokeSessions(c.UserContext(), user.ID)
+
+	return responses.SendSuccess(c, "Password reset successfully", nil)
+}
+
+// isLastActiveAdmin reports whether the user is the only remaining active admin
+func (h *UserHandler) isLastActiveAdmin(ctx context.Context, userID uint) bool {
+	others, err := h.users.CountOtherActiveAdmins(ctx, userID)
+	// Err on the side of keeping an admin when the count is unknown
+	return err != nil || others == 0
+}
+
+// revokeSessions signs the user out everywhere; failures are logged since the change itself succeeded
+func (h *UserHandler) revokeSessions(ctx context.Context, userID uint) {
+	if err := h.tokens.RevokeUserTokens(ctx, userID); err != nil {
+		slog.ErrorContext(ctx, "Error revoking sessions", "account_id", userID, "error", err)
+	}
+}
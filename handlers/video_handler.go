@@ -0,0 +1,333 @@
+package handlers
+
+import (
+	"context"
+	"course-api/apperrors"
+	"course-api/models"
+	"course-api/repository"
+	"course-api/responses"
+	"course-api/utils/cache"
+	"course-api/utils/query"
+	"course-api/validator"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// VideoHandler serves the video course endpoints
+type VideoHandler struct {
+	repo      repository.VideoRepository
+	materials repository.MaterialRepository
+	cache     *cache.Cache
+	validate  *validator.Validator
+	cacheTTL  time.Duration
+}
+
+// NewVideoHandler returns a VideoHandler that caches video reads for cacheTTL
+func NewVideoHandler(repo repository.VideoRepository, materials repository.MaterialRepository, responseCache *cache.Cache, validate *validator.Validator, cacheTTL time.Duration) *VideoHandler {
+	return &VideoHandler{repo: repo, materials: materials, cache: responseCache, validate: validate, cacheTTL: cacheTTL}
+}
+
+// videoListOptions lists the sort keys and filters accepted by video lists
+var videoListOptions = query.Options{
+	Sortable: map[string]string{
+		"id":          "id",
+		"order":       "order",
+		"title":       "title",
+		"material_id": "material_id",
+		"created_at":  "created_at",
+		"updated_at":  "updated_at",
+	},
+	DefaultSort: "material_id,order",
+	Filters: []query.Filter{
+		query.Equals("material_id", "material_id"),
+		query.OneOf("level", "level", "beginner", "intermediate", "advanced"),
+		query.Contains("q", "title"),
+		query.Contains("instructor", "instructor"),
+	},
+}
+
+// GetVideos godoc
+// @Summary List video courses
+// @Description List video courses, optionally filtered by material or level
+// @Tags videos
+// @Accept json
+// @Produce json
+// @Param material_id query int false "Filter by material"
+// @Param level query string false "Filter by level (beginner, intermediate, advanced)"
+// @Param q query string false "Search in title"
+// @Param instructor query string false "Search in instructor"
+// @Param page query int false "Page number, starting at 1"
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Param sort query string false "Comma separated sort keys (default material_id,order)"
+// @Security ApiKeyAuth
+// @Success 200 {object} responses.Response{data=[]models.VideoCourse,meta=query.Meta}
+// @Failure 400 {object} responses.Response
+// @Failure 401 {object} responses.Response
+// @Router /videos [get]
+func (h *VideoHandler) GetVideos(c *fiber.Ctx) error {
+	entry := cache.Entry{Key: "videos", TTL: h.cacheTTL, Tags: []string{tagVideos}}
+	return sendCachedList(c, h.cache, entry, videoListOptions, "Videos", h.repo.List)
+}
+
+// GetMaterialVideos godoc
+// @Summary List the videos of a material
+// @Description Retrieve the video courses of a material in their display order
+// @Tags videos
+// @Accept json
+// @Produce json
+// @Param material_id path int true "Material ID"
+// @Param level query string false "Filter by level (beginner, intermediate, advanced)"
+// @Param page query int false "Page number, starting at 1"
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Param sort query string false "Comma separated sort keys (default order)"
+// @Security ApiKeyAuth
+// @Success 200 {object} responses.Response{data=[]models.VideoCourse,meta=query.Meta}
+// @Failure 401 {object} responses.Response
+// @Failure 404 {object} responses.Response
+// @Router /videos/material/{material_id} [get]
+func (h *VideoHandler) GetMaterialVideos(c *fiber.Ctx) error {
+	materialID := paramID(c, "material_id")
+	opts := videoListOptions
+	opts.DefaultSort = "order"
+
+	// The material lookup is part of the cached read; a missing material is not cached
+	entry := cache.Entry{Key: fmt.Sprintf("videos:material:%d", materialID), TTL: h.cacheTTL, Tags: []string{itemTag("material", materialID)}}
+	return sendCachedList(c, h.cache, entry, opts, "Videos", func(ctx context.Context, params *query.Params) ([]models.VideoCourse, query.Meta, error) {
+		if _, err := h.materials.Get(ctx, materialID); err != nil {
+			return nil, query.Meta{}, lookupError(err, errMaterialNotFound)
+		}
+		return h.repo.ListByMaterial(ctx, materialID, params)
+	})
+}
+
+// GetVideo godoc
+// @Summary Get a video course
+// @Description Retrieve a specific video course by its ID
+// @Tags videos
+// @Accept json
+// @Produce json
+// @Param id path int true "Video ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} responses.Response{data=models.VideoCourse}
+// @Failure 401 {object} responses.Response
+// @Failure 404 {object} responses.Response
+// @Router /videos/{id} [get]
+func (h *VideoHandler) GetVideo(c *fiber.Ctx) error {
+	id := paramID(c, "id")
+	entry := cache.Entry{Key: fmt.Sprintf("videos:%d", id), TTL: h.cacheTTL, Tags: []string{itemTag("video", id), tagVideos}}
+
+	video, hit, err := cache.GetOrSet(c.UserContext(), h.cache, entry, func(ctx context.Context) (models.VideoCourse, error) {
+		return h.repo.Get(ctx, id)
+	})
+	if err != nil {
+		return responses.SendError(c, lookupError(err, errVideoNotFound))
+	}
+
+	return responses.SendValidated(c, found("Video", hit), video, nil)
+}
+
+// CreateVideo godoc
+// @Summary Create a video course
+// @Description Add a video to a material. Without an order the video is appended after the existing ones.
+// @Tags videos
+// @Accept json
+// @Produce json
+// @Param input body models.CreateVideoCourseInput true "Video details"
+// @Security ApiKeyAuth
+// @Success 200 {object} responses.Response{data=models.VideoCourse}
+// @Failure 400 {object} responses.Response
+// @Failure 401 {object} responses.Response
+// @Failure 404 {object} responses.Response
+// @Router /videos [post]
+func (h *VideoHandler) CreateVideo(c *fiber.Ctx) error {
+	input := new(models.CreateVideoCourseInput)
+
+	if err := c.BodyParser(input); err != nil {
+		return responses.SendError(c, apperrors.InvalidInput(err))
+	}
+
+	if err := h.validate.Struct(input); err != nil {
+		return responses.SendError(c, apperrors.Validation(err))
+	}
+
+	if _, err := h.materials.Get(c.UserContext(), input.MaterialID); err != nil {
+		return responses.SendError(c, lookupError(err, errMaterialNotFound))
+	}
+
+	video := models.VideoCourse{
+		Title:       input.Title,
+		Description: input.Description,
+		YoutubeID:   input.YoutubeID,
+		Duration:    input.Duration,
+		Instructor:  input.Instructor,
+		Level:       input.Level,
+		MaterialID:  input.MaterialID,
+	}
+	if input.Order != nil {
+		video.Order = *input.Order
+	} else {
+		order, err := h.repo.NextOrder(c.UserContext(), input.MaterialID)
+		if err != nil {
+			return responses.SendError(c, apperrors.Internal("Error creating video", err))
+		}
+		video.Order = order
+	}
+
+	if err := h.repo.Create(c.UserContext(), &video); err != nil {
+		return responses.SendError(c, apperrors.Internal("Error creating video", err))
+	}
+	h.invalidateVideo(c.UserContext(), video)
+
+	return responses.SendSuccess(c, "Video created successfully", video)
+}
+
+// UpdateVideo godoc
+// @Summary Update a video course
+// @Description Update a video's details, or move it to another material by setting material_id
+// @Tags videos
+// @Accept json
+// @Produce json
+// @Param id path int true "Video ID"
+// @Param input body models.UpdateVideoCourseInput true "Video update details"
+// @Security ApiKeyAuth
+// @Success 200 {object} responses.Response{data=models.VideoCourse}
+// @Failure 400 {object} responses.Response
+// @Failure 401 {object} responses.Response
+// @Failure 404 {object} responses.Response
+// @Router /videos/{id} [put]
+func (h *VideoHandler) UpdateVideo(c *fiber.Ctx) error {
+	input := new(models.UpdateVideoCourseInput)
+
+	if err := c.BodyParser(input); err != nil {
+		return responses.SendError(c, apperrors.InvalidInput(err))
+	}
+
+	if err := h.validate.Struct(input); err != nil {
+		return responses.SendError(c, apperrors.Validation(err))
+	}
+
+	video, err := h.repo.Get(c.UserContext(), paramID(c, "id"))
+	if err != nil {
+		return responses.SendError(c, lookupError(err, errVideoNotFound))
+	}
+	previousMaterialID := video.MaterialID
+
+	if input.Title != "" {
+		video.Title = input.Title
+	}
+	if input.Description != "" {
+		video.Description = input.Description
+	}
+	if input.YoutubeID != "" {
+		video.YoutubeID = input.YoutubeID
+	}
+	if input.Duration != "" {
+		video.Duration = input.Duration
+	}
+	if input.Instructor != "" {
+		video.Instructor = input.Instructor
+	}
+	if input.Level != "" {
+		video.Level = input.Level
+	}
+	if input.MaterialID != 0 && input.MaterialID != video.MaterialID {
+		if _, err := h.materials.Get(c.UserContext(), input.MaterialID); err != nil {
+			return responses.SendError(c, lookupError(err, errMaterialNotFound))
+		}
+		order, err := h.repo.NextOrder(c.UserContext(), input.MaterialID)
+		if err != nil {
+			return responses.SendError(c, apperrors.Internal("Error updating video", err))
+		}
+		video.MaterialID = input.MaterialID
+		video.Order = order
+	}
+	if input.Order != nil {
+		video.Order = *input.Order
+	}
+
+	if err := h.repo.Update(c.UserContext(), &video); err != nil {
+		return responses.SendError(c, apperrors.Internal("Error updating video", err))
+	}
+	// A moved video also leaves its previous material
+	h.invalidateVideo(c.UserContext(), video, previousMaterialID)
+
+	return responses.SendSuccess(c, "Video updated successfully", video)
+}
+
+// DeleteVideo godoc
+// @Summary Delete a video course
+// @Description Delete a video course by its ID
+// @Tags videos
+// @Accept json
+// @Produce json
+// @Param id path int true "Video ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} responses.Response
+// @Failure 401 {object} responses.Response
+// @Failure 404 {object} responses.Response
+// @Router /videos/{id} [delete]
+func (h *VideoHandler) DeleteVideo(c *fiber.Ctx) error {
+	video, err := h.repo.Get(c.UserContext(), paramID(c, "id"))
+	if err != nil {
+		return responses.SendError(c, lookupError(err, errVideoNotFound))
+	}
+
+	if err := h.repo.Delete(c.UserContext(), &video); err != nil {
+		return responses.SendError(c, apperrors.Internal("Error deleting video", err))
+	}
+	h.invalidateVideo(c.UserContext(), video)
+
+	return responses.SendSuccess(c, "Video deleted successfully", nil)
+}
+
+// ReorderMaterialVideos godoc
+// @Summary Reorder the videos of a material
+// @Description Set the display order of a material's videos. ids must list every video of the material exactly once.
+// @Tags videos
+// @Accept json
+// @Produce json
+// @Param material_id path int true "Material ID"
+// @Param input body models.ReorderInput true "Video IDs in their new order"
+// @Security ApiKeyAuth
+// @Success 200 {object} responses.Response{data=[]models.VideoCourse}
+// @Failure 400 {object} responses.Response
+// @Failure 401 {object} responses.Response
+// @Failure 404 {object} responses.Response
+// @Router /videos/material/{material_id}/order [put]
+func (h *VideoHandler) ReorderMaterialVideos(c *fiber.Ctx) error {
+	input := new(models.ReorderInput)
+
+	if err := c.BodyParser(input); err != nil {
+		return responses.SendError(c, apperrors.InvalidInput(err))
+	}
+
+	if err := h.validate.Struct(input); err != nil {
+		return responses.SendError(c, apperrors.Validation(err))
+	}
+
+	material, err := h.materials.Get(c.UserContext(), paramID(c, "material_id"))
+	if err != nil {
+		return responses.SendError(c, lookupError(err, errMaterialNotFound))
+	}
+
+	videos, err := h.repo.Reorder(c.UserContext(), material.ID, input.IDs)
+	if err != nil {
+		return sendReorderError(c, err)
+	}
+	// Every video of the material changed its order
+	invalidate(c.UserContext(), h.cache, itemTag("material", material.ID), tagVideos, tagMaterials, tagCurricula)
+
+	return responses.SendSuccess(c, "Videos reordered successfully", videos)
+}
+
+// invalidateVideo drops the cached video, the video listing and the entries embedding
+// the video: its materials, the material listing and the curricula
+func (h *VideoHandler) invalidateVideo(ctx context.Context, video models.VideoCourse, materialIDs ...uint) {
+	tags := []string{itemTag("video", video.ID), itemTag("material", video.MaterialID), tagVideos, tagMaterials, tagCurricula}
+	for _, id := range materialIDs {
+		tags = append(tags, itemTag("material", id))
+	}
+	invalidate(ctx, h.cache, tags...)
+}
@@ -1,46 +1,118 @@
 package main
 
 import (
+	"context"
 	"course-api/config"
+	"course-api/migrations"
+	"course-api/responses"
 	"course-api/routes"
-	"log"
+	"course-api/utils/cache"
+	"course-api/utils/logging"
+	"course-api/utils/mailer"
+	"course-api/utils/metrics"
+	"course-api/utils/search"
+	"course-api/utils/tracing"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
 	_ "course-api/docs" // Import swagger docs
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
-	"github.com/joho/godotenv"
 )
 
 func main() {
 
-	err := godotenv.Load()
+	settings, err := config.Load()
 	if err != nil {
-		log.Fatal("Error loading .env file")
+		fatal("Invalid configuration", err)
 	}
 
-	log.Println("Starting Course API server...")
+	logger, err := logging.New(os.Stdout, settings.Log.Level, settings.Log.Format)
+	if err != nil {
+		fatal("Invalid logging configuration", err)
+	}
+	slog.SetDefault(logger)
+
+	shutdownTracing, err := tracing.Setup(context.Background(), settings.Tracing, settings.Env)
+	if err != nil {
+		fatal("Failed to set up tracing", err)
+	}
+	defer func() {
+		// Flush the spans of the last requests
+		ctx, cancel := context.WithTimeout(context.Background(), settings.ShutdownTimeout)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("Error flushing traces", "error", err)
+		}
+	}()
+
+	slog.Info("Starting Course API server")
 
 	// Initialize database
-	log.Println("Initializing database connection...")
-	config.ConnectDB()
-	log.Println("Database connection initialized successfully")
+	slog.Info("Initializing database connection")
+	config.ConnectDB(settings.Database)
+	defer config.CloseDB()
+	slog.Info("Database connection initialized successfully")
+
+	// Run database migrations from the command line: main migrate up|down|status
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrateCommand(config.DB, os.Args[2:]); err != nil {
+			fatal("Migration failed", err)
+		}
+		return
+	}
+
+	if settings.Database.MigrateOnStart {
+		slog.Info("Applying database migrations")
+		if _, err := migrations.Up(config.DB); err != nil {
+			fatal("Failed to run migrations", err)
+		}
+		slog.Info("Database migrations applied successfully")
+	} else if pending, err := migrations.Pending(config.DB); err != nil {
+		slog.Warn("Could not check migrations", "error", err)
+	} else if pending > 0 {
+		slog.Warn("Pending migrations, run `main migrate up`", "count", pending)
+	}
+
+	// Time database queries from here on
+	appMetrics := metrics.New()
+	if err := config.DB.Use(appMetrics.GormPlugin()); err != nil {
+		fatal("Failed to install database metrics", err)
+	}
+	if err := config.DB.Use(tracing.GormPlugin()); err != nil {
+		fatal("Failed to install database tracing", err)
+	}
 
 	// Initialize Redis connection
-	log.Println("Initializing Redis connection...")
-	config.ConnectRedis()
+	slog.Info("Initializing Redis connection")
+	config.ConnectRedis(settings.Redis)
 	defer config.CloseRedis()
-	log.Println("Redis connection established")
+	if err := tracing.InstrumentRedis(config.RedisClient); err != nil {
+		fatal("Failed to install Redis tracing", err)
+	}
+	slog.Info("Redis connection established")
+
+	// Stopped before the Redis connection is closed
+	responseCache := cache.New(config.RedisClient, settings.Cache, appMetrics)
+	defer responseCache.Close()
+
+	// Build the search index from the database
+	index := search.NewIndex()
+	if err := index.Rebuild(config.DB); err != nil {
+		slog.Warn("Search index not built", "error", err)
+	}
 
 	// Create Fiber app
-	log.Println("Creating Fiber application...")
+	slog.Info("Creating Fiber application")
 	app := fiber.New(fiber.Config{
-		ErrorHandler: func(c *fiber.Ctx, err error) error {
-			log.Printf("Error occurred: %v\n", err)
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": err.Error(),
-			})
-		},
+		// Errors returned by handlers and fiber's own, such as unknown routes,
+		// are answered with the same envelope as every other response
+		ErrorHandler: responses.ErrorHandler,
 	})
 
 	// Middleware
@@ -48,21 +120,56 @@ func main() {
 	// app.Use(cors.New())
 
 	// ✅ Tambahkan middleware CORS di sini
-	log.Println("Setting up CORS middleware...")
+	slog.Info("Setting up CORS middleware")
 	app.Use(cors.New(cors.Config{
-		AllowOrigins: "*", // Bisa diganti dengan domain frontend
-		AllowMethods: "GET,POST,PUT,DELETE,OPTIONS",
-		AllowHeaders: "Origin, Content-Type, Accept, Authorization",
+		AllowOrigins:  strings.Join(settings.CORS.AllowOrigins, ","),
+		AllowMethods:  "GET,POST,PUT,DELETE,OPTIONS",
+		AllowHeaders:  "Origin, Content-Type, Accept, Authorization, X-Request-ID, traceparent, tracestate, If-None-Match, If-Modified-Since",
+		ExposeHeaders: "X-Request-ID, ETag, Last-Modified",
 	}))
 
 	// Setup routes
-	log.Println("Setting up routes...")
-	routes.SetupRoutes(app)
-	log.Println("Routes configured successfully")
+	slog.Info("Setting up routes")
+	routes.SetupRoutes(app, routes.Dependencies{
+		DB:       config.DB,
+		Redis:    config.RedisClient,
+		Cache:    responseCache,
+		Settings: settings,
+		Search:   index,
+		Mailer:   mailer.New(settings.Mail),
+		Metrics:  appMetrics,
+	})
+	slog.Info("Routes configured successfully")
+
+	// Stop on SIGTERM (sent by the platform on redeploy) or SIGINT
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
 	// Start server
-	log.Println("Starting server on 0.0.0.0:3000...")
-	if err := app.Listen(":3000"); err != nil {
-		log.Fatal("Failed to start server: ", err)
+	addr := fmt.Sprintf(":%d", settings.Port)
+	listenErr := make(chan error, 1)
+	go func() {
+		slog.Info("Starting server", "addr", "0.0.0.0"+addr)
+		listenErr <- app.Listen(addr)
+	}()
+
+	select {
+	case err := <-listenErr:
+		fatal("Failed to start server", err)
+	case <-ctx.Done():
+	}
+	stop()
+
+	// Finish in-flight requests before the deferred database and Redis closes run
+	slog.Info("Shutting down, waiting for in-flight requests", "timeout", settings.ShutdownTimeout.String())
+	if err := app.ShutdownWithTimeout(settings.ShutdownTimeout); err != nil {
+		slog.Error("Error during shutdown", "error", err)
 	}
+	slog.Info("Server stopped")
+}
+
+// fatal logs err and exits; like log.Fatal, deferred closes do not run
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
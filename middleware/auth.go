@@ -1,62 +1,72 @@
 package middleware
 
 import (
+	"course-api/apperrors"
 	"course-api/models"
 	"course-api/responses"
-	"fmt"
-	"os"
+	"course-api/utils/logging"
 	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
 type TokenClaims struct {
-	UserID uint        `json:"user_id"`
-	Role   models.Role `json:"role"`
+	UserID   uint        `json:"user_id"`
+	Role     models.Role `json:"role"`
+	FamilyID string      `json:"fid,omitempty"` // Refresh token family the access token belongs to
 	jwt.RegisteredClaims
 }
 
-// GenerateToken generates a new JWT token for a given user ID and role
-func GenerateToken(userID uint, role models.Role) (string, error) {
+// GenerateToken generates a new short-lived access token for a given user ID, role and token family
+func (s *TokenService) GenerateToken(userID uint, role models.Role, familyID string) (string, error) {
 	claims := TokenClaims{
 		userID,
 		role,
+		familyID,
 		jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+			ID:        uuid.NewString(),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(s.AccessTokenTTL())),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	return token.SignedString([]byte(s.settings.JWTSecret))
 }
 
-func Protected() fiber.Handler {
+func (s *TokenService) Protected() fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		authHeader := c.Get("Authorization")
 		if authHeader == "" {
-			return responses.SendError(c, fiber.StatusUnauthorized, "Missing authorization header")
+			return responses.SendError(c, apperrors.Unauthorized(apperrors.CodeMissingToken, "Missing authorization header"))
 		}
 
 		tokenString := strings.Replace(authHeader, "Bearer ", "", 1)
 		token, err := jwt.ParseWithClaims(tokenString, &TokenClaims{}, func(token *jwt.Token) (interface{}, error) {
-			return []byte(os.Getenv("JWT_SECRET")), nil
+			return []byte(s.settings.JWTSecret), nil
 		})
 
 		if err != nil {
-			return responses.SendError(c, fiber.StatusUnauthorized, "Invalid token")
+			return responses.SendError(c, apperrors.Unauthorized(apperrors.CodeInvalidToken, "Invalid token"))
 		}
 
 		claims, ok := token.Claims.(*TokenClaims)
 		if !ok || !token.Valid {
-			return responses.SendError(c, fiber.StatusUnauthorized, "Invalid token claims")
+			return responses.SendError(c, apperrors.Unauthorized(apperrors.CodeInvalidToken, "Invalid token claims"))
+		}
+
+		if claims.FamilyID != "" && s.IsFamilyRevoked(c.UserContext(), claims.FamilyID) {
+			return responses.SendError(c, apperrors.Unauthorized(apperrors.CodeTokenRevoked, "Token has been revoked"))
 		}
 
 		c.Locals("user_id", claims.UserID)
-		fmt.Println(claims.Role)
 		c.Locals("user_role", string(claims.Role))
+		if info := logging.FromContext(c.UserContext()); info != nil {
+			info.SetUserID(claims.UserID)
+		}
 		return c.Next()
 	}
 }
@@ -66,12 +76,12 @@ func RequireRole(roles ...models.Role) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		roleStr := c.Locals("user_role")
 		if roleStr == nil {
-			return responses.SendError(c, fiber.StatusUnauthorized, "User role not found")
+			return responses.SendError(c, apperrors.Unauthorized(apperrors.CodeInvalidToken, "User role not found"))
 		}
 
 		userRole := models.Role(roleStr.(string))
 		if userRole == "" {
-			return responses.SendError(c, fiber.StatusUnauthorized, "Invalid user role")
+			return responses.SendError(c, apperrors.Unauthorized(apperrors.CodeInvalidToken, "Invalid user role"))
 		}
 
 		for _, role := range roles {
@@ -80,6 +90,18 @@ func RequireRole(roles ...models.Role) fiber.Handler {
 			}
 		}
 
-		return responses.SendError(c, fiber.StatusForbidden, "Insufficient permissions")
+		return responses.SendError(c, apperrors.Forbidden(apperrors.CodeInsufficientPermissions, "Insufficient permissions"))
 	}
 }
+
+// CurrentUserID returns the authenticated user ID stored by Protected
+func CurrentUserID(c *fiber.Ctx) (uint, bool) {
+	userID, ok := c.Locals("user_id").(uint)
+	return userID, ok
+}
+
+// CurrentUserRole returns the authenticated user role stored by Protected
+func CurrentUserRole(c *fiber.Ctx) models.Role {
+	role, _ := c.Locals("user_role").(string)
+	return models.Role(role)
+}
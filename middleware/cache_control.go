@@ -0,0 +1,15 @@
+package middleware
+
+import "github.com/gofiber/fiber/v2"
+
+// CacheControl sets the Cache-Control header of the responses that succeed or
+// are not modified, leaving errors to the client's defaults
+func CacheControl(directives string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		err := c.Next()
+		if status := c.Response().StatusCode(); err == nil && status < fiber.StatusBadRequest {
+			c.Set(fiber.HeaderCacheControl, directives)
+		}
+		return err
+	}
+}
@@ -1,12 +1,38 @@
 package middleware
 
 import (
+	"log/slog"
+	"time"
+
 	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/middleware/logger"
 )
 
+// LoggerMiddleware writes one structured access log record per request, tagged with
+// the request attributes set by RequestID. Errors returned by handlers are turned
+// into their response first so the logged status is the one sent.
 func LoggerMiddleware() fiber.Handler {
-	return logger.New(logger.Config{
-		Format: "[${time}] ${status} - ${method} ${path}\n",
-	})
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+
+		if err := c.Next(); err != nil {
+			if err := c.App().ErrorHandler(c, err); err != nil {
+				_ = c.SendStatus(fiber.StatusInternalServerError)
+			}
+		}
+
+		status := c.Response().StatusCode()
+		level := slog.LevelInfo
+		if status >= fiber.StatusInternalServerError {
+			level = slog.LevelError
+		}
+
+		slog.Log(c.UserContext(), level, "request",
+			"path", c.Path(),
+			"status", status,
+			"latency_ms", float64(time.Since(start).Microseconds())/1000,
+			"ip", c.IP(),
+			"bytes", len(c.Response().Body()),
+		)
+		return nil
+	}
 }
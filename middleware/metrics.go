@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"course-api/utils/metrics"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// MetricsMiddleware records the count and latency of every request, labelled with
+// the matched route pattern such as /api/v1/courses/:id rather than the raw path
+func MetricsMiddleware(m *metrics.Metrics) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		err := c.Next()
+
+		// Paths without a route are labelled with the prefix of the last middleware
+		// they went through, so unknown paths never create a series each. The method
+		// is copied because fiber reuses its buffer for the next request.
+		m.ObserveRequest(strings.Clone(c.Method()), c.Route().Path, responseStatus(c, err), time.Since(start))
+		return err
+	}
+}
+
+// responseStatus is the status code of the response to c. Errors returned by
+// handlers are only turned into a response by the app's error handler, after
+// the middleware before it has returned, so their status is derived from err.
+func responseStatus(c *fiber.Ctx, err error) int {
+	if err == nil {
+		return c.Response().StatusCode()
+	}
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		return fiberErr.Code
+	}
+	return fiber.StatusInternalServerError
+}
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"course-api/utils/logging"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader carries the request ID in requests and responses
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds client supplied request IDs
+const maxRequestIDLength = 128
+
+// RequestID accepts the caller's X-Request-ID, or generates one, echoes it in the
+// response and puts the request into c.UserContext() so that log records made
+// with that context are tagged with it
+func RequestID() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id := c.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		} else {
+			id = strings.Clone(id) // fiber reuses the header buffer after the request
+		}
+		c.Set(RequestIDHeader, id)
+		c.Locals("request_id", id)
+
+		info := &logging.RequestInfo{
+			ID:     id,
+			Method: strings.Clone(c.Method()),
+			Path:   strings.Clone(c.Path()),
+			Start:  time.Now(),
+		}
+		info.SetRoute(func() string { return c.Route().Path })
+		c.SetUserContext(logging.NewContext(c.UserContext(), info))
+
+		err := c.Next()
+
+		// Freeze the route, the context may be used after fiber recycles c
+		route := c.Route().Path
+		info.SetRoute(func() string { return route })
+		return err
+	}
+}
+
+// CurrentRequestID returns the ID assigned by RequestID
+func CurrentRequestID(c *fiber.Ctx) string {
+	id, _ := c.Locals("request_id").(string)
+	return id
+}
+
+// validRequestID accepts short IDs of letters, digits and . _ - : so they are safe to log
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '.', r == '_', r == '-', r == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
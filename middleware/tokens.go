@@ -0,0 +1,257 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"time"
+
+	"course-api/config"
+	"course-api/models"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const revokedKeyPrefix = "revoked:"
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
+	ErrEmailNotVerified    = errors.New("email address is not verified")
+)
+
+// TokenPair is returned to clients on sign in and on refresh
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"` // Access token lifetime in seconds
+}
+
+// TokenService issues, verifies and revokes access and refresh tokens
+type TokenService struct {
+	db       *gorm.DB
+	redis    *redis.Client // Optional; speeds up denylist checks when set
+	settings config.AuthSettings
+}
+
+// NewTokenService returns a TokenService storing tokens in db. redisClient may be nil.
+func NewTokenService(db *gorm.DB, redisClient *redis.Client, settings config.AuthSettings) *TokenService {
+	return &TokenService{db: db, redis: redisClient, settings: settings}
+}
+
+// AccessTokenTTL returns the configured access token lifetime
+func (s *TokenService) AccessTokenTTL() time.Duration {
+	return s.settings.AccessTokenTTL
+}
+
+// RefreshTokenTTL returns the configured refresh token lifetime
+func (s *TokenService) RefreshTokenTTL() time.Duration {
+	return s.settings.RefreshTokenTTL
+}
+
+// IssueTokens starts a new token family for the user
+func (s *TokenService) IssueTokens(ctx context.Context, user models.User) (TokenPair, error) {
+	return s.issueTokens(s.db.WithContext(ctx), user, uuid.NewString())
+}
+
+// RotateRefreshToken exchanges a refresh token for a new token pair of the same family.
+// Presenting a token that was already rotated revokes the whole family, and users
+// who must verify their email first get ErrEmailNotVerified.
+func (s *TokenService) RotateRefreshToken(ctx context.Context, rawToken string) (TokenPair, error) {
+	var pair TokenPair
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var stored models.RefreshToken
+		if err := tx.Where("token_hash = ?", HashToken(rawToken)).First(&stored).Error; err != nil {
+			return ErrInvalidRefreshToken
+		}
+
+		if stored.UsedAt != nil {
+			return ErrRefreshTokenReused
+		}
+
+		if stored.RevokedAt != nil || time.Now().After(stored.ExpiresAt) {
+			return ErrInvalidRefreshToken
+		}
+
+		var user models.User
+		if err := tx.First(&user, stored.UserID).Error; err != nil || !user.IsActive {
+			return ErrInvalidRefreshToken
+		}
+		if s.settings.RequireEmailVerification && !user.EmailVerified {
+			return ErrEmailNotVerified
+		}
+
+		// Only one concurrent refresh may consume the token
+		now := time.Now()
+		result := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND used_at IS NULL", stored.ID).
+			Update("used_at", &now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrRefreshTokenReused
+		}
+
+		var err error
+		pair, err = s.issueTokens(tx, user, stored.FamilyID)
+		return err
+	})
+
+	if errors.Is(err, ErrRefreshTokenReused) {
+		var stored models.RefreshToken
+		if s.db.WithContext(ctx).Where("token_hash = ?", HashToken(rawToken)).First(&stored).Error == nil {
+			if revokeErr := s.RevokeFamily(ctx, stored.FamilyID); revokeErr != nil {
+				slog.ErrorContext(ctx, "Error revoking token family", "family_id", stored.FamilyID, "error", revokeErr)
+			}
+		}
+	}
+
+	return pair, err
+}
+
+// RevokeRefreshToken revokes the family of the given refresh token, or every
+// family of its user when all is set, and returns the owning user ID
+func (s *TokenService) RevokeRefreshToken(ctx context.Context, rawToken string, all bool) (uint, error) {
+	var stored models.RefreshToken
+	if err := s.db.WithContext(ctx).Where("token_hash = ?", HashToken(rawToken)).First(&stored).Error; err != nil {
+		return 0, ErrInvalidRefreshToken
+	}
+
+	if !all {
+		return stored.UserID, s.RevokeFamily(ctx, stored.FamilyID)
+	}
+
+	return stored.UserID, s.RevokeUserTokens(ctx, stored.UserID)
+}
+
+// RevokeUserTokens revokes every token family of a user
+func (s *TokenService) RevokeUserTokens(ctx context.Context, userID uint) error {
+	var families []string
+	if err := s.db.WithContext(ctx).Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Distinct().Pluck("family_id", &families).Error; err != nil {
+		return err
+	}
+
+	for _, family := range families {
+		if err := s.RevokeFamily(ctx, family); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RevokeFamily revokes all refresh tokens of a family and denylists the
+// access tokens issued with it until they expire
+func (s *TokenService) RevokeFamily(ctx context.Context, familyID string) error {
+	now := time.Now()
+	if err := s.db.WithContext(ctx).Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", &now).Error; err != nil {
+		return err
+	}
+
+	return s.denylist(ctx, "family:"+familyID, s.AccessTokenTTL())
+}
+
+// IsFamilyRevoked reports whether access tokens of the family were revoked.
+// Redis answers when it is reachable; the database is only consulted without
+// Redis or when Redis fails, and a database error counts as revoked.
+func (s *TokenService) IsFamilyRevoked(ctx context.Context, familyID string) bool {
+	key := "family:" + familyID
+
+	if s.redis != nil {
+		n, err := s.redis.Exists(ctx, revokedKeyPrefix+key).Result()
+		if err == nil {
+			return n > 0
+		}
+		slog.WarnContext(ctx, "Error checking token denylist in Redis", "error", err)
+	}
+
+	// Find rather than First: a missing entry is the common case, not an error
+	var entries []models.RevokedToken
+	if err := s.db.WithContext(ctx).
+		Where(&models.RevokedToken{Key: key}).
+		Where("expires_at > ?", time.Now()).
+		Limit(1).Find(&entries).Error; err != nil {
+		slog.ErrorContext(ctx, "Error checking token denylist in the database", "error", err)
+		return true
+	}
+
+	return len(entries) > 0
+}
+
+// denylist stores the key in the database and, when available, in Redis
+func (s *TokenService) denylist(ctx context.Context, key string, ttl time.Duration) error {
+	entry := models.RevokedToken{Key: key, ExpiresAt: time.Now().Add(ttl)}
+	if err := s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"expires_at"}),
+	}).Create(&entry).Error; err != nil {
+		return err
+	}
+
+	// Drop expired entries so the fallback table stays small
+	s.db.WithContext(ctx).Where("expires_at <= ?", time.Now()).Delete(&models.RevokedToken{})
+
+	if s.redis != nil {
+		if err := s.redis.Set(ctx, revokedKeyPrefix+key, 1, ttl).Err(); err != nil {
+			slog.WarnContext(ctx, "Error writing token denylist to Redis", "error", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *TokenService) issueTokens(db *gorm.DB, user models.User, familyID string) (TokenPair, error) {
+	accessToken, err := s.GenerateToken(user.ID, user.Role, familyID)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	refreshToken, refreshHash, err := NewOpaqueToken()
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	if err := db.Create(&models.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		TokenHash: refreshHash,
+		ExpiresAt: time.Now().Add(s.RefreshTokenTTL()),
+	}).Error; err != nil {
+		return TokenPair{}, err
+	}
+
+	return TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(s.AccessTokenTTL().Seconds()),
+	}, nil
+}
+
+// NewOpaqueToken returns a random URL-safe token and the hash to store for it
+func NewOpaqueToken() (token string, hash string, err error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(raw)
+	return token, HashToken(token), nil
+}
+
+// HashToken stores opaque tokens as SHA-256 so a database leak does not leak sessions
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
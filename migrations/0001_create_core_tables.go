@@ -0,0 +1,112 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// The catalog and user tables as of version 1. Migrations own a snapshot of the
+// tables they create, so later changes to the models cannot change what an
+// applied version means; schema changes go in new migrations.
+//
+// Databases created before migrations existed are adopted: AutoMigrate keeps
+// their existing tables and rows, but adds the columns and indexes of the
+// snapshot a table is missing and may alter columns whose type differs. It
+// never drops columns.
+
+type v1Course struct {
+	gorm.Model
+	Title       string
+	Description string
+	Instructor  string
+	Duration    int
+	Price       float64
+}
+
+func (v1Course) TableName() string { return "courses" }
+
+type v1User struct {
+	gorm.Model
+	Email         string `gorm:"unique"`
+	Password      string
+	FullName      string
+	Role          string `gorm:"type:varchar(10);default:'student'"`
+	IsActive      bool   `gorm:"default:true"`
+	EmailVerified bool   `gorm:"default:false"`
+}
+
+func (v1User) TableName() string { return "users" }
+
+type v1Program struct {
+	gorm.Model
+	Title    string
+	Type     string
+	Duration string
+	Price    float64
+	Features string `gorm:"type:json"`
+}
+
+func (v1Program) TableName() string { return "programs" }
+
+type v1Material struct {
+	gorm.Model
+	Title          string
+	Description    string
+	Icon           string
+	Duration       int
+	Lessons        int
+	LearningPoints string           `gorm:"type:json"`
+	Content        []v1ContentTopic `gorm:"foreignKey:MaterialID"`
+	VideoCourses   []v1VideoCourse  `gorm:"foreignKey:MaterialID"`
+}
+
+func (v1Material) TableName() string { return "materials" }
+
+type v1ContentTopic struct {
+	gorm.Model
+	Title      string
+	Content    string `gorm:"type:text"`
+	Topics     string `gorm:"type:json"`
+	Order      int    `gorm:"default:0"`
+	MaterialID uint
+}
+
+func (v1ContentTopic) TableName() string { return "content_topics" }
+
+type v1VideoCourse struct {
+	gorm.Model
+	Title       string
+	Description string
+	YoutubeID   string
+	Duration    string
+	Instructor  string
+	Level       string
+	Order       int `gorm:"default:0"`
+	MaterialID  uint
+}
+
+func (v1VideoCourse) TableName() string { return "video_courses" }
+
+func init() {
+	register(Migration{
+		Version: 1,
+		Name:    "create_core_tables",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(
+				&v1Course{},
+				&v1User{},
+				&v1Program{},
+				&v1Material{},
+				&v1ContentTopic{},
+				&v1VideoCourse{},
+			)
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(
+				&v1VideoCourse{},
+				&v1ContentTopic{},
+				&v1Material{},
+				&v1Program{},
+				&v1User{},
+				&v1Course{},
+			)
+		},
+	})
+}
@@ -0,0 +1,36 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type v2Enrollment struct {
+	gorm.Model
+	UserID      uint       `gorm:"not null;index"`
+	User        *v1User    `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+	CourseID    *uint      `gorm:"index"`
+	Course      *v1Course  `gorm:"foreignKey:CourseID"`
+	ProgramID   *uint      `gorm:"index"`
+	Program     *v1Program `gorm:"foreignKey:ProgramID"`
+	Status      string     `gorm:"type:varchar(20);default:'active'"`
+	EnrolledAt  time.Time
+	CompletedAt *time.Time
+	CancelledAt *time.Time
+}
+
+func (v2Enrollment) TableName() string { return "enrollments" }
+
+func init() {
+	register(Migration{
+		Version: 2,
+		Name:    "create_enrollments",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&v2Enrollment{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&v2Enrollment{})
+		},
+	})
+}
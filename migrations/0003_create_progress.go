@@ -0,0 +1,31 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type v3Progress struct {
+	gorm.Model
+	UserID      uint   `gorm:"not null;uniqueIndex:idx_progress_user_item"`
+	ItemType    string `gorm:"type:varchar(20);not null;uniqueIndex:idx_progress_user_item"`
+	ItemID      uint   `gorm:"not null;uniqueIndex:idx_progress_user_item"`
+	MaterialID  uint   `gorm:"not null;index"`
+	CompletedAt time.Time
+}
+
+func (v3Progress) TableName() string { return "progresses" }
+
+func init() {
+	register(Migration{
+		Version: 3,
+		Name:    "create_progress",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&v3Progress{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&v3Progress{})
+		},
+	})
+}
@@ -0,0 +1,42 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type v4ProgramCourse struct {
+	ProgramID uint `gorm:"primaryKey"`
+	CourseID  uint `gorm:"primaryKey;index"`
+	Order     int  `gorm:"default:0"`
+	CreatedAt time.Time
+	Program   *v1Program `gorm:"foreignKey:ProgramID;constraint:OnDelete:CASCADE"`
+	Course    *v1Course  `gorm:"foreignKey:CourseID;constraint:OnDelete:CASCADE"`
+}
+
+func (v4ProgramCourse) TableName() string { return "program_courses" }
+
+type v4CourseMaterial struct {
+	CourseID   uint `gorm:"primaryKey"`
+	MaterialID uint `gorm:"primaryKey;index"`
+	Order      int  `gorm:"default:0"`
+	CreatedAt  time.Time
+	Course     *v1Course   `gorm:"foreignKey:CourseID;constraint:OnDelete:CASCADE"`
+	Material   *v1Material `gorm:"foreignKey:MaterialID;constraint:OnDelete:CASCADE"`
+}
+
+func (v4CourseMaterial) TableName() string { return "course_materials" }
+
+func init() {
+	register(Migration{
+		Version: 4,
+		Name:    "create_curriculum",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&v4ProgramCourse{}, &v4CourseMaterial{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&v4CourseMaterial{}, &v4ProgramCourse{})
+		},
+	})
+}
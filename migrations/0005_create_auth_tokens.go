@@ -0,0 +1,52 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type v5RefreshToken struct {
+	gorm.Model
+	UserID    uint   `gorm:"not null;index"`
+	FamilyID  string `gorm:"type:varchar(36);not null;index"`
+	TokenHash string `gorm:"type:varchar(64);not null;uniqueIndex"`
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+}
+
+func (v5RefreshToken) TableName() string { return "refresh_tokens" }
+
+type v5RevokedToken struct {
+	ID        uint      `gorm:"primarykey"`
+	Key       string    `gorm:"type:varchar(100);not null;uniqueIndex"`
+	ExpiresAt time.Time `gorm:"index"`
+	CreatedAt time.Time
+}
+
+func (v5RevokedToken) TableName() string { return "revoked_tokens" }
+
+type v5UserToken struct {
+	gorm.Model
+	UserID    uint   `gorm:"not null;index"`
+	Purpose   string `gorm:"type:varchar(30);not null;index"`
+	TokenHash string `gorm:"type:varchar(64);not null;uniqueIndex"`
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
+
+func (v5UserToken) TableName() string { return "user_tokens" }
+
+func init() {
+	register(Migration{
+		Version: 5,
+		Name:    "create_auth_tokens",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&v5RefreshToken{}, &v5RevokedToken{}, &v5UserToken{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&v5UserToken{}, &v5RevokedToken{}, &v5RefreshToken{})
+		},
+	})
+}
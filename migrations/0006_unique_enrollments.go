@@ -0,0 +1,32 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// A user is enrolled at most once in a course and once in a program. Duplicates
+// left by concurrent enrollments are removed first, keeping the oldest row.
+func init() {
+	register(Migration{
+		Version: 6,
+		Name:    "unique_enrollments",
+		Up: func(tx *gorm.DB) error {
+			for _, target := range []string{"course_id", "program_id"} {
+				// The derived table lets MySQL read the table it deletes from
+				if err := tx.Exec(`DELETE FROM enrollments WHERE ` + target + ` IS NOT NULL AND id NOT IN (
+					SELECT id FROM (SELECT MIN(id) AS id FROM enrollments WHERE ` + target + ` IS NOT NULL GROUP BY user_id, ` + target + `) AS kept
+				)`).Error; err != nil {
+					return err
+				}
+			}
+			if err := tx.Exec("CREATE UNIQUE INDEX idx_enrollments_user_course ON enrollments (user_id, course_id)").Error; err != nil {
+				return err
+			}
+			return tx.Exec("CREATE UNIQUE INDEX idx_enrollments_user_program ON enrollments (user_id, program_id)").Error
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropIndex("enrollments", "idx_enrollments_user_program"); err != nil {
+				return err
+			}
+			return tx.Migrator().DropIndex("enrollments", "idx_enrollments_user_course")
+		},
+	})
+}
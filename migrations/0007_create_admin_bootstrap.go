@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// The admin bootstrap guard holds at most one row, inserted with the first admin
+type v7AdminBootstrap struct {
+	ID        uint `gorm:"primaryKey;autoIncrement:false"`
+	UserID    uint
+	CreatedAt time.Time
+}
+
+func (v7AdminBootstrap) TableName() string { return "admin_bootstraps" }
+
+func init() {
+	register(Migration{
+		Version: 7,
+		Name:    "create_admin_bootstrap",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&v7AdminBootstrap{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&v7AdminBootstrap{})
+		},
+	})
+}
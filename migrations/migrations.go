@@ -0,0 +1,270 @@
+// Package migrations keeps the database schema in numbered, reversible steps.
+//
+// Every migration lives in its own file named after its version, e.g.
+// 0006_add_course_level.go, and registers itself from init. Applied versions are
+// recorded in the schema_migrations table. A migration never uses the models
+// package: tables are created from snapshot structs declared in the migration's
+// file, named after its version (e.g. v2Enrollment), and changed with explicit
+// Migrator calls or SQL, so an applied version keeps its meaning as models change.
+package migrations
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Migration is one reversible schema change
+type Migration struct {
+	Version uint
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// SchemaMigration records an applied migration
+type SchemaMigration struct {
+	Version   uint   `gorm:"primaryKey;autoIncrement:false"`
+	Name      string `gorm:"type:varchar(255);not null"`
+	AppliedAt time.Time
+}
+
+func (SchemaMigration) TableName() string { return "schema_migrations" }
+
+// Status describes one known migration and whether it has been applied
+type Status struct {
+	Version   uint
+	Name      string
+	AppliedAt *time.Time
+}
+
+var registry []Migration
+
+// register adds a migration; it is called from the init function of every migration file
+func register(m Migration) {
+	for _, existing := range registry {
+		if existing.Version == m.Version {
+			panic(fmt.Sprintf("migrations: version %d registered twice", m.Version))
+		}
+	}
+	registry = append(registry, m)
+	sort.Slice(registry, func(i, j int) bool { return registry[i].Version < registry[j].Version })
+}
+
+// Up applies every pending migration in order and returns how many ran
+func Up(db *gorm.DB) (int, error) {
+	applied := 0
+	err := withLock(db, func() error {
+		done, err := appliedVersions(db)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range registry {
+			if _, ok := done[m.Version]; ok {
+				continue
+			}
+			slog.Info("Applying migration", "version", m.Version, "name", m.Name)
+			if err := db.Transaction(func(tx *gorm.DB) error {
+				if err := m.Up(tx); err != nil {
+					return err
+				}
+				return tx.Create(&SchemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+			}); err != nil {
+				return fmt.Errorf("migration %04d_%s: %w", m.Version, m.Name, err)
+			}
+			applied++
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down rolls back the given number of most recently applied migrations
+func Down(db *gorm.DB, steps int) (int, error) {
+	rolledBack := 0
+	err := withLock(db, func() error {
+		done, err := appliedVersions(db)
+		if err != nil {
+			return err
+		}
+
+		for i := len(registry) - 1; i >= 0 && rolledBack < steps; i-- {
+			m := registry[i]
+			if _, ok := done[m.Version]; !ok {
+				continue
+			}
+			if m.Down == nil {
+				return fmt.Errorf("migration %04d_%s cannot be rolled back", m.Version, m.Name)
+			}
+			slog.Info("Rolling back migration", "version", m.Version, "name", m.Name)
+			if err := db.Transaction(func(tx *gorm.DB) error {
+				if err := m.Down(tx); err != nil {
+					return err
+				}
+				return tx.Delete(&SchemaMigration{}, m.Version).Error
+			}); err != nil {
+				return fmt.Errorf("migration %04d_%s: %w", m.Version, m.Name, err)
+			}
+			rolledBack++
+		}
+		return nil
+	})
+	return rolledBack, err
+}
+
+// List reports every registered migration and when it was applied
+func List(db *gorm.DB) ([]Status, error) {
+	if err := ensureTables(db); err != nil {
+		return nil, err
+	}
+	done, err := appliedVersions(db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, len(registry))
+	for i, m := range registry {
+		statuses[i] = Status{Version: m.Version, Name: m.Name}
+		if row, ok := done[m.Version]; ok {
+			appliedAt := row.AppliedAt
+			statuses[i].AppliedAt = &appliedAt
+		}
+	}
+	return statuses, nil
+}
+
+// Pending returns how many registered migrations have not been applied
+func Pending(db *gorm.DB) (int, error) {
+	statuses, err := List(db)
+	if err != nil {
+		return 0, err
+	}
+	pending := 0
+	for _, s := range statuses {
+		if s.AppliedAt == nil {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+func appliedVersions(db *gorm.DB) (map[uint]SchemaMigration, error) {
+	var rows []SchemaMigration
+	if err := db.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	done := make(map[uint]SchemaMigration, len(rows))
+	for _, row := range rows {
+		done[row.Version] = row
+	}
+	return done, nil
+}
+
+func ensureTables(db *gorm.DB) error {
+	for _, table := range []interface{}{&SchemaMigration{}, &migrationLock{}} {
+		if !db.Migrator().HasTable(table) {
+			// Another instance may create the table at the same moment
+			if err := db.Migrator().CreateTable(table); err != nil && !db.Migrator().HasTable(table) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+const (
+	// lockTimeout is how long an instance waits for another one to finish migrating
+	lockTimeout = 5 * time.Minute
+	// lockExpiry is when a lock left behind by a crashed instance is taken over
+	lockExpiry = 15 * time.Minute
+	// lockRenewal is how often the holder renews the lock, so a long migration
+	// never looks abandoned
+	lockRenewal = lockExpiry / 3
+	lockRetry   = time.Second
+)
+
+// ErrLockTimeout is returned when another instance holds the migration lock for too long
+var ErrLockTimeout = errors.New("timed out waiting for the migration lock")
+
+// migrationLock is a single-row table; holding the row means holding the lock.
+// A row is used rather than database specific advisory locks so the lock works
+// the same on every supported database.
+type migrationLock struct {
+	ID       uint   `gorm:"primaryKey;autoIncrement:false"`
+	LockedBy string `gorm:"type:varchar(255)"`
+	LockedAt time.Time
+}
+
+func (migrationLock) TableName() string { return "schema_migrations_lock" }
+
+// withLock runs fn while holding the migration lock so only one instance migrates at a time
+func withLock(db *gorm.DB, fn func() error) error {
+	if err := ensureTables(db); err != nil {
+		return err
+	}
+
+	owner := lockOwner()
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		// Take over a lock abandoned by a crashed instance
+		db.Where("id = ? AND locked_at < ?", 1, time.Now().Add(-lockExpiry)).Delete(&migrationLock{})
+
+		result := db.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&migrationLock{ID: 1, LockedBy: owner, LockedAt: time.Now()})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 1 {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			return ErrLockTimeout
+		}
+		slog.Info("Waiting for another instance to finish migrating")
+		time.Sleep(lockRetry)
+	}
+
+	stop := make(chan struct{})
+	renewed := make(chan struct{})
+	go func() {
+		defer close(renewed)
+		renewLock(db, owner, stop)
+	}()
+	defer func() {
+		close(stop)
+		<-renewed
+		db.Where("id = ? AND locked_by = ?", 1, owner).Delete(&migrationLock{})
+	}()
+
+	return fn()
+}
+
+// renewLock keeps the lock of owner fresh until stop is closed
+func renewLock(db *gorm.DB, owner string, stop <-chan struct{}) {
+	ticker := time.NewTicker(lockRenewal)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := db.Model(&migrationLock{}).Where("id = ? AND locked_by = ?", 1, owner).
+				Update("locked_at", time.Now()).Error; err != nil {
+				slog.Warn("Could not renew the migration lock", "error", err)
+			}
+		}
+	}
+}
+
+func lockOwner() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s:%d:%d", host, os.Getpid(), time.Now().UnixNano())
+}
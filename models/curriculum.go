@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+// ProgramCourse places a course at a position inside a program
+type ProgramCourse struct {
+	ProgramID uint      `json:"program_id" gorm:"primaryKey"`
+	CourseID  uint      `json:"course_id" gorm:"primaryKey;index"`
+	Order     int       `json:"order" gorm:"default:0"`
+	CreatedAt time.Time `json:"-"`
+	Program   *Program  `json:"-" gorm:"foreignKey:ProgramID;constraint:OnDelete:CASCADE"`
+	Course    *Course   `json:"-" gorm:"foreignKey:CourseID;constraint:OnDelete:CASCADE"`
+}
+
+// CourseMaterial places a material at a position inside a course
+type CourseMaterial struct {
+	CourseID   uint      `json:"course_id" gorm:"primaryKey"`
+	MaterialID uint      `json:"material_id" gorm:"primaryKey;index"`
+	Order      int       `json:"order" gorm:"default:0"`
+	CreatedAt  time.Time `json:"-"`
+	Course     *Course   `json:"-" gorm:"foreignKey:CourseID;constraint:OnDelete:CASCADE"`
+	Material   *Material `json:"-" gorm:"foreignKey:MaterialID;constraint:OnDelete:CASCADE"`
+}
+
+type AttachCourseInput struct {
+	CourseID uint `json:"course_id" validate:"required"`
+	Order    *int `json:"order" validate:"omitempty,min=0"`
+}
+
+type AttachMaterialInput struct {
+	MaterialID uint `json:"material_id" validate:"required"`
+	Order      *int `json:"order" validate:"omitempty,min=0"`
+}
+
+// ReorderInput lists every child ID in its new order
+type ReorderInput struct {
+	IDs []uint `json:"ids" validate:"required,min=1,unique"`
+}
+
+// CurriculumMaterial is a material with its topics and videos at its position in a course
+type CurriculumMaterial struct {
+	Order int `json:"order"`
+	Material
+}
+
+// CurriculumCourse is a course with its ordered materials
+type CurriculumCourse struct {
+	Order int `json:"order"`
+	Course
+	Materials []CurriculumMaterial `json:"materials"`
+}
+
+// ProgramCurriculum is the full Program → Courses → Materials → Topics tree
+type ProgramCurriculum struct {
+	Program
+	Courses []CurriculumCourse `json:"courses"`
+}
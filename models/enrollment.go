@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type EnrollmentStatus string
+
+const (
+	EnrollmentActive    EnrollmentStatus = "active"
+	EnrollmentCompleted EnrollmentStatus = "completed"
+	EnrollmentCancelled EnrollmentStatus = "cancelled"
+)
+
+// Enrollment links a user to either a course or a program, at most once each
+type Enrollment struct {
+	gorm.Model  `swaggerignore:"true"`
+	UserID      uint             `json:"user_id" gorm:"not null;index;uniqueIndex:idx_enrollments_user_course;uniqueIndex:idx_enrollments_user_program"`
+	User        *User            `json:"user,omitempty" gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+	CourseID    *uint            `json:"course_id,omitempty" gorm:"index;uniqueIndex:idx_enrollments_user_course"`
+	Course      *Course          `json:"course,omitempty" gorm:"foreignKey:CourseID"`
+	ProgramID   *uint            `json:"program_id,omitempty" gorm:"index;uniqueIndex:idx_enrollments_user_program"`
+	Program     *Program         `json:"program,omitempty" gorm:"foreignKey:ProgramID"`
+	Status      EnrollmentStatus `json:"status" gorm:"type:varchar(20);default:'active'"`
+	EnrolledAt  time.Time        `json:"enrolled_at"`
+	CompletedAt *time.Time       `json:"completed_at,omitempty"`
+	CancelledAt *time.Time       `json:"cancelled_at,omitempty"`
+}
+
+type EnrollInput struct {
+	CourseID  uint `json:"course_id" validate:"required_without=ProgramID,excluded_with=ProgramID"`
+	ProgramID uint `json:"program_id" validate:"required_without=CourseID"`
+}
+
+type UpdateEnrollmentStatusInput struct {
+	Status EnrollmentStatus `json:"status" validate:"required,oneof=active completed cancelled"`
+}
+
+// SetStatus changes the enrollment status and stamps the matching timestamp
+func (e *Enrollment) SetStatus(status EnrollmentStatus) {
+	now := time.Now()
+	e.Status = status
+
+	switch status {
+	case EnrollmentActive:
+		e.EnrolledAt = now
+		e.CompletedAt = nil
+		e.CancelledAt = nil
+	case EnrollmentCompleted:
+		e.CompletedAt = &now
+	case EnrollmentCancelled:
+		e.CancelledAt = &now
+	}
+}
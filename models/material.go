@@ -20,10 +20,11 @@ type VideoCourse struct {
 	gorm.Model
 	Title       string    `json:"title" validate:"required"`
 	Description string    `json:"description" validate:"required"`
-	YoutubeID   string    `json:"youtube_id" validate:"required"`
-	Duration    string    `json:"duration" validate:"required"`
+	YoutubeID   string    `json:"youtube_id" validate:"required,youtube_id"`
+	Duration    string    `json:"duration" validate:"required,video_duration"`
 	Instructor  string    `json:"instructor" validate:"required"`
 	Level       string    `json:"level" validate:"required,oneof=beginner intermediate advanced"`
+	Order       int       `json:"order" gorm:"default:0"` // For ordering videos within a material
 	MaterialID  uint      `json:"material_id"`
 	Material    *Material `json:"-" gorm:"foreignKey:MaterialID"`
 }
@@ -47,19 +48,99 @@ type CreateMaterialInput struct {
 	Duration       int            `json:"duration" validate:"required,min=1"`
 	Lessons        int            `json:"lessons" validate:"required,min=1"`
 	LearningPoints []string       `json:"learningPoints" validate:"required"`
-	Content        []ContentTopic `json:"content" validate:"required"`
-	VideoCourses   []VideoCourse  `json:"videoCourses" validate:"required"`
+	Content        []ContentTopic `json:"content" validate:"required,dive"`
+	VideoCourses   []VideoCourse  `json:"videoCourses" validate:"required,dive"`
 }
 
+// How UpdateMaterialInput treats existing rows missing from content or videoCourses
+const (
+	SyncReplace = "replace" // Missing rows are deleted
+	SyncMerge   = "merge"   // Missing rows are kept; only rows flagged with delete are removed
+)
+
 type UpdateMaterialInput struct {
-	Title          string         `json:"title"`
-	Description    string         `json:"description"`
-	Icon           string         `json:"icon"`
-	Duration       int            `json:"duration" validate:"omitempty,min=1"`
-	Lessons        int            `json:"lessons" validate:"omitempty,min=1"`
-	LearningPoints []string       `json:"learningPoints"`
-	Content        []ContentTopic `json:"content"`
-	VideoCourses   []VideoCourse  `json:"videoCourses"`
+	Title          string              `json:"title"`
+	Description    string              `json:"description"`
+	Icon           string              `json:"icon"`
+	Duration       int                 `json:"duration" validate:"omitempty,min=1"`
+	Lessons        int                 `json:"lessons" validate:"omitempty,min=1"`
+	LearningPoints []string            `json:"learningPoints"`
+	Mode           string              `json:"mode" validate:"omitempty,oneof=replace merge"` // Defaults to replace
+	Content        []MaterialTopicItem `json:"content" validate:"omitempty,dive"`
+	VideoCourses   []MaterialVideoItem `json:"videoCourses" validate:"omitempty,dive"`
+}
+
+// MaterialTopicItem updates the content topic with ID, or creates one when ID is 0
+type MaterialTopicItem struct {
+	ID      uint     `json:"id"`
+	Title   string   `json:"title"`
+	Content string   `json:"content"` // HTML content
+	Topics  []string `json:"topics"`
+	Order   *int     `json:"order" validate:"omitempty,min=0"`
+	Delete  bool     `json:"delete"` // Removes the topic with ID
+}
+
+// MaterialVideoItem updates the video course with ID, or creates one when ID is 0
+type MaterialVideoItem struct {
+	ID          uint   `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	YoutubeID   string `json:"youtube_id" validate:"omitempty,youtube_id"`
+	Duration    string `json:"duration" validate:"omitempty,video_duration"`
+	Instructor  string `json:"instructor"`
+	Level       string `json:"level" validate:"omitempty,oneof=beginner intermediate advanced"`
+	Order       *int   `json:"order" validate:"omitempty,min=0"`
+	Delete      bool   `json:"delete"` // Removes the video with ID
+}
+
+// NewContentTopic builds the content topic created for an item without an ID
+func (item MaterialTopicItem) NewContentTopic(materialID uint) ContentTopic {
+	topic := ContentTopic{
+		Title:      item.Title,
+		Content:    item.Content,
+		Topics:     types.StringArray(item.Topics),
+		MaterialID: materialID,
+	}
+	if item.Order != nil {
+		topic.Order = *item.Order
+	}
+	return topic
+}
+
+// NewVideoCourse builds the video course created for an item without an ID
+func (item MaterialVideoItem) NewVideoCourse(materialID uint) VideoCourse {
+	video := VideoCourse{
+		Title:       item.Title,
+		Description: item.Description,
+		YoutubeID:   item.YoutubeID,
+		Duration:    item.Duration,
+		Instructor:  item.Instructor,
+		Level:       item.Level,
+		MaterialID:  materialID,
+	}
+	if item.Order != nil {
+		video.Order = *item.Order
+	}
+	return video
+}
+
+// ChangeSummary lists the IDs of rows touched by a material update
+type ChangeSummary struct {
+	Created   []uint `json:"created"`
+	Updated   []uint `json:"updated"`
+	Deleted   []uint `json:"deleted"`
+	Unchanged []uint `json:"unchanged"`
+}
+
+type MaterialChanges struct {
+	Content      ChangeSummary `json:"content"`
+	VideoCourses ChangeSummary `json:"videoCourses"`
+}
+
+// MaterialUpdateResult is the updated material together with what changed
+type MaterialUpdateResult struct {
+	Material
+	Changes MaterialChanges `json:"changes"`
 }
 
 type CreateContentTopicInput struct {
@@ -76,3 +157,25 @@ type UpdateContentTopicInput struct {
 	Topics  []string `json:"topics"`
 	Order   int      `json:"order"`
 }
+
+type CreateVideoCourseInput struct {
+	Title       string `json:"title" validate:"required"`
+	Description string `json:"description" validate:"required"`
+	YoutubeID   string `json:"youtube_id" validate:"required,youtube_id"`
+	Duration    string `json:"duration" validate:"required,video_duration"` // e.g. "12:30", "1:02:45" or "12m30s"
+	Instructor  string `json:"instructor" validate:"required"`
+	Level       string `json:"level" validate:"required,oneof=beginner intermediate advanced"`
+	Order       *int   `json:"order" validate:"omitempty,min=0"` // Appended after the last video when omitted
+	MaterialID  uint   `json:"material_id" validate:"required"`
+}
+
+type UpdateVideoCourseInput struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	YoutubeID   string `json:"youtube_id" validate:"omitempty,youtube_id"`
+	Duration    string `json:"duration" validate:"omitempty,video_duration"`
+	Instructor  string `json:"instructor"`
+	Level       string `json:"level" validate:"omitempty,oneof=beginner intermediate advanced"`
+	Order       *int   `json:"order" validate:"omitempty,min=0"`
+	MaterialID  uint   `json:"material_id"` // Moves the video to another material
+}
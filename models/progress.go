@@ -0,0 +1,61 @@
+package models
+
+import (
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type ProgressItemType string
+
+const (
+	ProgressContentTopic ProgressItemType = "content_topic"
+	ProgressVideoCourse  ProgressItemType = "video_course"
+)
+
+// Progress records that a user completed a content topic or watched a video course
+type Progress struct {
+	gorm.Model  `swaggerignore:"true"`
+	UserID      uint             `json:"user_id" gorm:"not null;uniqueIndex:idx_progress_user_item"`
+	ItemType    ProgressItemType `json:"item_type" gorm:"type:varchar(20);not null;uniqueIndex:idx_progress_user_item"`
+	ItemID      uint             `json:"item_id" gorm:"not null;uniqueIndex:idx_progress_user_item"`
+	MaterialID  uint             `json:"material_id" gorm:"not null;index"`
+	CompletedAt time.Time        `json:"completed_at"`
+}
+
+// MaterialProgress summarises a user's completion of a single material
+type MaterialProgress struct {
+	MaterialID        uint    `json:"material_id"`
+	Title             string  `json:"title"`
+	TotalTopics       int     `json:"total_topics"`
+	CompletedTopics   int     `json:"completed_topics"`
+	TotalVideos       int     `json:"total_videos"`
+	CompletedVideos   int     `json:"completed_videos"`
+	Percent           float64 `json:"percent"`
+	CompletedTopicIDs []uint  `json:"completed_topic_ids"`
+	CompletedVideoIDs []uint  `json:"completed_video_ids"`
+}
+
+// CourseProgress summarises a user's completion of every material in a course
+type CourseProgress struct {
+	CourseID  uint               `json:"course_id"`
+	Title     string             `json:"title"`
+	Percent   float64            `json:"percent"`
+	Materials []MaterialProgress `json:"materials"`
+}
+
+// ProgressSummary is the progress of a user across all the materials they started
+type ProgressSummary struct {
+	UserID    uint               `json:"user_id"`
+	Percent   float64            `json:"percent"`
+	Materials []MaterialProgress `json:"materials"`
+}
+
+// CompletionPercent returns completed/total as a percentage rounded to two decimals
+func CompletionPercent(completed, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(completed)/float64(total)*10000) / 100
+}
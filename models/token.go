@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RefreshToken is a single-use refresh token; rotating it issues the next
+// token of the same family
+type RefreshToken struct {
+	gorm.Model `swaggerignore:"true"`
+	UserID     uint       `json:"user_id" gorm:"not null;index"`
+	FamilyID   string     `json:"family_id" gorm:"type:varchar(36);not null;index"`
+	TokenHash  string     `json:"-" gorm:"type:varchar(64);not null;uniqueIndex"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	UsedAt     *time.Time `json:"used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// RevokedToken is the database fallback of the token denylist used when Redis is unavailable
+type RevokedToken struct {
+	ID        uint      `gorm:"primarykey"`
+	Key       string    `gorm:"type:varchar(100);not null;uniqueIndex"`
+	ExpiresAt time.Time `gorm:"index"`
+	CreatedAt time.Time
+}
+
+type RefreshInput struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+type LogoutInput struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+	All          bool   `json:"all"` // Revoke every session of the user, not only this one
+}
+
+type UserTokenPurpose string
+
+const (
+	TokenEmailVerification UserTokenPurpose = "email_verification"
+	TokenPasswordReset     UserTokenPurpose = "password_reset"
+)
+
+// UserToken is a single-use, expiring token sent by email
+type UserToken struct {
+	gorm.Model `swaggerignore:"true"`
+	UserID     uint             `json:"user_id" gorm:"not null;index"`
+	Purpose    UserTokenPurpose `json:"purpose" gorm:"type:varchar(30);not null;index"`
+	TokenHash  string           `json:"-" gorm:"type:varchar(64);not null;uniqueIndex"`
+	ExpiresAt  time.Time        `json:"expires_at"`
+	UsedAt     *time.Time       `json:"used_at,omitempty"`
+}
+
+type EmailInput struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type VerifyEmailInput struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type ResetPasswordInput struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,min=6"`
+}
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"time"
+
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
@@ -14,18 +16,48 @@ const (
 )
 
 type User struct {
-	gorm.Model `swaggerignore:"true"`
-	Email      string `json:"email" gorm:"unique" validate:"required,email"`
-	Password   string `json:"-" validate:"required,min=6"`
-	FullName   string `json:"full_name" validate:"required"`
-	Role       Role   `json:"role" gorm:"type:varchar(10);default:'student'" validate:"required,oneof=admin student mentor"`
+	gorm.Model    `swaggerignore:"true"`
+	Email         string `json:"email" gorm:"unique" validate:"required,email"`
+	Password      string `json:"-" validate:"required,min=6"`
+	FullName      string `json:"full_name" validate:"required"`
+	Role          Role   `json:"role" gorm:"type:varchar(10);default:'student'" validate:"required,oneof=admin student mentor"`
+	IsActive      bool   `json:"is_active" gorm:"default:true"`
+	EmailVerified bool   `json:"email_verified" gorm:"default:false"` // Set once the user confirms the signup email
 }
 
+// SignupInput always creates a student; roles are granted by admins
 type SignupInput struct {
 	Email    string `json:"email" validate:"required,email"`
 	Password string `json:"password" validate:"required,min=6"`
 	FullName string `json:"full_name" validate:"required"`
-	Role     Role   `json:"role" validate:"required,oneof=admin student mentor"`
+}
+
+// BootstrapAdminInput creates the very first admin account
+type BootstrapAdminInput struct {
+	Token    string `json:"token" validate:"required"`
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required,min=6"`
+	FullName string `json:"full_name" validate:"required"`
+}
+
+// AdminBootstrap is a single-row guard: the first admin is created in the
+// transaction inserting the row, so concurrent bootstraps cannot both succeed
+type AdminBootstrap struct {
+	ID        uint `gorm:"primaryKey;autoIncrement:false"`
+	UserID    uint
+	CreatedAt time.Time
+}
+
+type UpdateUserRoleInput struct {
+	Role Role `json:"role" validate:"required,oneof=admin student mentor"`
+}
+
+type UpdateUserStatusInput struct {
+	IsActive *bool `json:"is_active" validate:"required"`
+}
+
+type ResetUserPasswordInput struct {
+	Password string `json:"password" validate:"required,min=6"`
 }
 
 type LoginInput struct {
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+	"course-api/models"
+	"course-api/utils/query"
+
+	"gorm.io/gorm"
+)
+
+// ContentTopicRepository stores the content topics of materials
+type ContentTopicRepository interface {
+	ListByMaterial(ctx context.Context, materialID uint, params *query.Params) ([]models.ContentTopic, query.Meta, error)
+	Get(ctx context.Context, id uint) (models.ContentTopic, error)
+	Create(ctx context.Context, topic *models.ContentTopic) error
+	Update(ctx context.Context, topic *models.ContentTopic) error
+	Delete(ctx context.Context, topic *models.ContentTopic) error
+}
+
+type contentTopicRepository struct {
+	db *gorm.DB
+}
+
+// NewContentTopicRepository returns a ContentTopicRepository backed by db
+func NewContentTopicRepository(db *gorm.DB) ContentTopicRepository {
+	return &contentTopicRepository{db: db}
+}
+
+func (r *contentTopicRepository) ListByMaterial(ctx context.Context, materialID uint, params *query.Params) ([]models.ContentTopic, query.Meta, error) {
+	return list[models.ContentTopic](r.db.WithContext(ctx).Where("material_id = ?", materialID), params)
+}
+
+func (r *contentTopicRepository) Get(ctx context.Context, id uint) (models.ContentTopic, error) {
+	return first[models.ContentTopic](r.db.WithContext(ctx), id)
+}
+
+func (r *contentTopicRepository) Create(ctx context.Context, topic *models.ContentTopic) error {
+	return r.db.WithContext(ctx).Create(topic).Error
+}
+
+func (r *contentTopicRepository) Update(ctx context.Context, topic *models.ContentTopic) error {
+	return r.db.WithContext(ctx).Save(topic).Error
+}
+
+func (r *contentTopicRepository) Delete(ctx context.Context, topic *models.ContentTopic) error {
+	return r.db.WithContext(ctx).Delete(topic).Error
+}
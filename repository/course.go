@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+	"course-api/models"
+	"course-api/utils/query"
+
+	"gorm.io/gorm"
+)
+
+// CourseRepository stores courses
+type CourseRepository interface {
+	List(ctx context.Context, params *query.Params) ([]models.Course, query.Meta, error)
+	Get(ctx context.Context, id uint) (models.Course, error)
+	Create(ctx context.Context, course *models.Course) error
+	Update(ctx context.Context, course *models.Course) error
+	// Delete removes the course and detaches it from programs and materials
+	Delete(ctx context.Context, course *models.Course) error
+}
+
+type courseRepository struct {
+	db *gorm.DB
+}
+
+// NewCourseRepository returns a CourseRepository backed by db
+func NewCourseRepository(db *gorm.DB) CourseRepository {
+	return &courseRepository{db: db}
+}
+
+func (r *courseRepository) List(ctx context.Context, params *query.Params) ([]models.Course, query.Meta, error) {
+	return list[models.Course](r.db.WithContext(ctx), params)
+}
+
+func (r *courseRepository) Get(ctx context.Context, id uint) (models.Course, error) {
+	return first[models.Course](r.db.WithContext(ctx), id)
+}
+
+func (r *courseRepository) Create(ctx context.Context, course *models.Course) error {
+	return r.db.WithContext(ctx).Create(course).Error
+}
+
+func (r *courseRepository) Update(ctx context.Context, course *models.Course) error {
+	return r.db.WithContext(ctx).Save(course).Error
+}
+
+func (r *courseRepository) Delete(ctx context.Context, course *models.Course) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(course).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("course_id = ?", course.ID).Delete(&models.ProgramCourse{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("course_id = ?", course.ID).Delete(&models.CourseMaterial{}).Error
+	})
+}
@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"context"
+	"course-api/models"
+
+	"gorm.io/gorm"
+)
+
+// CurriculumRepository stores the ordered links between programs, courses and materials
+type CurriculumRepository interface {
+	// ProgramCourses loads the course links of a program in order, with their courses
+	ProgramCourses(ctx context.Context, programID uint) ([]models.ProgramCourse, error)
+	HasProgramCourse(ctx context.Context, programID, courseID uint) (bool, error)
+	// AddProgramCourse links a course to a program, after its last course when order is nil
+	AddProgramCourse(ctx context.Context, programID, courseID uint, order *int) (models.ProgramCourse, error)
+	// RemoveProgramCourse unlinks a course and reports whether it was linked
+	RemoveProgramCourse(ctx context.Context, programID, courseID uint) (bool, error)
+	ReorderProgramCourses(ctx context.Context, programID uint, courseIDs []uint) ([]models.ProgramCourse, error)
+
+	// CourseMaterials loads the material links of the courses in order, with each
+	// material's content topics and videos in display order
+	CourseMaterials(ctx context.Context, courseIDs []uint) ([]models.CourseMaterial, error)
+	HasCourseMaterial(ctx context.Context, courseID, materialID uint) (bool, error)
+	// AddCourseMaterial links a material to a course, after its last material when order is nil
+	AddCourseMaterial(ctx context.Context, courseID, materialID uint, order *int) (models.CourseMaterial, error)
+	// RemoveCourseMaterial unlinks a material and reports whether it was linked
+	RemoveCourseMaterial(ctx context.Context, courseID, materialID uint) (bool, error)
+	ReorderCourseMaterials(ctx context.Context, courseID uint, materialIDs []uint) ([]models.CourseMaterial, error)
+}
+
+type curriculumRepository struct {
+	db *gorm.DB
+}
+
+// NewCurriculumRepository returns a CurriculumRepository backed by db
+func NewCurriculumRepository(db *gorm.DB) CurriculumRepository {
+	return &curriculumRepository{db: db}
+}
+
+func (r *curriculumRepository) ProgramCourses(ctx context.Context, programID uint) ([]models.ProgramCourse, error) {
+	var links []models.ProgramCourse
+	err := r.db.WithContext(ctx).Preload("Course").
+		Where("program_id = ?", programID).
+		Order(byOrder).Order("course_id asc").
+		Find(&links).Error
+	return links, err
+}
+
+func (r *curriculumRepository) HasProgramCourse(ctx context.Context, programID, courseID uint) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.ProgramCourse{}).Where("program_id = ? AND course_id = ?", programID, courseID).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *curriculumRepository) AddProgramCourse(ctx context.Context, programID, courseID uint, order *int) (models.ProgramCourse, error) {
+	link := models.ProgramCourse{ProgramID: programID, CourseID: courseID}
+	if order != nil {
+		link.Order = *order
+	} else {
+		next, err := nextOrder(r.db.WithContext(ctx), &models.ProgramCourse{}, "program_id = ?", programID)
+		if err != nil {
+			return link, err
+		}
+		link.Order = next
+	}
+
+	err := r.db.WithContext(ctx).Create(&link).Error
+	return link, err
+}
+
+func (r *curriculumRepository) RemoveProgramCourse(ctx context.Context, programID, courseID uint) (bool, error) {
+	result := r.db.WithContext(ctx).Where("program_id = ? AND course_id = ?", programID, courseID).Delete(&models.ProgramCourse{})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *curriculumRepository) ReorderProgramCourses(ctx context.Context, programID uint, courseIDs []uint) ([]models.ProgramCourse, error) {
+	if err := reorder(r.db.WithContext(ctx), &models.ProgramCourse{}, "program_id", programID, "course_id", courseIDs); err != nil {
+		return nil, err
+	}
+
+	var links []models.ProgramCourse
+	err := r.db.WithContext(ctx).Where("program_id = ?", programID).Order(byOrder).Find(&links).Error
+	return links, err
+}
+
+func (r *curriculumRepository) CourseMaterials(ctx context.Context, courseIDs []uint) ([]models.CourseMaterial, error) {
+	var links []models.CourseMaterial
+	err := r.db.WithContext(ctx).
+		Preload("Material").
+		Preload("Material.Content", inOrder).
+		Preload("Material.VideoCourses", inOrder).
+		Where("course_id IN ?", courseIDs).
+		Order(byOrder).Order("material_id asc").
+		Find(&links).Error
+	return links, err
+}
+
+func (r *curriculumRepository) HasCourseMaterial(ctx context.Context, courseID, materialID uint) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.CourseMaterial{}).Where("course_id = ? AND material_id = ?", courseID, materialID).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *curriculumRepository) AddCourseMaterial(ctx context.Context, courseID, materialID uint, order *int) (models.CourseMaterial, error) {
+	link := models.CourseMaterial{CourseID: courseID, MaterialID: materialID}
+	if order != nil {
+		link.Order = *order
+	} else {
+		next, err := nextOrder(r.db.WithContext(ctx), &models.CourseMaterial{}, "course_id = ?", courseID)
+		if err != nil {
+			return link, err
+		}
+		link.Order = next
+	}
+
+	err := r.db.WithContext(ctx).Create(&link).Error
+	return link, err
+}
+
+func (r *curriculumRepository) RemoveCourseMaterial(ctx context.Context, courseID, materialID uint) (bool, error) {
+	result := r.db.WithContext(ctx).Where("course_id = ? AND material_id = ?", courseID, materialID).Delete(&models.CourseMaterial{})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *curriculumRepository) ReorderCourseMaterials(ctx context.Context, courseID uint, materialIDs []uint) ([]models.CourseMaterial, error) {
+	if err := reorder(r.db.WithContext(ctx), &models.CourseMaterial{}, "course_id", courseID, "material_id", materialIDs); err != nil {
+		return nil, err
+	}
+
+	var links []models.CourseMaterial
+	err := r.db.WithContext(ctx).Where("course_id = ?", courseID).Order(byOrder).Find(&links).Error
+	return links, err
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"course-api/models"
+	"course-api/utils/query"
+
+	"gorm.io/gorm"
+)
+
+// EnrollmentRepository stores enrollments of users in courses and programs
+type EnrollmentRepository interface {
+	Get(ctx context.Context, id uint) (models.Enrollment, error)
+	// FindForUser loads the user's enrollment in the course, or in the program when courseID is 0
+	FindForUser(ctx context.Context, userID, courseID, programID uint) (models.Enrollment, error)
+	// Create returns ErrDuplicate when the user is already enrolled in the course or program
+	Create(ctx context.Context, enrollment *models.Enrollment) error
+	Update(ctx context.Context, enrollment *models.Enrollment) error
+	// CoversMaterial reports whether the user has an active enrollment in a course
+	// containing the material, or in a program containing such a course
+	CoversMaterial(ctx context.Context, userID, materialID uint) (bool, error)
+	// ListByUser lists a user's enrollments with their course or program
+	ListByUser(ctx context.Context, userID uint, params *query.Params) ([]models.Enrollment, query.Meta, error)
+	// ListByCourse lists the enrollments of a course with their users
+	ListByCourse(ctx context.Context, courseID uint, params *query.Params) ([]models.Enrollment, query.Meta, error)
+	// ListByProgram lists the enrollments of a program with their users
+	ListByProgram(ctx context.Context, programID uint, params *query.Params) ([]models.Enrollment, query.Meta, error)
+}
+
+type enrollmentRepository struct {
+	db *gorm.DB
+}
+
+// NewEnrollmentRepository returns an EnrollmentRepository backed by db
+func NewEnrollmentRepository(db *gorm.DB) EnrollmentRepository {
+	return &enrollmentRepository{db: db}
+}
+
+func (r *enrollmentRepository) Get(ctx context.Context, id uint) (models.Enrollment, error) {
+	return first[models.Enrollment](r.db.WithContext(ctx), id)
+}
+
+func (r *enrollmentRepository) FindForUser(ctx context.Context, userID, courseID, programID uint) (models.Enrollment, error) {
+	db := r.db.WithContext(ctx).Where("user_id = ?", userID)
+	if courseID != 0 {
+		db = db.Where("course_id = ?", courseID)
+	} else {
+		db = db.Where("program_id = ?", programID)
+	}
+
+	var enrollment models.Enrollment
+	err := db.First(&enrollment).Error
+	return enrollment, notFound(err)
+}
+
+func (r *enrollmentRepository) Create(ctx context.Context, enrollment *models.Enrollment) error {
+	return duplicate(r.db.WithContext(ctx).Create(enrollment).Error)
+}
+
+func (r *enrollmentRepository) Update(ctx context.Context, enrollment *models.Enrollment) error {
+	return r.db.WithContext(ctx).Save(enrollment).Error
+}
+
+func (r *enrollmentRepository) CoversMaterial(ctx context.Context, userID, materialID uint) (bool, error) {
+	db := r.db.WithContext(ctx)
+	courses := db.Model(&models.CourseMaterial{}).Select("course_id").Where("material_id = ?", materialID)
+	programs := db.Model(&models.ProgramCourse{}).Select("program_id").Where("course_id IN (?)", courses)
+
+	var count int64
+	err := db.Model(&models.Enrollment{}).
+		Where("user_id = ? AND status = ?", userID, models.EnrollmentActive).
+		Where(db.Where("course_id IN (?)", courses).Or("program_id IN (?)", programs)).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *enrollmentRepository) ListByUser(ctx context.Context, userID uint, params *query.Params) ([]models.Enrollment, query.Meta, error) {
+	return list[models.Enrollment](r.db.WithContext(ctx).Where("user_id = ?", userID), params, preload("Course", "Program"))
+}
+
+func (r *enrollmentRepository) ListByCourse(ctx context.Context, courseID uint, params *query.Params) ([]models.Enrollment, query.Meta, error) {
+	return list[models.Enrollment](r.db.WithContext(ctx).Where("course_id = ?", courseID), params, preload("User"))
+}
+
+func (r *enrollmentRepository) ListByProgram(ctx context.Context, programID uint, params *query.Params) ([]models.Enrollment, query.Meta, error) {
+	return list[models.Enrollment](r.db.WithContext(ctx).Where("program_id = ?", programID), params, preload("User"))
+}
@@ -0,0 +1,338 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"course-api/models"
+	"course-api/types"
+	"course-api/utils/query"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MaterialRepository stores materials together with their content topics and video courses
+type MaterialRepository interface {
+	// List loads a page of materials with the given associations, e.g. "Content"
+	List(ctx context.Context, params *query.Params, associations ...string) ([]models.Material, query.Meta, error)
+	Get(ctx context.Context, id uint) (models.Material, error)
+	// GetWithChildren loads the material with its content topics and videos in display order
+	GetWithChildren(ctx context.Context, id uint) (models.Material, error)
+	// ListByIDs loads the given materials ordered by ID
+	ListByIDs(ctx context.Context, ids []uint) ([]models.Material, error)
+	// ListByCourse loads the materials of a course in curriculum order
+	ListByCourse(ctx context.Context, courseID uint) ([]models.Material, error)
+	// Create stores the material together with its Content and VideoCourses
+	Create(ctx context.Context, material *models.Material) error
+	// Update saves the material and reconciles its content topics and videos with the
+	// listed items by ID. Existing rows left out of a list are deleted when replace is
+	// set; a nil list leaves those rows untouched.
+	Update(ctx context.Context, material *models.Material, content []models.MaterialTopicItem, videos []models.MaterialVideoItem, replace bool) (models.MaterialChanges, error)
+	// Delete removes the material, its content topics and videos, and detaches it from courses
+	Delete(ctx context.Context, material *models.Material) error
+}
+
+// SyncError reports an item of a material update that does not fit the stored rows
+type SyncError string
+
+func (e SyncError) Error() string { return string(e) }
+
+type materialRepository struct {
+	db *gorm.DB
+}
+
+// NewMaterialRepository returns a MaterialRepository backed by db
+func NewMaterialRepository(db *gorm.DB) MaterialRepository {
+	return &materialRepository{db: db}
+}
+
+func (r *materialRepository) List(ctx context.Context, params *query.Params, associations ...string) ([]models.Material, query.Meta, error) {
+	return list[models.Material](r.db.WithContext(ctx), params, preload(associations...))
+}
+
+func (r *materialRepository) Get(ctx context.Context, id uint) (models.Material, error) {
+	return first[models.Material](r.db.WithContext(ctx), id)
+}
+
+func (r *materialRepository) GetWithChildren(ctx context.Context, id uint) (models.Material, error) {
+	return first[models.Material](r.db.WithContext(ctx).Preload("Content", inOrder).Preload("VideoCourses", inOrder), id)
+}
+
+func (r *materialRepository) ListByIDs(ctx context.Context, ids []uint) ([]models.Material, error) {
+	var materials []models.Material
+	err := r.db.WithContext(ctx).Where("id IN ?", ids).Order("id asc").Find(&materials).Error
+	return materials, err
+}
+
+func (r *materialRepository) ListByCourse(ctx context.Context, courseID uint) ([]models.Material, error) {
+	var materials []models.Material
+	err := r.db.WithContext(ctx).
+		Joins("JOIN course_materials ON course_materials.material_id = materials.id").
+		Where("course_materials.course_id = ?", courseID).
+		Order(clause.OrderByColumn{Column: clause.Column{Table: "course_materials", Name: "order"}}).
+		Find(&materials).Error
+	return materials, err
+}
+
+func (r *materialRepository) Create(ctx context.Context, material *models.Material) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(material).Error; err != nil {
+			return err
+		}
+
+		for i := range material.Content {
+			material.Content[i].MaterialID = material.ID
+			if err := tx.Create(&material.Content[i]).Error; err != nil {
+				return err
+			}
+		}
+
+		for i := range material.VideoCourses {
+			material.VideoCourses[i].MaterialID = material.ID
+			if err := tx.Create(&material.VideoCourses[i]).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (r *materialRepository) Update(ctx context.Context, material *models.Material, content []models.MaterialTopicItem, videos []models.MaterialVideoItem, replace bool) (models.MaterialChanges, error) {
+	changes := models.MaterialChanges{Content: newChangeSummary(), VideoCourses: newChangeSummary()}
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Save(material).Error; err != nil {
+			return err
+		}
+
+		// A missing list leaves the rows alone, an empty one clears them in replace mode
+		if content != nil {
+			if err := syncContentTopics(tx, material.ID, content, replace, &changes.Content); err != nil {
+				return err
+			}
+		}
+		if videos != nil {
+			if err := syncVideoCourses(tx, material.ID, videos, replace, &changes.VideoCourses); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	return changes, err
+}
+
+func (r *materialRepository) Delete(ctx context.Context, material *models.Material) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("material_id = ?", material.ID).Delete(&models.ContentTopic{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("material_id = ?", material.ID).Delete(&models.VideoCourse{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("material_id = ?", material.ID).Delete(&models.CourseMaterial{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(material).Error
+	})
+}
+
+func newChangeSummary() models.ChangeSummary {
+	return models.ChangeSummary{Created: []uint{}, Updated: []uint{}, Deleted: []uint{}, Unchanged: []uint{}}
+}
+
+// syncContentTopics applies the listed items to the content topics of a material.
+// Items without an ID are created as given, so they must be validated beforehand.
+func syncContentTopics(tx *gorm.DB, materialID uint, items []models.MaterialTopicItem, replace bool, summary *models.ChangeSummary) error {
+	var existing []models.ContentTopic
+	if err := tx.Where("material_id = ?", materialID).Scopes(inOrder).Find(&existing).Error; err != nil {
+		return err
+	}
+
+	byID := make(map[uint]*models.ContentTopic, len(existing))
+	next := 0
+	for i := range existing {
+		byID[existing[i].ID] = &existing[i]
+		if existing[i].Order >= next {
+			next = existing[i].Order + 1
+		}
+	}
+
+	listed := make(map[uint]bool, len(items))
+	for i, item := range items {
+		if item.ID == 0 {
+			if item.Delete {
+				return SyncError(fmt.Sprintf("content[%d]: delete needs an id", i))
+			}
+			topic := item.NewContentTopic(materialID)
+			if item.Order == nil {
+				topic.Order = next
+			}
+			if topic.Order >= next {
+				next = topic.Order + 1
+			}
+			if err := tx.Create(&topic).Error; err != nil {
+				return err
+			}
+			summary.Created = append(summary.Created, topic.ID)
+			continue
+		}
+
+		topic, ok := byID[item.ID]
+		if !ok {
+			return SyncError(fmt.Sprintf("content[%d]: content topic %d does not belong to this material", i, item.ID))
+		}
+		if listed[item.ID] {
+			return SyncError(fmt.Sprintf("content[%d]: content topic %d is listed more than once", i, item.ID))
+		}
+		listed[item.ID] = true
+
+		if item.Delete {
+			if err := tx.Delete(topic).Error; err != nil {
+				return err
+			}
+			summary.Deleted = append(summary.Deleted, topic.ID)
+			continue
+		}
+
+		changed := false
+		if item.Title != "" && item.Title != topic.Title {
+			topic.Title, changed = item.Title, true
+		}
+		if item.Content != "" && item.Content != topic.Content {
+			topic.Content, changed = item.Content, true
+		}
+		if item.Topics != nil && !slices.Equal(item.Topics, topic.Topics) {
+			topic.Topics, changed = types.StringArray(item.Topics), true
+		}
+		if item.Order != nil && *item.Order != topic.Order {
+			topic.Order, changed = *item.Order, true
+		}
+		if !changed {
+			summary.Unchanged = append(summary.Unchanged, topic.ID)
+			continue
+		}
+		if err := tx.Save(topic).Error; err != nil {
+			return err
+		}
+		summary.Updated = append(summary.Updated, topic.ID)
+	}
+
+	if replace {
+		for _, topic := range existing {
+			if listed[topic.ID] {
+				continue
+			}
+			if err := tx.Delete(&topic).Error; err != nil {
+				return err
+			}
+			summary.Deleted = append(summary.Deleted, topic.ID)
+		}
+	}
+
+	return nil
+}
+
+// syncVideoCourses applies the listed items to the video courses of a material.
+// Items without an ID are created as given, so they must be validated beforehand.
+func syncVideoCourses(tx *gorm.DB, materialID uint, items []models.MaterialVideoItem, replace bool, summary *models.ChangeSummary) error {
+	var existing []models.VideoCourse
+	if err := tx.Where("material_id = ?", materialID).Scopes(inOrder).Find(&existing).Error; err != nil {
+		return err
+	}
+
+	byID := make(map[uint]*models.VideoCourse, len(existing))
+	next := 0
+	for i := range existing {
+		byID[existing[i].ID] = &existing[i]
+		if existing[i].Order >= next {
+			next = existing[i].Order + 1
+		}
+	}
+
+	listed := make(map[uint]bool, len(items))
+	for i, item := range items {
+		if item.ID == 0 {
+			if item.Delete {
+				return SyncError(fmt.Sprintf("videoCourses[%d]: delete needs an id", i))
+			}
+			video := item.NewVideoCourse(materialID)
+			if item.Order == nil {
+				video.Order = next
+			}
+			if video.Order >= next {
+				next = video.Order + 1
+			}
+			if err := tx.Create(&video).Error; err != nil {
+				return err
+			}
+			summary.Created = append(summary.Created, video.ID)
+			continue
+		}
+
+		video, ok := byID[item.ID]
+		if !ok {
+			return SyncError(fmt.Sprintf("videoCourses[%d]: video %d does not belong to this material", i, item.ID))
+		}
+		if listed[item.ID] {
+			return SyncError(fmt.Sprintf("videoCourses[%d]: video %d is listed more than once", i, item.ID))
+		}
+		listed[item.ID] = true
+
+		if item.Delete {
+			if err := tx.Delete(video).Error; err != nil {
+				return err
+			}
+			summary.Deleted = append(summary.Deleted, video.ID)
+			continue
+		}
+
+		changed := false
+		if item.Title != "" && item.Title != video.Title {
+			video.Title, changed = item.Title, true
+		}
+		if item.Description != "" && item.Description != video.Description {
+			video.Description, changed = item.Description, true
+		}
+		if item.YoutubeID != "" && item.YoutubeID != video.YoutubeID {
+			video.YoutubeID, changed = item.YoutubeID, true
+		}
+		if item.Duration != "" && item.Duration != video.Duration {
+			video.Duration, changed = item.Duration, true
+		}
+		if item.Instructor != "" && item.Instructor != video.Instructor {
+			video.Instructor, changed = item.Instructor, true
+		}
+		if item.Level != "" && item.Level != video.Level {
+			video.Level, changed = item.Level, true
+		}
+		if item.Order != nil && *item.Order != video.Order {
+			video.Order, changed = *item.Order, true
+		}
+		if !changed {
+			summary.Unchanged = append(summary.Unchanged, video.ID)
+			continue
+		}
+		if err := tx.Save(video).Error; err != nil {
+			return err
+		}
+		summary.Updated = append(summary.Updated, video.ID)
+	}
+
+	if replace {
+		for _, video := range existing {
+			if listed[video.ID] {
+				continue
+			}
+			if err := tx.Delete(&video).Error; err != nil {
+				return err
+			}
+			summary.Deleted = append(summary.Deleted, video.ID)
+		}
+	}
+
+	return nil
+}
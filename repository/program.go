@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+	"course-api/models"
+	"course-api/utils/query"
+
+	"gorm.io/gorm"
+)
+
+// ProgramRepository stores programs
+type ProgramRepository interface {
+	List(ctx context.Context, params *query.Params) ([]models.Program, query.Meta, error)
+	Get(ctx context.Context, id uint) (models.Program, error)
+	Create(ctx context.Context, program *models.Program) error
+	Update(ctx context.Context, program *models.Program) error
+	// Delete removes the program and detaches its courses
+	Delete(ctx context.Context, program *models.Program) error
+}
+
+type programRepository struct {
+	db *gorm.DB
+}
+
+// NewProgramRepository returns a ProgramRepository backed by db
+func NewProgramRepository(db *gorm.DB) ProgramRepository {
+	return &programRepository{db: db}
+}
+
+func (r *programRepository) List(ctx context.Context, params *query.Params) ([]models.Program, query.Meta, error) {
+	return list[models.Program](r.db.WithContext(ctx), params)
+}
+
+func (r *programRepository) Get(ctx context.Context, id uint) (models.Program, error) {
+	return first[models.Program](r.db.WithContext(ctx), id)
+}
+
+func (r *programRepository) Create(ctx context.Context, program *models.Program) error {
+	return r.db.WithContext(ctx).Create(program).Error
+}
+
+func (r *programRepository) Update(ctx context.Context, program *models.Program) error {
+	return r.db.WithContext(ctx).Save(program).Error
+}
+
+func (r *programRepository) Delete(ctx context.Context, program *models.Program) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(program).Error; err != nil {
+			return err
+		}
+		return tx.Where("program_id = ?", program.ID).Delete(&models.ProgramCourse{}).Error
+	})
+}
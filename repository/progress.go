@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"course-api/models"
+
+	"gorm.io/gorm"
+)
+
+// ProgressRepository stores which content topics and videos users completed
+type ProgressRepository interface {
+	// Complete records the completion once; completing again keeps the original time
+	Complete(ctx context.Context, progress *models.Progress) error
+	Uncomplete(ctx context.Context, userID uint, itemType models.ProgressItemType, itemID uint) error
+	// MaterialIDs lists the materials the user completed anything in
+	MaterialIDs(ctx context.Context, userID uint) ([]uint, error)
+	// Records loads the user's completions within the materials
+	Records(ctx context.Context, userID uint, materialIDs []uint) ([]models.Progress, error)
+	// Items loads the IDs and materials of every content topic and video of the materials
+	Items(ctx context.Context, materialIDs []uint) ([]models.ContentTopic, []models.VideoCourse, error)
+}
+
+type progressRepository struct {
+	db *gorm.DB
+}
+
+// NewProgressRepository returns a ProgressRepository backed by db
+func NewProgressRepository(db *gorm.DB) ProgressRepository {
+	return &progressRepository{db: db}
+}
+
+func (r *progressRepository) Complete(ctx context.Context, progress *models.Progress) error {
+	return r.db.WithContext(ctx).
+		Where(models.Progress{UserID: progress.UserID, ItemType: progress.ItemType, ItemID: progress.ItemID}).
+		FirstOrCreate(progress).Error
+}
+
+func (r *progressRepository) Uncomplete(ctx context.Context, userID uint, itemType models.ProgressItemType, itemID uint) error {
+	return r.db.WithContext(ctx).Unscoped().
+		Where("user_id = ? AND item_type = ? AND item_id = ?", userID, itemType, itemID).
+		Delete(&models.Progress{}).Error
+}
+
+func (r *progressRepository) MaterialIDs(ctx context.Context, userID uint) ([]uint, error) {
+	var materialIDs []uint
+	err := r.db.WithContext(ctx).Model(&models.Progress{}).
+		Where("user_id = ?", userID).
+		Distinct().Pluck("material_id", &materialIDs).Error
+	return materialIDs, err
+}
+
+func (r *progressRepository) Records(ctx context.Context, userID uint, materialIDs []uint) ([]models.Progress, error) {
+	var records []models.Progress
+	err := r.db.WithContext(ctx).Where("user_id = ? AND material_id IN ?", userID, materialIDs).Find(&records).Error
+	return records, err
+}
+
+func (r *progressRepository) Items(ctx context.Context, materialIDs []uint) ([]models.ContentTopic, []models.VideoCourse, error) {
+	var topics []models.ContentTopic
+	if err := r.db.WithContext(ctx).Select("id", "material_id").Where("material_id IN ?", materialIDs).Find(&topics).Error; err != nil {
+		return nil, nil, err
+	}
+
+	var videos []models.VideoCourse
+	if err := r.db.WithContext(ctx).Select("id", "material_id").Where("material_id IN ?", materialIDs).Find(&videos).Error; err != nil {
+		return nil, nil, err
+	}
+
+	return topics, videos, nil
+}
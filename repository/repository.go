@@ -0,0 +1,118 @@
+// Package repository keeps database access behind one interface per resource.
+//
+// The GORM implementations work with every supported database, SQLite included.
+// Handlers only see the interfaces, so another implementation, such as an
+// in-memory one in tests, can be swapped in where the handlers are built.
+package repository
+
+import (
+	"errors"
+
+	"course-api/utils/query"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrNotFound is returned when the requested row does not exist
+var ErrNotFound = errors.New("record not found")
+
+// ErrDuplicate is returned when a row conflicts with a unique constraint
+var ErrDuplicate = errors.New("record already exists")
+
+// ErrInvalidOrder is returned by reorders whose ids do not list every attached item exactly once
+var ErrInvalidOrder = errors.New("ids must list every attached item exactly once")
+
+// byOrder sorts by the "order" column, quoted because ORDER is a reserved word
+var byOrder = clause.OrderByColumn{Column: clause.Column{Name: "order"}}
+
+// inOrder sorts ordered children such as content topics and videos by position
+func inOrder(db *gorm.DB) *gorm.DB {
+	return db.Order(byOrder).Order("id asc")
+}
+
+// preload returns a scope preloading the given associations
+func preload(associations ...string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		for _, a := range associations {
+			db = db.Preload(a)
+		}
+		return db
+	}
+}
+
+// notFound maps GORM's missing row error to ErrNotFound
+func notFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// duplicate maps GORM's unique constraint violation to ErrDuplicate. GORM only
+// reports it with TranslateError set on the connection.
+func duplicate(err error) error {
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+// first loads the row with the given primary key
+func first[T any](db *gorm.DB, id uint) (T, error) {
+	var row T
+	err := db.First(&row, id).Error
+	return row, notFound(err)
+}
+
+// list loads one page of rows; scopes only apply to the data query, not to the count
+func list[T any](db *gorm.DB, params *query.Params, scopes ...func(*gorm.DB) *gorm.DB) ([]T, query.Meta, error) {
+	var rows []T
+	meta, err := params.Find(db, &rows, scopes...)
+	return rows, meta, err
+}
+
+// nextOrder returns the position right after the last child matching the condition
+func nextOrder(db *gorm.DB, model interface{}, condition string, parentID uint) (int, error) {
+	var last struct{ Max *int }
+	if err := db.Model(model).Select("MAX(?) AS max", clause.Column{Name: "order"}).Where(condition, parentID).Scan(&last).Error; err != nil {
+		return 0, err
+	}
+	if last.Max == nil {
+		return 0, nil
+	}
+	return *last.Max + 1, nil
+}
+
+// reorder rewrites the order column of a table so children follow ids,
+// which must contain exactly the children currently attached to the parent
+func reorder(db *gorm.DB, model interface{}, parentColumn string, parentID uint, childColumn string, ids []uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var current []uint
+		if err := tx.Model(model).Where(parentColumn+" = ?", parentID).Pluck(childColumn, &current).Error; err != nil {
+			return err
+		}
+
+		if len(current) != len(ids) {
+			return ErrInvalidOrder
+		}
+
+		attached := make(map[uint]bool, len(current))
+		for _, id := range current {
+			attached[id] = true
+		}
+
+		for i, id := range ids {
+			if !attached[id] {
+				return ErrInvalidOrder
+			}
+			if err := tx.Model(model).
+				Where(parentColumn+" = ? AND "+childColumn+" = ?", parentID, id).
+				Update("order", i).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"course-api/models"
+	"course-api/utils/query"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// ErrAdminExists is returned by CreateFirstAdmin once an admin exists or was bootstrapped
+var ErrAdminExists = errors.New("an admin already exists")
+
+// UserRepository stores user accounts
+type UserRepository interface {
+	List(ctx context.Context, params *query.Params) ([]models.User, query.Meta, error)
+	Get(ctx context.Context, id uint) (models.User, error)
+	FindByEmail(ctx context.Context, email string) (models.User, error)
+	// Create returns ErrDuplicate when the email is already registered
+	Create(ctx context.Context, user *models.User) error
+	// CreateFirstAdmin creates the user unless an admin exists or one was
+	// bootstrapped before, in which case it returns ErrAdminExists
+	CreateFirstAdmin(ctx context.Context, user *models.User) error
+	// CountOtherActiveAdmins counts the active admins other than the given user
+	CountOtherActiveAdmins(ctx context.Context, userID uint) (int64, error)
+	UpdateRole(ctx context.Context, userID uint, role models.Role) error
+	UpdateStatus(ctx context.Context, userID uint, active bool) error
+	// UpdatePassword stores an already hashed password
+	UpdatePassword(ctx context.Context, userID uint, passwordHash string) error
+}
+
+type userRepository struct {
+	db *gorm.DB
+}
+
+// NewUserRepository returns a UserRepository backed by db
+func NewUserRepository(db *gorm.DB) UserRepository {
+	return &userRepository{db: db}
+}
+
+func (r *userRepository) List(ctx context.Context, params *query.Params) ([]models.User, query.Meta, error) {
+	return list[models.User](r.db.WithContext(ctx), params)
+}
+
+func (r *userRepository) Get(ctx context.Context, id uint) (models.User, error) {
+	return first[models.User](r.db.WithContext(ctx), id)
+}
+
+func (r *userRepository) FindByEmail(ctx context.Context, email string) (models.User, error) {
+	var user models.User
+	err := r.db.WithContext(ctx).Where("email = ?", email).First(&user).Error
+	return user, notFound(err)
+}
+
+func (r *userRepository) Create(ctx context.Context, user *models.User) error {
+	return duplicate(r.db.WithContext(ctx).Create(user).Error)
+}
+
+func (r *userRepository) CreateFirstAdmin(ctx context.Context, user *models.User) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// The guard row goes first: a concurrent bootstrap holds or has committed
+		// it, and starting with a write keeps SQLite from failing a lock upgrade
+		if err := tx.Create(&models.AdminBootstrap{ID: 1}).Error; errors.Is(err, gorm.ErrDuplicatedKey) {
+			return ErrAdminExists
+		} else if err != nil {
+			return err
+		}
+
+		var admins int64
+		if err := tx.Model(&models.User{}).Where("role = ?", models.RoleAdmin).Count(&admins).Error; err != nil {
+			return err
+		}
+		if admins > 0 {
+			return ErrAdminExists
+		}
+
+		if err := tx.Create(user).Error; err != nil {
+			return duplicate(err)
+		}
+		return tx.Model(&models.AdminBootstrap{ID: 1}).Update("user_id", user.ID).Error
+	})
+}
+
+func (r *userRepository) CountOtherActiveAdmins(ctx context.Context, userID uint) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.User{}).
+		Where("role = ? AND is_active = ? AND id <> ?", models.RoleAdmin, true, userID).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *userRepository) UpdateRole(ctx context.Context, userID uint, role models.Role) error {
+	return r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", userID).Update("role", role).Error
+}
+
+func (r *userRepository) UpdateStatus(ctx context.Context, userID uint, active bool) error {
+	return r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", userID).Update("is_active", active).Error
+}
+
+func (r *userRepository) UpdatePassword(ctx context.Context, userID uint, passwordHash string) error {
+	return r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", userID).Update("password", passwordHash).Error
+}
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"course-api/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidToken is returned for a single-use token that is unknown, expired or already used
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// UserTokenRepository stores the single-use tokens sent by email. Tokens are
+// looked up by their hash, never by the raw value.
+type UserTokenRepository interface {
+	Create(ctx context.Context, token *models.UserToken) error
+	// VerifyEmail consumes an email verification token and marks its user verified
+	VerifyEmail(ctx context.Context, tokenHash string) error
+	// ResetPassword consumes a password reset token, stores the new password hash,
+	// invalidates the user's other reset tokens and returns the user ID
+	ResetPassword(ctx context.Context, tokenHash, passwordHash string) (uint, error)
+}
+
+type userTokenRepository struct {
+	db *gorm.DB
+}
+
+// NewUserTokenRepository returns a UserTokenRepository backed by db
+func NewUserTokenRepository(db *gorm.DB) UserTokenRepository {
+	return &userTokenRepository{db: db}
+}
+
+func (r *userTokenRepository) Create(ctx context.Context, token *models.UserToken) error {
+	return r.db.WithContext(ctx).Create(token).Error
+}
+
+func (r *userTokenRepository) VerifyEmail(ctx context.Context, tokenHash string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		token, err := consumeUserToken(tx, tokenHash, models.TokenEmailVerification)
+		if err != nil {
+			return err
+		}
+		return tx.Model(&models.User{}).Where("id = ?", token.UserID).Update("email_verified", true).Error
+	})
+}
+
+func (r *userTokenRepository) ResetPassword(ctx context.Context, tokenHash, passwordHash string) (uint, error) {
+	var userID uint
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		token, err := consumeUserToken(tx, tokenHash, models.TokenPasswordReset)
+		if err != nil {
+			return err
+		}
+		userID = token.UserID
+
+		// Receiving the reset email proves ownership of the address
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"password":       passwordHash,
+			"email_verified": true,
+		}).Error; err != nil {
+			return err
+		}
+
+		// Any other outstanding reset token is no longer needed
+		now := time.Now()
+		return tx.Model(&models.UserToken{}).
+			Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, models.TokenPasswordReset).
+			Update("used_at", &now).Error
+	})
+	return userID, err
+}
+
+// consumeUserToken marks a valid token as used so it cannot be replayed
+func consumeUserToken(tx *gorm.DB, tokenHash string, purpose models.UserTokenPurpose) (models.UserToken, error) {
+	var token models.UserToken
+	if err := tx.Where("token_hash = ? AND purpose = ?", tokenHash, purpose).First(&token).Error; err != nil {
+		return token, ErrInvalidToken
+	}
+
+	if token.UsedAt != nil || time.Now().After(token.ExpiresAt) {
+		return token, ErrInvalidToken
+	}
+
+	now := time.Now()
+	result := tx.Model(&models.UserToken{}).Where("id = ? AND used_at IS NULL", token.ID).Update("used_at", &now)
+	if result.Error != nil {
+		return token, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return token, ErrInvalidToken
+	}
+
+	return token, nil
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"course-api/models"
+	"course-api/utils/query"
+
+	"gorm.io/gorm"
+)
+
+// VideoRepository stores the video courses of materials
+type VideoRepository interface {
+	List(ctx context.Context, params *query.Params) ([]models.VideoCourse, query.Meta, error)
+	ListByMaterial(ctx context.Context, materialID uint, params *query.Params) ([]models.VideoCourse, query.Meta, error)
+	Get(ctx context.Context, id uint) (models.VideoCourse, error)
+	// NextOrder returns the position after the last video of the material
+	NextOrder(ctx context.Context, materialID uint) (int, error)
+	Create(ctx context.Context, video *models.VideoCourse) error
+	// Update saves the video; progress recorded for it follows it to its material
+	Update(ctx context.Context, video *models.VideoCourse) error
+	Delete(ctx context.Context, video *models.VideoCourse) error
+	// Reorder sets the display order of every video of the material and returns them in it
+	Reorder(ctx context.Context, materialID uint, ids []uint) ([]models.VideoCourse, error)
+}
+
+type videoRepository struct {
+	db *gorm.DB
+}
+
+// NewVideoRepository returns a VideoRepository backed by db
+func NewVideoRepository(db *gorm.DB) VideoRepository {
+	return &videoRepository{db: db}
+}
+
+func (r *videoRepository) List(ctx context.Context, params *query.Params) ([]models.VideoCourse, query.Meta, error) {
+	return list[models.VideoCourse](r.db.WithContext(ctx), params)
+}
+
+func (r *videoRepository) ListByMaterial(ctx context.Context, materialID uint, params *query.Params) ([]models.VideoCourse, query.Meta, error) {
+	return list[models.VideoCourse](r.db.WithContext(ctx).Where("material_id = ?", materialID), params)
+}
+
+func (r *videoRepository) Get(ctx context.Context, id uint) (models.VideoCourse, error) {
+	return first[models.VideoCourse](r.db.WithContext(ctx), id)
+}
+
+func (r *videoRepository) NextOrder(ctx context.Context, materialID uint) (int, error) {
+	return nextOrder(r.db.WithContext(ctx), &models.VideoCourse{}, "material_id = ?", materialID)
+}
+
+func (r *videoRepository) Create(ctx context.Context, video *models.VideoCourse) error {
+	return r.db.WithContext(ctx).Create(video).Error
+}
+
+func (r *videoRepository) Update(ctx context.Context, video *models.VideoCourse) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(video).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Progress{}).
+			Where("item_type = ? AND item_id = ? AND material_id <> ?", models.ProgressVideoCourse, video.ID, video.MaterialID).
+			Update("material_id", video.MaterialID).Error
+	})
+}
+
+func (r *videoRepository) Delete(ctx context.Context, video *models.VideoCourse) error {
+	return r.db.WithContext(ctx).Delete(video).Error
+}
+
+func (r *videoRepository) Reorder(ctx context.Context, materialID uint, ids []uint) ([]models.VideoCourse, error) {
+	if err := reorder(r.db.WithContext(ctx), &models.VideoCourse{}, "material_id", materialID, "id", ids); err != nil {
+		return nil, err
+	}
+
+	var videos []models.VideoCourse
+	err := r.db.WithContext(ctx).Where("material_id = ?", materialID).Scopes(inOrder).Find(&videos).Error
+	return videos, err
+}
@@ -0,0 +1,119 @@
+package responses
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SendValidated is SendList for reads clients can revalidate: meta may be nil for
+// a single resource. The response carries an ETag, a hash of the data and meta,
+// and a Last-Modified, the latest UpdatedAt found in the data, nested resources
+// included. A request whose If-None-Match or If-Modified-Since matches them is
+// answered with 304 Not Modified and no body.
+//
+// The ETag is weak because the message may differ for the same data, e.g. "found
+// in cache". Deleting a row does not move Last-Modified, so a list is only
+// reliably revalidated with its ETag, which If-None-Match gives precedence to.
+func SendValidated(c *fiber.Ctx, message string, data interface{}, meta interface{}) error {
+	body := Response{Success: true, Message: message}
+	hash := sha256.New()
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return SendError(c, err)
+	}
+	hash.Write(encoded)
+	body.Data = json.RawMessage(encoded)
+	if meta != nil {
+		encoded, err := json.Marshal(meta)
+		if err != nil {
+			return SendError(c, err)
+		}
+		hash.Write(encoded)
+		body.Meta = json.RawMessage(encoded)
+	}
+
+	modified := latestUpdate(reflect.ValueOf(data))
+	etag := fmt.Sprintf(`W/"%x"`, hash.Sum(nil)[:16])
+	c.Set(fiber.HeaderETag, etag)
+	if !modified.IsZero() {
+		c.Set(fiber.HeaderLastModified, modified.UTC().Format(http.TimeFormat))
+	}
+
+	if notModified(c, etag, modified) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+	return c.Status(fiber.StatusOK).JSON(body)
+}
+
+// notModified evaluates the request's preconditions as RFC 9110 does for GET:
+// If-Modified-Since only counts without If-None-Match, and ETags compare weakly
+func notModified(c *fiber.Ctx, etag string, modified time.Time) bool {
+	if match := c.Get(fiber.HeaderIfNoneMatch); match != "" {
+		for _, candidate := range strings.Split(match, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	since, err := http.ParseTime(c.Get(fiber.HeaderIfModifiedSince))
+	// Last-Modified has a precision of one second
+	return err == nil && !modified.IsZero() && !modified.Truncate(time.Second).After(since)
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// latestUpdate is the latest UpdatedAt time of the structs in v, such as the
+// gorm.Model of a resource and of the resources embedded in it
+func latestUpdate(v reflect.Value) time.Time {
+	var latest time.Time
+	later := func(t time.Time) {
+		if t.After(latest) {
+			latest = t
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if !v.IsNil() {
+			later(latestUpdate(v.Elem()))
+		}
+	case reflect.Slice, reflect.Array:
+		switch v.Type().Elem().Kind() {
+		case reflect.Struct, reflect.Pointer, reflect.Interface, reflect.Slice, reflect.Array, reflect.Map:
+			for i := 0; i < v.Len(); i++ {
+				later(latestUpdate(v.Index(i)))
+			}
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			later(latestUpdate(iter.Value()))
+		}
+	case reflect.Struct:
+		if v.Type() == timeType {
+			break
+		}
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			if field.Name == "UpdatedAt" && field.Type == timeType {
+				later(v.Field(i).Interface().(time.Time))
+			} else {
+				later(latestUpdate(v.Field(i)))
+			}
+		}
+	}
+	return latest
+}
@@ -1,11 +1,26 @@
 package responses
 
-import "github.com/gofiber/fiber/v2"
+import (
+	"course-api/apperrors"
+	"errors"
+	"log/slog"
 
+	"github.com/gofiber/fiber/v2"
+)
+
+// Response is the envelope of every response, successful or not
 type Response struct {
 	Success bool        `json:"success"`
 	Message string      `json:"message"`
 	Data    interface{} `json:"data"`
+	Meta    interface{} `json:"meta,omitempty"`  // Pagination details on list responses
+	Error   *ErrorBody  `json:"error,omitempty"` // Set on errors only
+}
+
+// ErrorBody tells clients what went wrong in machine-readable form
+type ErrorBody struct {
+	Code    apperrors.Code         `json:"code"`
+	Details []apperrors.FieldError `json:"details,omitempty"` // The invalid fields of VALIDATION_FAILED and INVALID_QUERY
 }
 
 func SendSuccess(c *fiber.Ctx, message string, data interface{}) error {
@@ -16,10 +31,43 @@ func SendSuccess(c *fiber.Ctx, message string, data interface{}) error {
 	})
 }
 
-func SendError(c *fiber.Ctx, status int, message string) error {
-	return c.Status(status).JSON(Response{
-		Success: false,
+// SendList sends a page of results together with its pagination meta
+func SendList(c *fiber.Ctx, message string, data interface{}, meta interface{}) error {
+	return c.Status(fiber.StatusOK).JSON(Response{
+		Success: true,
 		Message: message,
-		Data:    nil,
+		Data:    data,
+		Meta:    meta,
 	})
 }
+
+// SendError answers with err, an *apperrors.Error or a *fiber.Error; any other
+// error is answered as an internal error. The cause of server errors is logged,
+// not sent.
+func SendError(c *fiber.Ctx, err error) error {
+	var appErr *apperrors.Error
+	var fiberErr *fiber.Error
+	switch {
+	case errors.As(err, &appErr):
+	case errors.As(err, &fiberErr):
+		appErr = apperrors.FromStatus(fiberErr.Code, fiberErr.Message)
+	default:
+		appErr = apperrors.From(err)
+	}
+
+	if appErr.Status >= fiber.StatusInternalServerError {
+		slog.ErrorContext(c.UserContext(), appErr.Message, "code", appErr.Code, "error", appErr.Err)
+	}
+
+	return c.Status(appErr.Status).JSON(Response{
+		Success: false,
+		Message: appErr.Message,
+		Error:   &ErrorBody{Code: appErr.Code, Details: appErr.Details},
+	})
+}
+
+// ErrorHandler is the app's error handler: errors returned by handlers and
+// middleware, and fiber's own such as unknown routes, get the same envelope
+func ErrorHandler(c *fiber.Ctx, err error) error {
+	return SendError(c, err)
+}
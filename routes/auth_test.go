@@ -0,0 +1,284 @@
+package routes_test
+
+import (
+	"course-api/config"
+	"course-api/models"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+func TestAuthRoutes(t *testing.T) {
+	e := newTestEnv(t)
+	e.signUp("existing@example.com", "secret1")
+
+	e.runCases([]routeCase{
+		{
+			name:   "sign up",
+			method: http.MethodPost,
+			path:   "/api/v1/auth/signup",
+			body:   models.SignupInput{Email: "new@example.com", Password: "secret1", FullName: "New User"},
+			status: http.StatusOK,
+		},
+		{
+			name:   "sign up with a registered email",
+			method: http.MethodPost,
+			path:   "/api/v1/auth/signup",
+			body:   models.SignupInput{Email: "existing@example.com", Password: "secret1", FullName: "Again"},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "sign up with a short password",
+			method: http.MethodPost,
+			path:   "/api/v1/auth/signup",
+			body:   models.SignupInput{Email: "short@example.com", Password: "123", FullName: "Short"},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "sign in",
+			method: http.MethodPost,
+			path:   "/api/v1/auth/signin",
+			body:   models.LoginInput{Email: "existing@example.com", Password: "secret1"},
+			status: http.StatusOK,
+		},
+		{
+			name:   "sign in with a wrong password",
+			method: http.MethodPost,
+			path:   "/api/v1/auth/signin",
+			body:   models.LoginInput{Email: "existing@example.com", Password: "wrong-password"},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "sign in with an unknown email",
+			method: http.MethodPost,
+			path:   "/api/v1/auth/signin",
+			body:   models.LoginInput{Email: "nobody@example.com", Password: "secret1"},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "refresh with an unknown token",
+			method: http.MethodPost,
+			path:   "/api/v1/auth/refresh",
+			body:   models.RefreshInput{RefreshToken: "not-a-token"},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "bootstrap admin while disabled",
+			method: http.MethodPost,
+			path:   "/api/v1/auth/bootstrap-admin",
+			body:   map[string]string{"token": "anything", "email": "boss@example.com", "password": "secret1", "full_name": "Boss"},
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "protected route without a token",
+			method: http.MethodGet,
+			path:   "/api/v1/courses/",
+			status: http.StatusUnauthorized,
+		},
+	})
+
+	t.Run("protected route with a malformed token", func(t *testing.T) {
+		status, _, err := e.request(http.MethodGet, "/api/v1/courses/", "not-a-jwt", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status != http.StatusUnauthorized {
+			t.Errorf("status %d, want %d", status, http.StatusUnauthorized)
+		}
+	})
+
+	t.Run("sign up signs in as a student", func(t *testing.T) {
+		var data struct {
+			User struct {
+				Role models.Role `json:"role"`
+			} `json:"user"`
+		}
+		_, resp, err := e.request(http.MethodPost, "/api/v1/auth/signin", "", models.LoginInput{Email: "new@example.com", Password: "secret1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(resp.Data, &data); err != nil {
+			t.Fatal(err)
+		}
+		if data.User.Role != models.RoleStudent {
+			t.Errorf("role %q, want %q", data.User.Role, models.RoleStudent)
+		}
+	})
+}
+
+func TestRefreshAndLogout(t *testing.T) {
+	e := newTestEnv(t)
+
+	var session struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	e.mustDo(http.MethodPost, "/api/v1/auth/signup", "", models.SignupInput{Email: "student@example.com", Password: "secret1", FullName: "Student"}, &session)
+
+	var rotated struct {
+		AccessToken  string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	e.mustDo(http.MethodPost, "/api/v1/auth/refresh", "", models.RefreshInput{RefreshToken: session.RefreshToken}, &rotated)
+	if rotated.RefreshToken == "" || rotated.RefreshToken == session.RefreshToken {
+		t.Fatalf("refresh did not rotate the refresh token")
+	}
+
+	e.mustDo(http.MethodGet, "/api/v1/courses/", rotated.AccessToken, nil, nil)
+
+	// Reusing a rotated refresh token revokes the whole session
+	if status, _ := e.do(http.MethodPost, "/api/v1/auth/refresh", "", models.RefreshInput{RefreshToken: session.RefreshToken}); status != http.StatusUnauthorized {
+		t.Errorf("reused refresh token: status %d, want %d", status, http.StatusUnauthorized)
+	}
+	if status, _ := e.do(http.MethodGet, "/api/v1/courses/", rotated.AccessToken, nil); status != http.StatusUnauthorized {
+		t.Errorf("access token of a revoked session: status %d, want %d", status, http.StatusUnauthorized)
+	}
+
+	// Logging out everywhere revokes the access tokens of every session at once
+	var signedIn struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	e.mustDo(http.MethodPost, "/api/v1/auth/signin", "", models.LoginInput{Email: "student@example.com", Password: "secret1"}, &signedIn)
+	e.mustDo(http.MethodGet, "/api/v1/courses/", signedIn.Token, nil, nil)
+	e.mustDo(http.MethodPost, "/api/v1/auth/logout", "", models.LogoutInput{RefreshToken: signedIn.RefreshToken, All: true}, nil)
+
+	if status, _ := e.do(http.MethodGet, "/api/v1/courses/", signedIn.Token, nil); status != http.StatusUnauthorized {
+		t.Errorf("access token after logging out everywhere: status %d, want %d", status, http.StatusUnauthorized)
+	}
+
+	// Revocations are still found in the database while Redis fails
+	e.redis.SetError("redis unavailable")
+	status, _ := e.do(http.MethodGet, "/api/v1/courses/", signedIn.Token, nil)
+	e.redis.SetError("")
+	if status != http.StatusUnauthorized {
+		t.Errorf("access token of a revoked session while Redis fails: status %d, want %d", status, http.StatusUnauthorized)
+	}
+}
+
+func TestConcurrentSignUps(t *testing.T) {
+	e := newTestEnv(t)
+
+	const requests = 10
+	statuses := make([]int, requests)
+	codes := make([]string, requests)
+	var wg sync.WaitGroup
+	for i := range statuses {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			status, resp, err := e.request(http.MethodPost, "/api/v1/auth/signup", "", models.SignupInput{Email: "same@example.com", Password: "secret1", FullName: "Same"})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			statuses[i] = status
+			if resp.Error != nil {
+				codes[i] = resp.Error.Code
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	created := 0
+	for i, status := range statuses {
+		switch {
+		case status == http.StatusOK:
+			created++
+		case status != http.StatusBadRequest || codes[i] != "EMAIL_ALREADY_REGISTERED":
+			t.Errorf("request %d: status %d with code %q, want success or EMAIL_ALREADY_REGISTERED", i, status, codes[i])
+		}
+	}
+	if created != 1 {
+		t.Errorf("%d requests signed up, want 1", created)
+	}
+}
+
+func TestConcurrentAdminBootstraps(t *testing.T) {
+	e := newTestEnv(t, func(s *config.Settings) {
+		s.Auth.AdminBootstrapToken = "bootstrap-secret"
+	})
+
+	const requests = 10
+	statuses := make([]int, requests)
+	codes := make([]string, requests)
+	var wg sync.WaitGroup
+	for i := range statuses {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			input := models.BootstrapAdminInput{Token: "bootstrap-secret", Email: fmt.Sprintf("boss%d@example.com", i), Password: "secret1", FullName: "Boss"}
+			status, resp, err := e.request(http.MethodPost, "/api/v1/auth/bootstrap-admin", "", input)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			statuses[i] = status
+			if resp.Error != nil {
+				codes[i] = resp.Error.Code
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i, status := range statuses {
+		if status != http.StatusOK && (status != http.StatusForbidden || codes[i] != "ADMIN_ALREADY_EXISTS") {
+			t.Errorf("request %d: status %d with code %q, want success or ADMIN_ALREADY_EXISTS", i, status, codes[i])
+		}
+	}
+
+	var admins int64
+	e.db.Model(&models.User{}).Where("role = ?", models.RoleAdmin).Count(&admins)
+	if admins != 1 {
+		t.Errorf("%d admins created, want 1", admins)
+	}
+}
+
+func TestRequiredEmailVerification(t *testing.T) {
+	e := newTestEnv(t, func(s *config.Settings) {
+		s.Auth.RequireEmailVerification = true
+	})
+
+	t.Run("sign up does not sign in", func(t *testing.T) {
+		var data struct {
+			Token string `json:"token"`
+			User  struct {
+				EmailVerified bool `json:"email_verified"`
+			} `json:"user"`
+		}
+		e.mustDo(http.MethodPost, "/api/v1/auth/signup", "", models.SignupInput{Email: "new@example.com", Password: "secret1", FullName: "New User"}, &data)
+		if data.Token != "" {
+			t.Error("sign up of an unverified account returned a token")
+		}
+		if data.User.EmailVerified {
+			t.Error("new account reported as verified")
+		}
+
+		status, resp, err := e.request(http.MethodPost, "/api/v1/auth/signin", "", models.LoginInput{Email: "new@example.com", Password: "secret1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status != http.StatusForbidden || resp.Error == nil || resp.Error.Code != "EMAIL_NOT_VERIFIED" {
+			t.Errorf("sign in: status %d with %+v, want %d EMAIL_NOT_VERIFIED", status, resp.Error, http.StatusForbidden)
+		}
+	})
+
+	t.Run("refresh is refused once the account is unverified", func(t *testing.T) {
+		user := e.createUser(models.RoleStudent, "secret1")
+		var signedIn struct {
+			RefreshToken string `json:"refresh_token"`
+		}
+		e.mustDo(http.MethodPost, "/api/v1/auth/signin", "", models.LoginInput{Email: user.Email, Password: "secret1"}, &signedIn)
+		e.db.Model(&models.User{}).Where("id = ?", user.ID).Update("email_verified", false)
+
+		status, resp, err := e.request(http.MethodPost, "/api/v1/auth/refresh", "", models.RefreshInput{RefreshToken: signedIn.RefreshToken})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status != http.StatusForbidden || resp.Error == nil || resp.Error.Code != "EMAIL_NOT_VERIFIED" {
+			t.Errorf("refresh: status %d with %+v, want %d EMAIL_NOT_VERIFIED", status, resp.Error, http.StatusForbidden)
+		}
+	})
+}
@@ -0,0 +1,537 @@
+package routes_test
+
+import (
+	"context"
+	"course-api/config"
+	"course-api/models"
+	"course-api/utils/cache"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+func TestCacheTagInvalidation(t *testing.T) {
+	e := newTestEnv(t)
+	student := e.tokenFor(models.RoleStudent)
+	admin := e.tokenFor(models.RoleAdmin)
+
+	created := e.createMaterial("Go Basics")
+	material := fmt.Sprintf("/api/v1/materials/%d", created.ID)
+	topic := fmt.Sprintf("/api/v1/content/%d", created.Content[0].ID)
+	video := fmt.Sprintf("/api/v1/videos/%d", created.VideoCourses[0].ID)
+	course := fmt.Sprintf("/api/v1/courses/%d", e.createCourse("Go"))
+	e.mustDo(http.MethodPost, course+"/materials", admin, models.AttachMaterialInput{MaterialID: created.ID}, nil)
+
+	// readAll fills the cache with every read embedding the material
+	readAll := func() {
+		for _, path := range []string{material, topic, video, course + "/curriculum", "/api/v1/materials/?include=content", fmt.Sprintf("/api/v1/videos/material/%d", created.ID)} {
+			e.mustDo(http.MethodGet, path, student, nil, nil)
+			if _, resp := e.do(http.MethodGet, path, student, nil); !strings.HasSuffix(resp.Message, "found in cache") {
+				t.Fatalf("GET %s: message %q, want it served from cache", path, resp.Message)
+			}
+		}
+	}
+
+	t.Run("topic update", func(t *testing.T) {
+		readAll()
+		e.mustDo(http.MethodPut, topic, admin, models.UpdateContentTopicInput{Title: "Hello, Go"}, nil)
+
+		var got models.Material
+		e.mustDo(http.MethodGet, material, student, nil, &got)
+		if got.Content[0].Title != "Hello, Go" {
+			t.Errorf("material topic title %q, want the update", got.Content[0].Title)
+		}
+		var curriculum models.CurriculumCourse
+		e.mustDo(http.MethodGet, course+"/curriculum", student, nil, &curriculum)
+		if title := curriculum.Materials[0].Content[0].Title; title != "Hello, Go" {
+			t.Errorf("curriculum topic title %q, want the update", title)
+		}
+	})
+
+	t.Run("video update", func(t *testing.T) {
+		readAll()
+		e.mustDo(http.MethodPut, video, admin, models.UpdateVideoCourseInput{Title: "Welcome back"}, nil)
+
+		var videos []models.VideoCourse
+		e.mustDo(http.MethodGet, fmt.Sprintf("/api/v1/videos/material/%d", created.ID), student, nil, &videos)
+		if len(videos) != 1 || videos[0].Title != "Welcome back" {
+			t.Errorf("material videos %+v, want the update", videos)
+		}
+		var got models.Material
+		e.mustDo(http.MethodGet, material, student, nil, &got)
+		if got.VideoCourses[0].Title != "Welcome back" {
+			t.Errorf("material video title %q, want the update", got.VideoCourses[0].Title)
+		}
+	})
+
+	t.Run("material delete", func(t *testing.T) {
+		readAll()
+		e.mustDo(http.MethodDelete, material, admin, nil, nil)
+
+		for _, path := range []string{material, topic, video} {
+			if status, _ := e.do(http.MethodGet, path, student, nil); status != http.StatusNotFound {
+				t.Errorf("GET %s after delete: status %d, want %d", path, status, http.StatusNotFound)
+			}
+		}
+		var curriculum models.CurriculumCourse
+		e.mustDo(http.MethodGet, course+"/curriculum", student, nil, &curriculum)
+		if len(curriculum.Materials) != 0 {
+			t.Errorf("curriculum still lists %d materials", len(curriculum.Materials))
+		}
+	})
+
+	// Failed reads are not cached
+	if status, _ := e.do(http.MethodGet, "/api/v1/videos/material/9999", student, nil); status != http.StatusNotFound {
+		t.Errorf("videos of a missing material: status %d, want %d", status, http.StatusNotFound)
+	}
+	if e.cached("videos:material:9999:list") {
+		t.Errorf("a missing material was cached")
+	}
+}
+
+func TestCacheListKeys(t *testing.T) {
+	e := newTestEnv(t)
+	student := e.tokenFor(models.RoleStudent)
+	e.createMaterial("Go")
+
+	// listEntries counts the cached material lists
+	listEntries := func() int {
+		n := 0
+		for _, key := range e.cacheKeys() {
+			if strings.HasPrefix(key, "materials:") && strings.Contains(key, ":list") {
+				n++
+			}
+		}
+		return n
+	}
+
+	cases := []struct {
+		name    string
+		queries []string
+	}{
+		{"defaults and unknown parameters", []string{"", "?page=1", "?limit=20&sort=id", "?junk=1", "?junk=2&other=x"}},
+		{"filters in any order", []string{"?q=go&duration_min=1", "?duration_min=1&q=go&junk=1"}},
+		{"includes in any order", []string{"?include=content,videoCourses", "?include=videoCourses,content,content&junk=1"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			before := listEntries()
+			for _, q := range tc.queries {
+				e.mustDo(http.MethodGet, "/api/v1/materials/"+q, student, nil, nil)
+			}
+			if n := listEntries() - before; n != 1 {
+				t.Errorf("%d entries cached for %q, want 1", n, tc.queries)
+			}
+		})
+	}
+
+	if !e.cached("materials:list") {
+		t.Errorf("the default list is not cached under materials:list, keys %q", e.cacheKeys())
+	}
+
+	t.Run("values holding separators", func(t *testing.T) {
+		// The second query searches for the literal title "a&instructor=b"
+		e.mustDo(http.MethodGet, "/api/v1/videos/?q=a&instructor=b", student, nil, nil)
+		e.mustDo(http.MethodGet, "/api/v1/videos/?q=a%26instructor%3Db", student, nil, nil)
+
+		n := 0
+		for _, key := range e.cacheKeys() {
+			if strings.HasPrefix(key, "videos:list:") {
+				n++
+			}
+		}
+		if n != 2 {
+			t.Errorf("%d video lists cached for two different queries, want 2", n)
+		}
+	})
+}
+
+func TestCacheAdmin(t *testing.T) {
+	e := newTestEnv(t)
+	student := e.tokenFor(models.RoleStudent)
+	admin := e.tokenFor(models.RoleAdmin)
+
+	// Data of other applications sharing Redis
+	e.redis.Set("session:1", "keep me")
+
+	courseID, materialID := e.createCourse("Go"), e.createMaterial("Go Basics").ID
+	for _, path := range []string{fmt.Sprintf("/api/v1/courses/%d", courseID), "/api/v1/courses/", fmt.Sprintf("/api/v1/materials/%d", materialID)} {
+		e.mustDo(http.MethodGet, path, student, nil, nil)
+	}
+
+	e.runCases([]routeCase{
+		{name: "stats as student", method: http.MethodGet, path: "/api/v1/admin/cache", role: models.RoleStudent, status: http.StatusForbidden},
+		{name: "purge as mentor", method: http.MethodDelete, path: "/api/v1/admin/cache", role: models.RoleMentor, status: http.StatusForbidden},
+		{name: "purge the tag sets", method: http.MethodDelete, path: "/api/v1/admin/cache/tag", role: models.RoleAdmin, status: http.StatusBadRequest},
+	})
+
+	var stats cache.Stats
+	e.mustDo(http.MethodGet, "/api/v1/admin/cache", admin, nil, &stats)
+	// Every key of the cache is counted, the tags' invalidation counters included
+	stored := len(e.redis.Keys()) - 1
+	if !stats.Enabled || stats.Keys != int64(stored) || stats.MemoryBytes <= 0 {
+		t.Errorf("stats %+v, want the %d cached keys", stats, stored)
+	}
+	namespaces := map[string]cache.NamespaceStats{}
+	for _, ns := range stats.Namespaces {
+		namespaces[ns.Namespace] = ns
+	}
+	// Kept for the 15m TTL, its jitter of up to 10% and 1m for serving it stale
+	if ns := namespaces["courses"]; ns.Keys != 2 || ns.MinTTLSeconds < 15*60 || ns.MaxTTLSeconds > 15*66+60 || ns.NoTTL != 0 {
+		t.Errorf("courses namespace %+v, want 2 keys expiring after 16m to 17m30s", ns)
+	}
+	if namespaces["materials"].Keys != 1 || namespaces["tag"].Keys == 0 {
+		t.Errorf("namespaces %+v, want materials and tag sets counted", stats.Namespaces)
+	}
+
+	var purged struct {
+		Deleted int64 `json:"deleted"`
+	}
+	e.mustDo(http.MethodDelete, "/api/v1/admin/cache/courses", admin, nil, &purged)
+	if purged.Deleted != 2 || e.cached(fmt.Sprintf("courses:%d", courseID)) || !e.cached(fmt.Sprintf("materials:%d", materialID)) {
+		t.Errorf("purging courses deleted %d keys, left %v", purged.Deleted, e.cacheKeys())
+	}
+
+	e.mustDo(http.MethodDelete, "/api/v1/admin/cache", admin, nil, &purged)
+	if keys := e.cacheKeys(); len(keys) != 0 || purged.Deleted == 0 {
+		t.Errorf("purging the cache deleted %d keys, left %v", purged.Deleted, keys)
+	}
+	if !e.redis.Exists("session:1") {
+		t.Errorf("purging the cache deleted data outside its prefix")
+	}
+}
+
+// slowQueries counts the queries on table, each delayed by delay so concurrent
+// requests overlap while it loads
+func (e *testEnv) slowQueries(table string, delay time.Duration) *atomic.Int64 {
+	e.t.Helper()
+
+	var count atomic.Int64
+	err := e.db.Callback().Query().Before("gorm:query").Register("test:slow_"+table, func(tx *gorm.DB) {
+		if tx.Statement.Table == table {
+			count.Add(1)
+			time.Sleep(delay)
+		}
+	})
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	return &count
+}
+
+func TestCacheStampede(t *testing.T) {
+	e := newTestEnv(t)
+	student := e.tokenFor(models.RoleStudent)
+	course := fmt.Sprintf("/api/v1/courses/%d", e.createCourse("Go"))
+	queries := e.slowQueries("courses", 100*time.Millisecond)
+
+	// Concurrent misses of a key share one load
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if status, _, err := e.request(http.MethodGet, course, student, nil); err != nil || status != http.StatusOK {
+				t.Errorf("GET %s: status %d, error %v", course, status, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := queries.Load(); n != 1 {
+		t.Errorf("20 concurrent misses ran %d queries, want 1", n)
+	}
+
+	// Entries cached together expire apart
+	e.mustDo(http.MethodGet, fmt.Sprintf("/api/v1/courses/%d", e.createCourse("Rust")), student, nil, nil)
+	keys := e.cacheKeys()
+	ttls := map[time.Duration]bool{}
+	for _, key := range keys {
+		if strings.HasPrefix(key, "courses:") {
+			ttls[e.redis.TTL(e.prefix+key)] = true
+		}
+	}
+	if len(ttls) != 2 {
+		t.Errorf("two cached courses share their TTL, keys: %v", keys)
+	}
+}
+
+func TestCacheServesStaleWhileRefreshing(t *testing.T) {
+	e := newTestEnv(t, func(s *config.Settings) {
+		s.Cache.TTL = 100 * time.Millisecond
+		s.Cache.Jitter = 0
+	})
+	student := e.tokenFor(models.RoleStudent)
+	courseID := e.createCourse("Go")
+	course := fmt.Sprintf("/api/v1/courses/%d", courseID)
+
+	e.mustDo(http.MethodGet, course, student, nil, nil)
+	time.Sleep(150 * time.Millisecond)
+
+	// Changed behind the cache's back, so only the refresh can pick it up
+	if err := e.db.Model(&models.Course{}).Where("id = ?", courseID).Update("title", "Go Fundamentals").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var got models.Course
+	_, resp := e.do(http.MethodGet, course, student, nil)
+	if err := json.Unmarshal(resp.Data, &got); err != nil || got.Title != "Go" || resp.Message != "Course found in cache" {
+		t.Errorf("expired read: %q with title %q, want the stale course from the cache", resp.Message, got.Title)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for got.Title != "Go Fundamentals" && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+		_, resp := e.do(http.MethodGet, course, student, nil)
+		_ = json.Unmarshal(resp.Data, &got)
+	}
+	if got.Title != "Go Fundamentals" {
+		t.Errorf("the stale course was not refreshed, title %q", got.Title)
+	}
+}
+
+func TestCacheRefreshAfterInvalidation(t *testing.T) {
+	for _, localSize := range []int{64, 0} {
+		t.Run(fmt.Sprintf("local tier of %dMiB", localSize), func(t *testing.T) {
+			e := newTestEnv(t, func(s *config.Settings) {
+				s.Cache.TTL = 500 * time.Millisecond
+				s.Cache.Jitter = 0
+				s.Cache.LocalSize = localSize
+			})
+			student, admin := e.tokenFor(models.RoleStudent), e.tokenFor(models.RoleAdmin)
+			courseID := e.createCourse("Go")
+			course := fmt.Sprintf("/api/v1/courses/%d", courseID)
+
+			e.mustDo(http.MethodGet, course, student, nil, nil)
+			time.Sleep(600 * time.Millisecond)
+
+			// The refresh reads the course, then waits while it is updated
+			loaded, resume := make(chan struct{}), make(chan struct{})
+			var armed atomic.Bool
+			armed.Store(true)
+			err := e.db.Callback().Query().After("gorm:query").Register("test:pause", func(tx *gorm.DB) {
+				if tx.Statement.Table == "courses" && armed.CompareAndSwap(true, false) {
+					close(loaded)
+					<-resume
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			e.mustDo(http.MethodGet, course, student, nil, nil)
+			select {
+			case <-loaded:
+			case <-time.After(5 * time.Second):
+				t.Fatal("the stale course was not refreshed")
+			}
+			e.mustDo(http.MethodPut, course, admin, models.UpdateCourseInput{Title: "Go Fundamentals"}, nil)
+			close(resume)
+			time.Sleep(50 * time.Millisecond)
+
+			var got models.Course
+			e.mustDo(http.MethodGet, course, student, nil, &got)
+			if got.Title != "Go Fundamentals" {
+				t.Errorf("title %q after the refresh, want the update rather than the value loaded before it", got.Title)
+			}
+		})
+	}
+}
+
+func TestCacheUnrelatedInvalidationDuringLoad(t *testing.T) {
+	e := newTestEnv(t)
+	student, admin := e.tokenFor(models.RoleStudent), e.tokenFor(models.RoleAdmin)
+	courseID := e.createCourse("Go")
+	program := fmt.Sprintf("/api/v1/programs/%d", e.createProgram("Backend"))
+
+	// The course load waits while a program, which shares no tag with it, is updated
+	loaded, resume := make(chan struct{}), make(chan struct{})
+	var armed atomic.Bool
+	armed.Store(true)
+	err := e.db.Callback().Query().After("gorm:query").Register("test:pause", func(tx *gorm.DB) {
+		if tx.Statement.Table == "courses" && armed.CompareAndSwap(true, false) {
+			close(loaded)
+			<-resume
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if status, _ := e.do(http.MethodGet, fmt.Sprintf("/api/v1/courses/%d", courseID), student, nil); status != http.StatusOK {
+			t.Errorf("get course: status %d, want %d", status, http.StatusOK)
+		}
+	}()
+	select {
+	case <-loaded:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the course was not loaded")
+	}
+	e.mustDo(http.MethodPut, program, admin, models.UpdateProgramInput{Title: "Backend Go"}, nil)
+	close(resume)
+	<-done
+
+	if !e.cached(fmt.Sprintf("courses:%d", courseID)) {
+		t.Errorf("course not cached in Redis after an unrelated invalidation, keys %q", e.cacheKeys())
+	}
+}
+
+func TestCacheLock(t *testing.T) {
+	e := newTestEnv(t, func(s *config.Settings) {
+		s.Cache.Lock = true
+		s.Cache.LockTimeout = 2 * time.Second
+		s.Cache.LocalSize = 0 // Keys are deleted from Redis behind the cache's back
+	})
+	student := e.tokenFor(models.RoleStudent)
+	courseID := e.createCourse("Go")
+	course := fmt.Sprintf("/api/v1/courses/%d", courseID)
+	courseKey, lock := fmt.Sprintf("courses:%d", courseID), fmt.Sprintf("lock:courses:%d", courseID)
+	queries := e.slowQueries("courses", 100*time.Millisecond)
+
+	get := func() <-chan int {
+		done := make(chan int, 1)
+		go func() {
+			status, _, err := e.request(http.MethodGet, course, student, nil)
+			if err != nil {
+				t.Error(err)
+			}
+			done <- status
+		}()
+		return done
+	}
+
+	t.Run("held while loading", func(t *testing.T) {
+		done := get()
+		time.Sleep(50 * time.Millisecond)
+		if !e.cached(lock) {
+			t.Errorf("no lock while loading, keys: %v", e.cacheKeys())
+		}
+		if status := <-done; status != http.StatusOK {
+			t.Errorf("status %d, want %d", status, http.StatusOK)
+		}
+		if e.cached(lock) {
+			t.Errorf("the lock outlived the load")
+		}
+	})
+
+	t.Run("waits for another instance", func(t *testing.T) {
+		e.redis.Del(e.prefix + courseKey)
+		e.redis.Set(e.prefix+lock, "another instance")
+		before := queries.Load()
+
+		done := get()
+		select {
+		case status := <-done:
+			t.Fatalf("answered with %d while another instance held the lock", status)
+		case <-time.After(200 * time.Millisecond):
+		}
+
+		// The other instance gave up without a value, so the request loads it
+		e.redis.Del(e.prefix + lock)
+		if status := <-done; status != http.StatusOK {
+			t.Errorf("status %d, want %d", status, http.StatusOK)
+		}
+		if n := queries.Load() - before; n != 1 {
+			t.Errorf("%d queries after the lock was released, want 1", n)
+		}
+	})
+}
+
+func TestCacheLocalTier(t *testing.T) {
+	e := newTestEnv(t)
+	student, admin := e.tokenFor(models.RoleStudent), e.tokenFor(models.RoleAdmin)
+	courseID := e.createCourse("Go")
+	course := fmt.Sprintf("/api/v1/courses/%d", courseID)
+	courseKey := fmt.Sprintf("courses:%d", courseID)
+	ctx := context.Background()
+
+	// eventually polls the cache of a replica until it no longer holds key
+	eventually := func(t *testing.T, replica *cache.Cache, key string) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for {
+			var value models.Course
+			err := replica.Get(ctx, key, &value)
+			if errors.Is(err, redis.Nil) {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("%s still cached after the invalidation: %v", key, err)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	t.Run("answers without Redis", func(t *testing.T) {
+		e.mustDo(http.MethodGet, course, student, nil, nil)
+		e.redis.Del(e.prefix + courseKey)
+
+		if _, resp := e.do(http.MethodGet, course, student, nil); resp.Message != "Course found in cache" {
+			t.Errorf("read after the Redis key was evicted: %q, want the course from the in-process tier", resp.Message)
+		}
+	})
+
+	t.Run("writes invalidate replicas", func(t *testing.T) {
+		replica := cache.New(e.client, config.DefaultSettings().Cache, nil)
+		t.Cleanup(func() { replica.Close() })
+
+		// The replica reads the course from Redis into its own tier
+		e.mustDo(http.MethodDelete, "/api/v1/admin/cache/courses", admin, nil, nil)
+		e.mustDo(http.MethodGet, course, admin, nil, nil)
+		var cached models.Course
+		if err := replica.Get(ctx, courseKey, &cached); err != nil {
+			t.Fatalf("replica read: %v", err)
+		}
+		e.mustDo(http.MethodPut, course, admin, models.UpdateCourseInput{Title: "Go Fundamentals", Duration: 10}, nil)
+		eventually(t, replica, courseKey)
+	})
+
+	t.Run("replica writes invalidate the app", func(t *testing.T) {
+		replica := cache.New(e.client, config.DefaultSettings().Cache, nil)
+		t.Cleanup(func() { replica.Close() })
+
+		e.mustDo(http.MethodGet, course, student, nil, nil)
+		if err := replica.Invalidate(ctx, fmt.Sprintf("course:%d", courseID)); err != nil {
+			t.Fatal(err)
+		}
+
+		deadline := time.Now().Add(time.Second)
+		for {
+			_, resp := e.do(http.MethodGet, course, student, nil)
+			if resp.Message == "Course found successfully" {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("read after a replica invalidated the course: %q", resp.Message)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+
+	t.Run("without Redis", func(t *testing.T) {
+		alone := cache.New(nil, config.DefaultSettings().Cache, nil)
+		entry := cache.Entry{Key: courseKey, Tags: []string{"courses"}}
+		if err := alone.Set(ctx, entry, models.Course{Title: "Go"}); err != nil {
+			t.Fatal(err)
+		}
+		var got models.Course
+		if err := alone.Get(ctx, courseKey, &got); err != nil || got.Title != "Go" {
+			t.Fatalf("read %q, %v; want the course cached in process", got.Title, err)
+		}
+		if err := alone.Invalidate(ctx, "courses"); err != nil {
+			t.Fatal(err)
+		}
+		eventually(t, alone, courseKey)
+	})
+}
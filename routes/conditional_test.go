@@ -0,0 +1,119 @@
+package routes_test
+
+import (
+	"course-api/models"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// get sends a GET request with the headers and returns the response, its body read
+func (e *testEnv) get(path, token string, headers map[string]string) (*http.Response, []byte) {
+	e.t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := e.app.Test(req, -1)
+	if err != nil {
+		e.t.Fatalf("GET %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp, body
+}
+
+func TestConditionalRequests(t *testing.T) {
+	e := newTestEnv(t)
+	student, admin := e.tokenFor(models.RoleStudent), e.tokenFor(models.RoleAdmin)
+	material := e.createMaterial("Go")
+	path := fmt.Sprintf("/api/v1/materials/%d", material.ID)
+
+	first, _ := e.get(path, student, nil)
+	etag, lastModified := first.Header.Get("ETag"), first.Header.Get("Last-Modified")
+	if first.StatusCode != http.StatusOK || etag == "" || lastModified == "" {
+		t.Fatalf("status %d with ETag %q and Last-Modified %q", first.StatusCode, etag, lastModified)
+	}
+	if got := first.Header.Get("Cache-Control"); got != "private, max-age=60" {
+		t.Errorf("Cache-Control %q of a material", got)
+	}
+
+	t.Run("cached reads keep the ETag", func(t *testing.T) {
+		if resp, _ := e.get(path, student, nil); resp.Header.Get("ETag") != etag {
+			t.Errorf("ETag %q of the cached material, want %q", resp.Header.Get("ETag"), etag)
+		}
+	})
+
+	t.Run("preconditions", func(t *testing.T) {
+		modified, err := http.ParseTime(lastModified)
+		if err != nil {
+			t.Fatal(err)
+		}
+		earlier := modified.Add(-time.Hour).Format(http.TimeFormat)
+		cases := []struct {
+			name    string
+			headers map[string]string
+			status  int
+		}{
+			{"matching ETag", map[string]string{"If-None-Match": etag}, http.StatusNotModified},
+			{"one of several ETags", map[string]string{"If-None-Match": `"other", ` + etag}, http.StatusNotModified},
+			{"any ETag", map[string]string{"If-None-Match": "*"}, http.StatusNotModified},
+			{"other ETag", map[string]string{"If-None-Match": `W/"other"`}, http.StatusOK},
+			{"not modified since", map[string]string{"If-Modified-Since": lastModified}, http.StatusNotModified},
+			{"modified since", map[string]string{"If-Modified-Since": earlier}, http.StatusOK},
+			{"ETag over date", map[string]string{"If-None-Match": `W/"other"`, "If-Modified-Since": lastModified}, http.StatusOK},
+		}
+		for _, tc := range cases {
+			t.Run(tc.name, func(t *testing.T) {
+				resp, body := e.get(path, student, tc.headers)
+				if resp.StatusCode != tc.status {
+					t.Fatalf("status %d, want %d", resp.StatusCode, tc.status)
+				}
+				if tc.status == http.StatusNotModified {
+					if len(body) != 0 || resp.Header.Get("ETag") != etag || resp.Header.Get("Cache-Control") == "" {
+						t.Errorf("304 with body %q, ETag %q and Cache-Control %q", body, resp.Header.Get("ETag"), resp.Header.Get("Cache-Control"))
+					}
+				}
+			})
+		}
+	})
+
+	t.Run("nested writes change the ETag", func(t *testing.T) {
+		topic := fmt.Sprintf("/api/v1/content/%d", material.Content[0].ID)
+		e.mustDo(http.MethodPut, topic, admin, models.UpdateContentTopicInput{Title: "Hello, Go"}, nil)
+
+		if resp, _ := e.get(path, student, map[string]string{"If-None-Match": etag}); resp.StatusCode != http.StatusOK {
+			t.Errorf("status %d after a topic of the material changed, want %d", resp.StatusCode, http.StatusOK)
+		}
+	})
+
+	t.Run("lists", func(t *testing.T) {
+		list, _ := e.get("/api/v1/materials", student, nil)
+		if got := list.Header.Get("Cache-Control"); got != "private, no-cache" {
+			t.Errorf("Cache-Control %q of a list", got)
+		}
+		listETag := list.Header.Get("ETag")
+		if resp, _ := e.get("/api/v1/materials", student, map[string]string{"If-None-Match": listETag}); resp.StatusCode != http.StatusNotModified {
+			t.Errorf("status %d of an unchanged list, want %d", resp.StatusCode, http.StatusNotModified)
+		}
+
+		e.createMaterial("Rust")
+		if resp, _ := e.get("/api/v1/materials", student, map[string]string{"If-None-Match": listETag}); resp.StatusCode != http.StatusOK {
+			t.Errorf("status %d after a material was added, want %d", resp.StatusCode, http.StatusOK)
+		}
+	})
+
+	t.Run("other routes", func(t *testing.T) {
+		if resp, _ := e.get("/api/v1/me/progress", student, nil); resp.Header.Get("Cache-Control") != "no-store" {
+			t.Errorf("Cache-Control %q of personal data", resp.Header.Get("Cache-Control"))
+		}
+		if resp, _ := e.get("/api/v1/materials/999", student, nil); resp.Header.Get("Cache-Control") != "" || resp.Header.Get("ETag") != "" {
+			t.Errorf("not found answered with Cache-Control %q and ETag %q", resp.Header.Get("Cache-Control"), resp.Header.Get("ETag"))
+		}
+	})
+}
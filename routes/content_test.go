@@ -0,0 +1,146 @@
+package routes_test
+
+import (
+	"course-api/models"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestContentRoutes(t *testing.T) {
+	e := newTestEnv(t)
+	material := e.createMaterial("Syntax")
+	topic := fmt.Sprintf("/api/v1/content/%d", material.Content[0].ID)
+	byMaterial := fmt.Sprintf("/api/v1/content/material/%d", material.ID)
+
+	newTopic := models.CreateContentTopicInput{Title: "Loops", Content: "<p>for</p>", Topics: []string{"loops"}, Order: 1, MaterialID: material.ID}
+
+	e.runCases([]routeCase{
+		{name: "list without a token", method: http.MethodGet, path: byMaterial, status: http.StatusUnauthorized},
+		{name: "list as student", method: http.MethodGet, path: byMaterial, role: models.RoleStudent, status: http.StatusOK},
+		{name: "list with an unknown sort key", method: http.MethodGet, path: byMaterial + "?sort=content", role: models.RoleStudent, status: http.StatusBadRequest},
+		{name: "get as student", method: http.MethodGet, path: topic, role: models.RoleStudent, status: http.StatusOK},
+		{name: "get a missing topic", method: http.MethodGet, path: "/api/v1/content/9999", role: models.RoleStudent, status: http.StatusNotFound},
+
+		{name: "create as student", method: http.MethodPost, path: "/api/v1/content/", role: models.RoleStudent, body: newTopic, status: http.StatusForbidden},
+		{name: "create as mentor", method: http.MethodPost, path: "/api/v1/content/", role: models.RoleMentor, body: newTopic, status: http.StatusOK},
+		{name: "create without a material", method: http.MethodPost, path: "/api/v1/content/", role: models.RoleAdmin, body: models.CreateContentTopicInput{Title: "x", Content: "x", Topics: []string{"x"}}, status: http.StatusBadRequest},
+		{name: "update as student", method: http.MethodPut, path: topic, role: models.RoleStudent, body: models.UpdateContentTopicInput{Title: "Hacked"}, status: http.StatusForbidden},
+		{name: "update as mentor", method: http.MethodPut, path: topic, role: models.RoleMentor, body: models.UpdateContentTopicInput{Title: "Welcome"}, status: http.StatusOK},
+		{name: "update a missing topic", method: http.MethodPut, path: "/api/v1/content/9999", role: models.RoleAdmin, body: models.UpdateContentTopicInput{Title: "x"}, status: http.StatusNotFound},
+	})
+
+	t.Run("list returns the topics of the material", func(t *testing.T) {
+		var topics []models.ContentTopic
+		e.mustDo(http.MethodGet, byMaterial, e.tokenFor(models.RoleStudent), nil, &topics)
+		if len(topics) != 2 || topics[0].Title != "Welcome" || topics[1].Title != "Loops" {
+			t.Errorf("topics %+v, want Welcome then Loops", topics)
+		}
+	})
+
+	e.runCases([]routeCase{
+		{name: "delete as student", method: http.MethodDelete, path: topic, role: models.RoleStudent, status: http.StatusForbidden},
+		{name: "delete as admin", method: http.MethodDelete, path: topic, role: models.RoleAdmin, status: http.StatusOK},
+		{name: "get a deleted topic", method: http.MethodGet, path: topic, role: models.RoleStudent, status: http.StatusNotFound},
+	})
+}
+
+func TestVideoRoutes(t *testing.T) {
+	e := newTestEnv(t)
+	material := e.createMaterial("Syntax")
+	video := fmt.Sprintf("/api/v1/videos/%d", material.VideoCourses[0].ID)
+
+	newVideo := models.CreateVideoCourseInput{Title: "Loops", Description: "for", YoutubeID: "dQw4w9WgXcQ", Duration: "4:20", Instructor: "Ada", Level: "beginner", MaterialID: material.ID}
+
+	e.runCases([]routeCase{
+		{name: "list without a token", method: http.MethodGet, path: "/api/v1/videos/", status: http.StatusUnauthorized},
+		{name: "list as student", method: http.MethodGet, path: "/api/v1/videos/", role: models.RoleStudent, status: http.StatusOK},
+		{name: "list by material", method: http.MethodGet, path: fmt.Sprintf("/api/v1/videos/material/%d", material.ID), role: models.RoleStudent, status: http.StatusOK},
+		{name: "get as student", method: http.MethodGet, path: video, role: models.RoleStudent, status: http.StatusOK},
+		{name: "get a missing video", method: http.MethodGet, path: "/api/v1/videos/9999", role: models.RoleStudent, status: http.StatusNotFound},
+
+		{name: "create as student", method: http.MethodPost, path: "/api/v1/videos/", role: models.RoleStudent, body: newVideo, status: http.StatusForbidden},
+		{name: "create as mentor", method: http.MethodPost, path: "/api/v1/videos/", role: models.RoleMentor, body: newVideo, status: http.StatusOK},
+		{name: "create with a bad duration", method: http.MethodPost, path: "/api/v1/videos/", role: models.RoleAdmin, body: models.CreateVideoCourseInput{Title: "x", Description: "x", YoutubeID: "dQw4w9WgXcQ", Duration: "soon", Instructor: "x", Level: "beginner", MaterialID: material.ID}, status: http.StatusBadRequest},
+		{name: "update as student", method: http.MethodPut, path: video, role: models.RoleStudent, body: models.UpdateVideoCourseInput{Title: "Hacked"}, status: http.StatusForbidden},
+		{name: "update as mentor", method: http.MethodPut, path: video, role: models.RoleMentor, body: models.UpdateVideoCourseInput{Title: "Hello"}, status: http.StatusOK},
+		{name: "list with an unknown level", method: http.MethodGet, path: "/api/v1/videos/?level=expert", role: models.RoleStudent, status: http.StatusBadRequest},
+	})
+
+	// stored loads the material's videos from the database in display order
+	stored := func(t *testing.T) []models.VideoCourse {
+		t.Helper()
+		var videos []models.VideoCourse
+		if err := e.db.Where("material_id = ?", material.ID).Order(`"order", id`).Find(&videos).Error; err != nil {
+			t.Fatal(err)
+		}
+		return videos
+	}
+	byMaterial := fmt.Sprintf("/api/v1/videos/material/%d", material.ID)
+
+	t.Run("writes are stored", func(t *testing.T) {
+		videos := stored(t)
+		if len(videos) != 2 || videos[0].Title != "Hello" || videos[1].Title != "Loops" || videos[1].Instructor != "Ada" {
+			t.Errorf("stored videos %+v, want Hello then Loops", videos)
+		}
+	})
+
+	t.Run("list filters by level", func(t *testing.T) {
+		var videos []models.VideoCourse
+		e.mustDo(http.MethodGet, "/api/v1/videos/?level=advanced", e.tokenFor(models.RoleStudent), nil, &videos)
+		if len(videos) != 0 {
+			t.Errorf("%d advanced videos, want none", len(videos))
+		}
+		e.mustDo(http.MethodGet, "/api/v1/videos/?level=beginner&sort=-title", e.tokenFor(models.RoleStudent), nil, &videos)
+		if len(videos) != 2 || videos[0].Title != "Loops" {
+			t.Errorf("beginner videos %+v, want Loops first", videos)
+		}
+	})
+
+	t.Run("reorder", func(t *testing.T) {
+		videos := stored(t)
+		reversed := []uint{videos[1].ID, videos[0].ID}
+		e.mustDo(http.MethodPut, byMaterial+"/order", e.tokenFor(models.RoleMentor), models.ReorderInput{IDs: reversed}, nil)
+
+		var listed []models.VideoCourse
+		e.mustDo(http.MethodGet, byMaterial, e.tokenFor(models.RoleStudent), nil, &listed)
+		if len(listed) != 2 || listed[0].ID != reversed[0] || listed[1].ID != reversed[1] {
+			t.Errorf("listed videos %+v, want %v", listed, reversed)
+		}
+		if videos := stored(t); videos[0].ID != reversed[0] {
+			t.Errorf("stored order %+v, want %v", videos, reversed)
+		}
+		if status, _ := e.do(http.MethodPut, byMaterial+"/order", e.tokenFor(models.RoleMentor), models.ReorderInput{IDs: reversed[:1]}); status != http.StatusBadRequest {
+			t.Errorf("reordering with a video left out: status %d, want %d", status, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("moving a video moves its progress", func(t *testing.T) {
+		user := e.createUser(models.RoleStudent, "password")
+		progress := models.Progress{UserID: user.ID, ItemType: models.ProgressVideoCourse, ItemID: material.VideoCourses[0].ID, MaterialID: material.ID, CompletedAt: time.Now()}
+		if err := e.db.Create(&progress).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		other := e.createMaterial("Types")
+		e.mustDo(http.MethodPut, video, e.tokenFor(models.RoleMentor), models.UpdateVideoCourseInput{MaterialID: other.ID}, nil)
+
+		if err := e.db.First(&progress, progress.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		if progress.MaterialID != other.ID {
+			t.Errorf("progress of the moved video in material %d, want %d", progress.MaterialID, other.ID)
+		}
+	})
+
+	e.runCases([]routeCase{
+		{name: "delete as student", method: http.MethodDelete, path: video, role: models.RoleStudent, status: http.StatusForbidden},
+		{name: "delete as admin", method: http.MethodDelete, path: video, role: models.RoleAdmin, status: http.StatusOK},
+		{name: "get a deleted video", method: http.MethodGet, path: video, role: models.RoleStudent, status: http.StatusNotFound},
+	})
+
+	if videos := stored(t); len(videos) != 1 || videos[0].Title != "Loops" {
+		t.Errorf("stored videos %+v after the delete, want Loops alone", videos)
+	}
+}
@@ -0,0 +1,118 @@
+package routes_test
+
+import (
+	"course-api/models"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestCourseRoutes(t *testing.T) {
+	e := newTestEnv(t)
+	courseID := e.createCourse("Go Basics")
+	materialID := e.createMaterial("Syntax").ID
+	course := fmt.Sprintf("/api/v1/courses/%d", courseID)
+
+	newCourse := models.CreateCourseInput{Title: "Rust", Description: "Ownership", Instructor: "Grace", Duration: 8, Price: 50}
+
+	e.runCases([]routeCase{
+		{name: "list without a token", method: http.MethodGet, path: "/api/v1/courses/", status: http.StatusUnauthorized},
+		{name: "list as student", method: http.MethodGet, path: "/api/v1/courses/", role: models.RoleStudent, status: http.StatusOK},
+		{name: "list with an unknown sort key", method: http.MethodGet, path: "/api/v1/courses/?sort=secret", role: models.RoleStudent, status: http.StatusBadRequest},
+		{name: "get as student", method: http.MethodGet, path: course, role: models.RoleStudent, status: http.StatusOK},
+		{name: "get a missing course", method: http.MethodGet, path: "/api/v1/courses/9999", role: models.RoleStudent, status: http.StatusNotFound},
+		{name: "curriculum as student", method: http.MethodGet, path: course + "/curriculum", role: models.RoleStudent, status: http.StatusOK},
+
+		{name: "create as student", method: http.MethodPost, path: "/api/v1/courses/", role: models.RoleStudent, body: newCourse, status: http.StatusForbidden},
+		{name: "create as mentor", method: http.MethodPost, path: "/api/v1/courses/", role: models.RoleMentor, body: newCourse, status: http.StatusOK},
+		{name: "create without a title", method: http.MethodPost, path: "/api/v1/courses/", role: models.RoleAdmin, body: models.CreateCourseInput{Description: "x", Instructor: "x", Duration: 1, Price: 1}, status: http.StatusBadRequest},
+		{name: "update as student", method: http.MethodPut, path: course, role: models.RoleStudent, body: models.UpdateCourseInput{Title: "Hacked"}, status: http.StatusForbidden},
+		{name: "update as mentor", method: http.MethodPut, path: course, role: models.RoleMentor, body: models.UpdateCourseInput{Title: "Go Fundamentals"}, status: http.StatusOK},
+		{name: "update a missing course", method: http.MethodPut, path: "/api/v1/courses/9999", role: models.RoleAdmin, body: models.UpdateCourseInput{Title: "x"}, status: http.StatusNotFound},
+
+		{name: "enrollments as student", method: http.MethodGet, path: course + "/enrollments", role: models.RoleStudent, status: http.StatusForbidden},
+		{name: "enrollments as mentor", method: http.MethodGet, path: course + "/enrollments", role: models.RoleMentor, status: http.StatusOK},
+		{name: "attach material as student", method: http.MethodPost, path: course + "/materials", role: models.RoleStudent, body: models.AttachMaterialInput{MaterialID: materialID}, status: http.StatusForbidden},
+		{name: "attach material as mentor", method: http.MethodPost, path: course + "/materials", role: models.RoleMentor, body: models.AttachMaterialInput{MaterialID: materialID}, status: http.StatusOK},
+		{name: "attach the same material again", method: http.MethodPost, path: course + "/materials", role: models.RoleMentor, body: models.AttachMaterialInput{MaterialID: materialID}, status: http.StatusBadRequest},
+		{name: "reorder materials with unknown ids", method: http.MethodPut, path: course + "/materials/order", role: models.RoleAdmin, body: models.ReorderInput{IDs: []uint{materialID, 9999}}, status: http.StatusBadRequest},
+		{name: "reorder materials", method: http.MethodPut, path: course + "/materials/order", role: models.RoleAdmin, body: models.ReorderInput{IDs: []uint{materialID}}, status: http.StatusOK},
+		{name: "detach material", method: http.MethodDelete, path: fmt.Sprintf("%s/materials/%d", course, materialID), role: models.RoleAdmin, status: http.StatusOK},
+		{name: "detach a material that is not attached", method: http.MethodDelete, path: fmt.Sprintf("%s/materials/%d", course, materialID), role: models.RoleAdmin, status: http.StatusNotFound},
+
+		{name: "delete as student", method: http.MethodDelete, path: course, role: models.RoleStudent, status: http.StatusForbidden},
+		{name: "delete as admin", method: http.MethodDelete, path: course, role: models.RoleAdmin, status: http.StatusOK},
+		{name: "get a deleted course", method: http.MethodGet, path: course, role: models.RoleStudent, status: http.StatusNotFound},
+	})
+}
+
+func TestCourseCacheInvalidation(t *testing.T) {
+	e := newTestEnv(t)
+	student := e.tokenFor(models.RoleStudent)
+	admin := e.tokenFor(models.RoleAdmin)
+
+	courseID := e.createCourse("Go Basics")
+	course := fmt.Sprintf("/api/v1/courses/%d", courseID)
+	courseKey := fmt.Sprintf("courses:%d", courseID)
+	listKey := "courses:list"
+
+	// Reads fill the cache
+	e.mustDo(http.MethodGet, "/api/v1/courses/", student, nil, nil)
+	e.mustDo(http.MethodGet, course, student, nil, nil)
+	if !e.cached(listKey) || !e.cached(courseKey) {
+		t.Fatalf("reads did not cache the course, keys: %v", e.cacheKeys())
+	}
+
+	_, resp := e.do(http.MethodGet, course, student, nil)
+	if resp.Message != "Course found in cache" {
+		t.Errorf("second read: message %q, want it served from cache", resp.Message)
+	}
+
+	// Filtered listings are cached apart from the default listing, whatever the parameter order
+	e.mustDo(http.MethodGet, "/api/v1/courses/?q=go&limit=5", student, nil, nil)
+	_, resp = e.do(http.MethodGet, "/api/v1/courses/?limit=5&q=go", student, nil)
+	if resp.Message != "Courses found in cache" {
+		t.Errorf("reordered filtered listing: message %q, want it served from cache", resp.Message)
+	}
+	if len(e.cacheKeys()) != 5 {
+		t.Errorf("want the course, two listings and their tags cached, keys: %v", e.cacheKeys())
+	}
+
+	// Updating drops the cached course and every listing, so the next read sees the change
+	e.mustDo(http.MethodPut, course, admin, models.UpdateCourseInput{Title: "Go Fundamentals"}, nil)
+	if keys := e.cacheKeys(); len(keys) != 0 {
+		t.Errorf("update left cached keys: %v", keys)
+	}
+
+	var updated models.Course
+	e.mustDo(http.MethodGet, course, student, nil, &updated)
+	if updated.Title != "Go Fundamentals" {
+		t.Errorf("read after update: title %q, want %q", updated.Title, "Go Fundamentals")
+	}
+
+	var courses []models.Course
+	e.mustDo(http.MethodGet, "/api/v1/courses/", student, nil, &courses)
+	if len(courses) != 1 || courses[0].Title != "Go Fundamentals" {
+		t.Errorf("listing after update: %+v", courses)
+	}
+
+	// Creating drops the cached listing
+	e.createCourse("Rust")
+	if e.cached(listKey) {
+		t.Errorf("create left the cached listing")
+	}
+	e.mustDo(http.MethodGet, "/api/v1/courses/", student, nil, &courses)
+	if len(courses) != 2 {
+		t.Errorf("listing after create: %d courses, want 2", len(courses))
+	}
+
+	// Deleting drops both, so the deleted course is no longer served
+	e.mustDo(http.MethodGet, course, student, nil, nil)
+	e.mustDo(http.MethodDelete, course, admin, nil, nil)
+	if e.cached(listKey) || e.cached(courseKey) {
+		t.Errorf("delete left cached keys: %v", e.cacheKeys())
+	}
+	if status, _ := e.do(http.MethodGet, course, student, nil); status != http.StatusNotFound {
+		t.Errorf("read after delete: status %d, want %d", status, http.StatusNotFound)
+	}
+}
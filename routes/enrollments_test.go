@@ -0,0 +1,167 @@
+package routes_test
+
+import (
+	"course-api/models"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+func TestEnrollmentRoutes(t *testing.T) {
+	e := newTestEnv(t)
+	student := e.tokenFor(models.RoleStudent)
+	other := e.signUp("other@example.com", "password123")
+	courseID, programID := e.createCourse("Go"), e.createProgram("Backend Track")
+
+	// stored loads an enrollment from the database
+	stored := func(t *testing.T, id uint) models.Enrollment {
+		t.Helper()
+		var enrollment models.Enrollment
+		if err := e.db.First(&enrollment, id).Error; err != nil {
+			t.Fatal(err)
+		}
+		return enrollment
+	}
+
+	var inCourse, inProgram models.Enrollment
+	t.Run("enroll", func(t *testing.T) {
+		e.mustDo(http.MethodPost, "/api/v1/enrollments/", student, models.EnrollInput{CourseID: courseID}, &inCourse)
+		e.mustDo(http.MethodPost, "/api/v1/enrollments/", student, models.EnrollInput{ProgramID: programID}, &inProgram)
+		if inCourse.CourseID == nil || *inCourse.CourseID != courseID || inCourse.ProgramID != nil || inCourse.Status != models.EnrollmentActive {
+			t.Errorf("course enrollment %+v", inCourse)
+		}
+		if inProgram.ProgramID == nil || *inProgram.ProgramID != programID || inProgram.CourseID != nil {
+			t.Errorf("program enrollment %+v", inProgram)
+		}
+		if got := stored(t, inCourse.ID); got.Status != models.EnrollmentActive || got.EnrolledAt.IsZero() {
+			t.Errorf("stored enrollment %+v, want it active with its enrollment time", got)
+		}
+	})
+
+	e.runCases([]routeCase{
+		{name: "enroll without a token", method: http.MethodPost, path: "/api/v1/enrollments/", body: models.EnrollInput{CourseID: courseID}, status: http.StatusUnauthorized},
+		{name: "enroll twice", method: http.MethodPost, path: "/api/v1/enrollments/", role: models.RoleStudent, body: models.EnrollInput{CourseID: courseID}, status: http.StatusBadRequest},
+		{name: "enroll in a course and a program at once", method: http.MethodPost, path: "/api/v1/enrollments/", role: models.RoleStudent, body: models.EnrollInput{CourseID: courseID, ProgramID: programID}, status: http.StatusBadRequest},
+		{name: "enroll in nothing", method: http.MethodPost, path: "/api/v1/enrollments/", role: models.RoleStudent, body: models.EnrollInput{}, status: http.StatusBadRequest},
+		{name: "enroll in a missing course", method: http.MethodPost, path: "/api/v1/enrollments/", role: models.RoleStudent, body: models.EnrollInput{CourseID: 9999}, status: http.StatusNotFound},
+		{name: "enroll in a missing program", method: http.MethodPost, path: "/api/v1/enrollments/", role: models.RoleStudent, body: models.EnrollInput{ProgramID: 9999}, status: http.StatusNotFound},
+		{name: "list with an unknown status", method: http.MethodGet, path: "/api/v1/enrollments/me?status=paused", role: models.RoleStudent, status: http.StatusBadRequest},
+		{name: "update status as student", method: http.MethodPut, path: fmt.Sprintf("/api/v1/enrollments/%d/status", inCourse.ID), role: models.RoleStudent, body: models.UpdateEnrollmentStatusInput{Status: models.EnrollmentCompleted}, status: http.StatusForbidden},
+		{name: "update the status of a missing enrollment", method: http.MethodPut, path: "/api/v1/enrollments/9999/status", role: models.RoleMentor, body: models.UpdateEnrollmentStatusInput{Status: models.EnrollmentCompleted}, status: http.StatusNotFound},
+		{name: "course enrollments as student", method: http.MethodGet, path: fmt.Sprintf("/api/v1/courses/%d/enrollments", courseID), role: models.RoleStudent, status: http.StatusForbidden},
+	})
+
+	t.Run("my enrollments", func(t *testing.T) {
+		var mine []models.Enrollment
+		e.mustDo(http.MethodGet, "/api/v1/enrollments/me", student, nil, &mine)
+		if len(mine) != 2 {
+			t.Fatalf("%d enrollments, want 2", len(mine))
+		}
+		for _, enrollment := range mine {
+			if (enrollment.Course == nil || enrollment.Course.Title != "Go") && (enrollment.Program == nil || enrollment.Program.Title != "Backend Track") {
+				t.Errorf("enrollment %+v without its course or program", enrollment)
+			}
+		}
+
+		var others []models.Enrollment
+		e.mustDo(http.MethodGet, "/api/v1/enrollments/me", other, nil, &others)
+		if len(others) != 0 {
+			t.Errorf("another user sees %d enrollments", len(others))
+		}
+	})
+
+	t.Run("course enrollments", func(t *testing.T) {
+		var enrollments []models.Enrollment
+		e.mustDo(http.MethodGet, fmt.Sprintf("/api/v1/courses/%d/enrollments", courseID), e.tokenFor(models.RoleAdmin), nil, &enrollments)
+		if len(enrollments) != 1 || enrollments[0].User == nil || enrollments[0].User.ID != inCourse.UserID {
+			t.Errorf("course enrollments %+v, want the student's", enrollments)
+		}
+	})
+
+	t.Run("unenroll", func(t *testing.T) {
+		path := fmt.Sprintf("/api/v1/enrollments/%d", inCourse.ID)
+		if status, _ := e.do(http.MethodDelete, path, other, nil); status != http.StatusForbidden {
+			t.Errorf("unenrolling another user: status %d, want %d", status, http.StatusForbidden)
+		}
+
+		e.mustDo(http.MethodDelete, path, student, nil, nil)
+		if got := stored(t, inCourse.ID); got.Status != models.EnrollmentCancelled || got.CancelledAt == nil {
+			t.Errorf("stored enrollment %+v, want it cancelled", got)
+		}
+		if status, resp := e.do(http.MethodDelete, path, student, nil); status != http.StatusBadRequest || resp.Error == nil || resp.Error.Code != "ENROLLMENT_ALREADY_CANCELLED" {
+			t.Errorf("unenrolling twice: status %d with %+v", status, resp.Error)
+		}
+
+		var cancelled []models.Enrollment
+		e.mustDo(http.MethodGet, "/api/v1/enrollments/me?status=cancelled", student, nil, &cancelled)
+		if len(cancelled) != 1 || cancelled[0].ID != inCourse.ID {
+			t.Errorf("cancelled enrollments %+v, want the course enrollment", cancelled)
+		}
+	})
+
+	t.Run("enrolling again reactivates the enrollment", func(t *testing.T) {
+		var again models.Enrollment
+		e.mustDo(http.MethodPost, "/api/v1/enrollments/", student, models.EnrollInput{CourseID: courseID}, &again)
+		if again.ID != inCourse.ID || again.Status != models.EnrollmentActive {
+			t.Errorf("enrollment %+v, want enrollment %d active again", again, inCourse.ID)
+		}
+		if got := stored(t, inCourse.ID); got.Status != models.EnrollmentActive || got.CancelledAt != nil {
+			t.Errorf("stored enrollment %+v, want it active", got)
+		}
+	})
+
+	t.Run("update status", func(t *testing.T) {
+		var updated models.Enrollment
+		e.mustDo(http.MethodPut, fmt.Sprintf("/api/v1/enrollments/%d/status", inProgram.ID), e.tokenFor(models.RoleMentor), models.UpdateEnrollmentStatusInput{Status: models.EnrollmentCompleted}, &updated)
+		if got := stored(t, inProgram.ID); got.Status != models.EnrollmentCompleted || got.CompletedAt == nil || updated.Status != models.EnrollmentCompleted {
+			t.Errorf("stored enrollment %+v, want it completed", got)
+		}
+	})
+}
+
+func TestConcurrentEnrollments(t *testing.T) {
+	e := newTestEnv(t)
+	student := e.tokenFor(models.RoleStudent)
+	courseID := e.createCourse("Go")
+
+	const requests = 10
+	statuses := make([]int, requests)
+	codes := make([]string, requests)
+	var wg sync.WaitGroup
+	for i := range statuses {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			status, resp, err := e.request(http.MethodPost, "/api/v1/enrollments/", student, models.EnrollInput{CourseID: courseID})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			statuses[i] = status
+			if resp.Error != nil {
+				codes[i] = resp.Error.Code
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	enrolled := 0
+	for i, status := range statuses {
+		switch {
+		case status == http.StatusOK:
+			enrolled++
+		case status != http.StatusBadRequest || codes[i] != "ALREADY_ENROLLED":
+			t.Errorf("request %d: status %d with code %q, want success or ALREADY_ENROLLED", i, status, codes[i])
+		}
+	}
+	if enrolled != 1 {
+		t.Errorf("%d requests enrolled, want 1", enrolled)
+	}
+
+	var rows int64
+	e.db.Model(&models.Enrollment{}).Where("course_id = ?", courseID).Count(&rows)
+	if rows != 1 {
+		t.Errorf("%d enrollments stored, want 1", rows)
+	}
+}
@@ -0,0 +1,121 @@
+package routes_test
+
+import (
+	"course-api/models"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestErrorResponses(t *testing.T) {
+	e := newTestEnv(t)
+	student := e.tokenFor(models.RoleStudent)
+	admin := e.tokenFor(models.RoleAdmin)
+	material := fmt.Sprintf("/api/v1/materials/%d", e.createMaterial("Syntax").ID)
+
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		body   string
+		status int
+		code   string
+		fields []string // Fields expected in the details
+	}{
+		{"missing course", http.MethodGet, "/api/v1/courses/9999", student, "", http.StatusNotFound, "COURSE_NOT_FOUND", nil},
+		{"missing program", http.MethodGet, "/api/v1/programs/9999", student, "", http.StatusNotFound, "PROGRAM_NOT_FOUND", nil},
+		{"no token", http.MethodGet, "/api/v1/courses/", "", "", http.StatusUnauthorized, "MISSING_TOKEN", nil},
+		{"bad token", http.MethodGet, "/api/v1/courses/", "not-a-jwt", "", http.StatusUnauthorized, "INVALID_TOKEN", nil},
+		{"wrong role", http.MethodPost, "/api/v1/courses/", student, `{}`, http.StatusForbidden, "INSUFFICIENT_PERMISSIONS", nil},
+		{"malformed body", http.MethodPost, "/api/v1/courses/", admin, `{"title":`, http.StatusBadRequest, "INVALID_INPUT", nil},
+		{"invalid fields", http.MethodPost, "/api/v1/courses/", admin, `{"description":"x","instructor":"x","duration":1,"price":1}`, http.StatusBadRequest, "VALIDATION_FAILED", []string{"title"}},
+		{"invalid nested item", http.MethodPut, material, admin, `{"content":[{"title":"Loops"}]}`, http.StatusBadRequest, "VALIDATION_FAILED", []string{"content[0].content", "content[0].topics"}},
+		{"invalid new nested item", http.MethodPost, "/api/v1/materials/", admin, `{"title":"x","description":"x","icon":"x","duration":1,"lessons":1,"learningPoints":["x"],"content":[{"title":"x","content":"x","topics":["x"]}],"videoCourses":[{"title":"x","description":"x","youtube_id":"not a video","duration":"12:30","instructor":"x","level":"beginner"}]}`, http.StatusBadRequest, "VALIDATION_FAILED", []string{"videoCourses[0].youtube_id"}},
+		{"invalid query", http.MethodGet, "/api/v1/courses/?limit=0", student, "", http.StatusBadRequest, "INVALID_QUERY", []string{"limit"}},
+		{"unknown route", http.MethodGet, "/api/v1/nothing-here", student, "", http.StatusNotFound, "ROUTE_NOT_FOUND", nil},
+		{"wrong credentials", http.MethodPost, "/api/v1/auth/signin", "", `{"email":"nobody@example.com","password":"secret1"}`, http.StatusUnauthorized, "INVALID_CREDENTIALS", nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			resp := decodeError(t, e, req)
+
+			if resp.status != tc.status {
+				t.Errorf("status %d, want %d", resp.status, tc.status)
+			}
+			if resp.Success || resp.Error == nil {
+				t.Fatalf("response %+v is not an error envelope", resp.apiResponse)
+			}
+			if resp.Error.Code != tc.code {
+				t.Errorf("code %s, want %s", resp.Error.Code, tc.code)
+			}
+			if resp.Message == "" {
+				t.Errorf("error without a message")
+			}
+
+			var fields []string
+			for _, detail := range resp.Error.Details {
+				fields = append(fields, detail.Field)
+			}
+			if strings.Join(fields, ",") != strings.Join(tc.fields, ",") {
+				t.Errorf("detail fields %v, want %v", fields, tc.fields)
+			}
+		})
+	}
+}
+
+func TestDatabaseFailureIsNotNotFound(t *testing.T) {
+	e := newTestEnv(t)
+	student := e.tokenFor(models.RoleStudent)
+
+	if err := e.db.Migrator().DropTable("programs"); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := decodeError(t, e, httptest.NewRequest(http.MethodGet, "/api/v1/programs/1", nil), "Bearer "+student)
+	if resp.status != http.StatusInternalServerError {
+		t.Errorf("status %d, want %d", resp.status, http.StatusInternalServerError)
+	}
+	if resp.Error == nil || resp.Error.Code != "INTERNAL_ERROR" {
+		t.Fatalf("error %+v, want INTERNAL_ERROR", resp.Error)
+	}
+	if strings.Contains(strings.ToLower(resp.Message), "no such table") {
+		t.Errorf("message %q reveals the database error", resp.Message)
+	}
+}
+
+// errorResponse is a decoded response with its status code
+type errorResponse struct {
+	apiResponse
+	status int
+}
+
+// decodeError sends req, with the Authorization header when given, and decodes the envelope
+func decodeError(t *testing.T, e *testEnv, req *http.Request, authorization ...string) errorResponse {
+	t.Helper()
+
+	if len(authorization) > 0 {
+		req.Header.Set("Authorization", authorization[0])
+	}
+	resp, err := e.app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	raw, _ := io.ReadAll(resp.Body)
+	out := errorResponse{status: resp.StatusCode}
+	if err := json.Unmarshal(raw, &out.apiResponse); err != nil {
+		t.Fatalf("decode %q: %v", raw, err)
+	}
+	return out
+}
@@ -0,0 +1,53 @@
+package routes_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestHealthRoutes(t *testing.T) {
+	e := newTestEnv(t)
+
+	if status, _ := e.do(http.MethodGet, "/healthz", "", nil); status != http.StatusOK {
+		t.Errorf("healthz: status %d, want %d", status, http.StatusOK)
+	}
+
+	readiness := func() (int, map[string]string) {
+		t.Helper()
+
+		status, resp := e.do(http.MethodGet, "/readyz", "", nil)
+		var report map[string]string
+		if err := json.Unmarshal(resp.Data, &report); err != nil {
+			t.Fatalf("decode readiness: %v", err)
+		}
+		return status, report
+	}
+
+	status, report := readiness()
+	if status != http.StatusOK || report["status"] != "ok" {
+		t.Errorf("ready: status %d, report %v", status, report)
+	}
+
+	// Redis is optional, so losing it degrades the service without taking it out of rotation
+	e.redis.Close()
+	status, report = readiness()
+	if status != http.StatusOK || report["status"] != "degraded" || report["redis"] != "down" {
+		t.Errorf("without redis: status %d, report %v", status, report)
+	}
+
+	// Without its database the service is not ready
+	sqlDB, err := e.db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.Close()
+	status, report = readiness()
+	if status != http.StatusServiceUnavailable || report["status"] != "down" || report["database"] != "down" {
+		t.Errorf("without database: status %d, report %v", status, report)
+	}
+
+	if status, _ := e.do(http.MethodGet, "/healthz", "", nil); status != http.StatusOK {
+		t.Errorf("healthz without dependencies: status %d, want %d", status, http.StatusOK)
+	}
+}
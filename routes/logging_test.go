@@ -0,0 +1,176 @@
+package routes_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"course-api/middleware"
+	"course-api/models"
+	"course-api/utils/logging"
+	"course-api/utils/mailer"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// captureLogs sends debug records to a buffer as JSON until the test ends
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, "debug", logging.FormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := slog.Default()
+	slog.SetDefault(logger)
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &buf
+}
+
+// logRecords decodes the captured JSON records
+func logRecords(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+
+	var records []map[string]interface{}
+	scanner := bufio.NewScanner(bytes.NewReader(buf.Bytes()))
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var record map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("decode log record %q: %v", scanner.Text(), err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestRequestID(t *testing.T) {
+	e := newTestEnv(t)
+
+	cases := []struct {
+		name   string
+		header string
+		want   string // Empty when a new ID must be generated
+	}{
+		{"echoes the caller's ID", "client-42.retry_1", "client-42.retry_1"},
+		{"generates a missing ID", "", ""},
+		{"replaces an unsafe ID", "bad id\"", ""},
+		{"replaces an overlong ID", strings.Repeat("a", 129), ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/courses", nil)
+			if tc.header != "" {
+				req.Header.Set(middleware.RequestIDHeader, tc.header)
+			}
+			resp, err := e.app.Test(req, -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			got := resp.Header.Get(middleware.RequestIDHeader)
+			switch {
+			case tc.want != "" && got != tc.want:
+				t.Errorf("request ID %q, want %q", got, tc.want)
+			case tc.want == "" && (got == "" || got == tc.header):
+				t.Errorf("request ID %q was not generated", got)
+			}
+		})
+	}
+}
+
+func TestRequestLogging(t *testing.T) {
+	e := newTestEnv(t)
+	user := e.createUser(models.RoleStudent, "s3cret-pass")
+	token := e.signIn(user.Email, "s3cret-pass")
+	course := e.createCourse("Go Basics")
+
+	buf := captureLogs(t)
+	req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/courses/%d", course), nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set(middleware.RequestIDHeader, "trace-me")
+	resp, err := e.app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	var access, query map[string]interface{}
+	for _, record := range logRecords(t, buf) {
+		switch record["msg"] {
+		case "request":
+			access = record
+		case "query":
+			if query == nil {
+				query = record
+			}
+		}
+	}
+
+	if access == nil {
+		t.Fatalf("no access log record in %s", buf)
+	}
+	for key, want := range map[string]interface{}{
+		"request_id": "trace-me",
+		"method":     http.MethodGet,
+		"route":      "/api/v1/courses/:id",
+		"user_id":    float64(user.ID),
+		"status":     float64(http.StatusOK),
+	} {
+		if access[key] != want {
+			t.Errorf("access log %s = %v, want %v", key, access[key], want)
+		}
+	}
+	if _, ok := access["latency_ms"]; !ok {
+		t.Errorf("access log has no latency_ms")
+	}
+
+	if query == nil {
+		t.Fatalf("no query log record in %s", buf)
+	}
+	if query["request_id"] != "trace-me" {
+		t.Errorf("query log request_id = %v, want trace-me", query["request_id"])
+	}
+
+	if strings.Contains(buf.String(), token) {
+		t.Errorf("access token was logged")
+	}
+}
+
+func TestLogRedaction(t *testing.T) {
+	e := newTestEnv(t)
+	buf := captureLogs(t)
+
+	// Signing up writes the password hash, which must not appear in SQL logs
+	e.signUp("redact@example.com", "hunter2-pass")
+	var user models.User
+	if err := e.db.Where("email = ?", "redact@example.com").First(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	slog.Info("credentials", "password", "hunter2-pass", "refresh_token", "rt-value", "header", "Bearer abc.def")
+
+	// Mail bodies carry verification and reset tokens
+	if err := (mailer.LogMailer{}).Send(context.Background(), mailer.Message{To: user.Email, Subject: "Reset your password", Body: "Use the token reset-token-value"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "Reset your password") {
+		t.Error("log does not contain the mail subject")
+	}
+
+	out := buf.String()
+	for _, secret := range []string{"hunter2-pass", user.Password, "rt-value", "abc.def", "reset-token-value"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("log contains secret %q", secret)
+		}
+	}
+	if !strings.Contains(out, logging.Redacted) {
+		t.Errorf("log does not contain %s", logging.Redacted)
+	}
+}
@@ -0,0 +1,61 @@
+package routes_test
+
+import (
+	"course-api/models"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestMaterialRoutes(t *testing.T) {
+	e := newTestEnv(t)
+	created := e.createMaterial("Syntax")
+	material := fmt.Sprintf("/api/v1/materials/%d", created.ID)
+
+	newMaterial := map[string]interface{}{
+		"title":          "Testing",
+		"description":    "Table tests",
+		"icon":           "check",
+		"duration":       3,
+		"lessons":        1,
+		"learningPoints": []string{"Subtests"},
+		"content":        []map[string]interface{}{},
+		"videoCourses":   []map[string]interface{}{},
+	}
+
+	e.runCases([]routeCase{
+		{name: "list without a token", method: http.MethodGet, path: "/api/v1/materials/", status: http.StatusUnauthorized},
+		{name: "list as student", method: http.MethodGet, path: "/api/v1/materials/", role: models.RoleStudent, status: http.StatusOK},
+		{name: "list with children", method: http.MethodGet, path: "/api/v1/materials/?include=content,videoCourses", role: models.RoleStudent, status: http.StatusOK},
+		{name: "list with an unknown include", method: http.MethodGet, path: "/api/v1/materials/?include=secrets", role: models.RoleStudent, status: http.StatusBadRequest},
+		{name: "get as student", method: http.MethodGet, path: material, role: models.RoleStudent, status: http.StatusOK},
+		{name: "get a missing material", method: http.MethodGet, path: "/api/v1/materials/9999", role: models.RoleStudent, status: http.StatusNotFound},
+
+		{name: "create as student", method: http.MethodPost, path: "/api/v1/materials/", role: models.RoleStudent, body: newMaterial, status: http.StatusForbidden},
+		{name: "create as mentor", method: http.MethodPost, path: "/api/v1/materials/", role: models.RoleMentor, body: newMaterial, status: http.StatusOK},
+		{name: "create without a title", method: http.MethodPost, path: "/api/v1/materials/", role: models.RoleAdmin, body: models.CreateMaterialInput{Description: "x", Icon: "x", Duration: 1, Lessons: 1}, status: http.StatusBadRequest},
+		{name: "update as student", method: http.MethodPut, path: material, role: models.RoleStudent, body: models.UpdateMaterialInput{Title: "Hacked"}, status: http.StatusForbidden},
+		{name: "update as mentor", method: http.MethodPut, path: material, role: models.RoleMentor, body: models.UpdateMaterialInput{Title: "Go Syntax"}, status: http.StatusOK},
+		{name: "update with an unknown mode", method: http.MethodPut, path: material, role: models.RoleAdmin, body: models.UpdateMaterialInput{Mode: "append"}, status: http.StatusBadRequest},
+		{name: "update a topic of another material", method: http.MethodPut, path: material, role: models.RoleAdmin, body: models.UpdateMaterialInput{Mode: models.SyncMerge, Content: []models.MaterialTopicItem{{ID: 9999, Title: "x"}}}, status: http.StatusBadRequest},
+		{name: "update a missing material", method: http.MethodPut, path: "/api/v1/materials/9999", role: models.RoleAdmin, body: models.UpdateMaterialInput{Title: "x"}, status: http.StatusNotFound},
+	})
+
+	t.Run("merge keeps rows that are not listed", func(t *testing.T) {
+		var updated models.Material
+		e.mustDo(http.MethodPut, material, e.tokenFor(models.RoleAdmin), models.UpdateMaterialInput{
+			Mode:    models.SyncMerge,
+			Content: []models.MaterialTopicItem{{Title: "Loops", Content: "<p>for</p>", Topics: []string{"loops"}}},
+		}, &updated)
+		if len(updated.Content) != 2 || len(updated.VideoCourses) != 1 {
+			t.Errorf("got %d topics and %d videos, want 2 and 1", len(updated.Content), len(updated.VideoCourses))
+		}
+	})
+
+	e.runCases([]routeCase{
+		{name: "delete as student", method: http.MethodDelete, path: material, role: models.RoleStudent, status: http.StatusForbidden},
+		{name: "delete as mentor", method: http.MethodDelete, path: material, role: models.RoleMentor, status: http.StatusOK},
+		{name: "get a deleted material", method: http.MethodGet, path: material, role: models.RoleStudent, status: http.StatusNotFound},
+		{name: "delete a missing material", method: http.MethodDelete, path: material, role: models.RoleAdmin, status: http.StatusNotFound},
+	})
+}
@@ -0,0 +1,52 @@
+package routes_test
+
+import (
+	"course-api/models"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	e := newTestEnv(t)
+	student := e.tokenFor(models.RoleStudent)
+	course := fmt.Sprintf("/api/v1/courses/%d", e.createCourse("Go Basics"))
+
+	e.mustDo(http.MethodGet, course, student, nil, nil) // Miss, then cached
+	e.mustDo(http.MethodGet, course, student, nil, nil) // Hit
+	e.do(http.MethodGet, "/api/v1/courses/9999", student, nil)
+	if _, err := e.app.Test(httptest.NewRequest(http.MethodGet, "/no/such/path", nil), -1); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := e.app.Test(httptest.NewRequest(http.MethodGet, "/metrics", nil), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	raw, _ := io.ReadAll(resp.Body)
+	body := string(raw)
+
+	for _, want := range []string{
+		`http_requests_total{method="GET",route="/api/v1/courses/:id",status="200"} 2`,
+		`http_requests_total{method="GET",route="/api/v1/courses/:id",status="404"} 1`,
+		`http_request_duration_seconds_count{method="GET",route="/api/v1/courses/:id"} 3`,
+		`cache_hits_total{prefix="courses"} 1`,
+		`cache_misses_total{prefix="courses"} 2`,
+		`db_query_duration_seconds_count{operation="create",table="courses"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics do not contain %s", want)
+		}
+	}
+	t.Log(body)
+	if strings.Contains(body, "/no/such/path") {
+		t.Errorf("unmatched path was used as a route label")
+	}
+}
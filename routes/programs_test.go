@@ -0,0 +1,64 @@
+package routes_test
+
+import (
+	"course-api/models"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestProgramRoutes(t *testing.T) {
+	e := newTestEnv(t)
+	programID := e.createProgram("Backend Track")
+	courseID := e.createCourse("Go Basics")
+	secondCourseID := e.createCourse("Databases")
+	program := fmt.Sprintf("/api/v1/programs/%d", programID)
+
+	newProgram := models.CreateProgramInput{Title: "Frontend Track", Type: "intensive", Duration: "6 weeks", Price: 300, Features: []string{"Projects"}}
+
+	e.runCases([]routeCase{
+		{name: "list without a token", method: http.MethodGet, path: "/api/v1/programs/", status: http.StatusUnauthorized},
+		{name: "list as student", method: http.MethodGet, path: "/api/v1/programs/", role: models.RoleStudent, status: http.StatusOK},
+		{name: "list with an unknown type", method: http.MethodGet, path: "/api/v1/programs/?type=weekend", role: models.RoleStudent, status: http.StatusBadRequest},
+		{name: "get as student", method: http.MethodGet, path: program, role: models.RoleStudent, status: http.StatusOK},
+		{name: "get a missing program", method: http.MethodGet, path: "/api/v1/programs/9999", role: models.RoleStudent, status: http.StatusNotFound},
+
+		{name: "create as student", method: http.MethodPost, path: "/api/v1/programs/", role: models.RoleStudent, body: newProgram, status: http.StatusForbidden},
+		{name: "create as mentor", method: http.MethodPost, path: "/api/v1/programs/", role: models.RoleMentor, body: newProgram, status: http.StatusOK},
+		{name: "create with an unknown type", method: http.MethodPost, path: "/api/v1/programs/", role: models.RoleAdmin, body: models.CreateProgramInput{Title: "x", Type: "weekend", Duration: "x", Price: 1, Features: []string{"x"}}, status: http.StatusBadRequest},
+		{name: "update as student", method: http.MethodPut, path: program, role: models.RoleStudent, body: models.UpdateProgramInput{Title: "Hacked"}, status: http.StatusForbidden},
+		{name: "update as mentor", method: http.MethodPut, path: program, role: models.RoleMentor, body: models.UpdateProgramInput{Title: "Backend Engineering"}, status: http.StatusOK},
+		{name: "update a missing program", method: http.MethodPut, path: "/api/v1/programs/9999", role: models.RoleAdmin, body: models.UpdateProgramInput{Title: "x"}, status: http.StatusNotFound},
+
+		{name: "enrollments as student", method: http.MethodGet, path: program + "/enrollments", role: models.RoleStudent, status: http.StatusForbidden},
+		{name: "enrollments as admin", method: http.MethodGet, path: program + "/enrollments", role: models.RoleAdmin, status: http.StatusOK},
+		{name: "attach course as student", method: http.MethodPost, path: program + "/courses", role: models.RoleStudent, body: models.AttachCourseInput{CourseID: courseID}, status: http.StatusForbidden},
+		{name: "attach course as mentor", method: http.MethodPost, path: program + "/courses", role: models.RoleMentor, body: models.AttachCourseInput{CourseID: courseID}, status: http.StatusOK},
+		{name: "attach second course", method: http.MethodPost, path: program + "/courses", role: models.RoleMentor, body: models.AttachCourseInput{CourseID: secondCourseID}, status: http.StatusOK},
+		{name: "attach a missing course", method: http.MethodPost, path: program + "/courses", role: models.RoleMentor, body: models.AttachCourseInput{CourseID: 9999}, status: http.StatusNotFound},
+		{name: "reorder courses with one left out", method: http.MethodPut, path: program + "/courses/order", role: models.RoleAdmin, body: models.ReorderInput{IDs: []uint{courseID}}, status: http.StatusBadRequest},
+		{name: "reorder courses as student", method: http.MethodPut, path: program + "/courses/order", role: models.RoleStudent, body: models.ReorderInput{IDs: []uint{secondCourseID, courseID}}, status: http.StatusForbidden},
+		{name: "reorder courses", method: http.MethodPut, path: program + "/courses/order", role: models.RoleAdmin, body: models.ReorderInput{IDs: []uint{secondCourseID, courseID}}, status: http.StatusOK},
+	})
+
+	t.Run("curriculum follows the new order", func(t *testing.T) {
+		var curriculum struct {
+			Courses []struct {
+				ID    uint `json:"id"`
+				Order int  `json:"order"`
+			} `json:"courses"`
+		}
+		e.mustDo(http.MethodGet, program+"/curriculum", e.tokenFor(models.RoleStudent), nil, &curriculum)
+		if len(curriculum.Courses) != 2 || curriculum.Courses[0].ID != secondCourseID || curriculum.Courses[1].ID != courseID {
+			t.Errorf("curriculum courses %+v, want %d then %d", curriculum.Courses, secondCourseID, courseID)
+		}
+	})
+
+	e.runCases([]routeCase{
+		{name: "detach course as student", method: http.MethodDelete, path: fmt.Sprintf("%s/courses/%d", program, courseID), role: models.RoleStudent, status: http.StatusForbidden},
+		{name: "detach course", method: http.MethodDelete, path: fmt.Sprintf("%s/courses/%d", program, courseID), role: models.RoleMentor, status: http.StatusOK},
+		{name: "delete as student", method: http.MethodDelete, path: program, role: models.RoleStudent, status: http.StatusForbidden},
+		{name: "delete as admin", method: http.MethodDelete, path: program, role: models.RoleAdmin, status: http.StatusOK},
+		{name: "get a deleted program", method: http.MethodGet, path: program, role: models.RoleStudent, status: http.StatusNotFound},
+	})
+}
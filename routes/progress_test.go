@@ -0,0 +1,181 @@
+package routes_test
+
+import (
+	"course-api/models"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestProgressRequiresEnrollment(t *testing.T) {
+	e := newTestEnv(t)
+	admin := e.tokenFor(models.RoleAdmin)
+	material := e.createMaterial("Go")
+	courseID, programID := e.createCourse("Go Basics"), e.createProgram("Backend Track")
+	e.mustDo(http.MethodPost, fmt.Sprintf("/api/v1/courses/%d/materials", courseID), admin, models.AttachMaterialInput{MaterialID: material.ID}, nil)
+	e.mustDo(http.MethodPost, fmt.Sprintf("/api/v1/programs/%d/courses", programID), admin, models.AttachCourseInput{CourseID: courseID}, nil)
+
+	topic := fmt.Sprintf("/api/v1/me/progress/topics/%d", material.Content[0].ID)
+	video := fmt.Sprintf("/api/v1/me/progress/videos/%d", material.VideoCourses[0].ID)
+	student := e.tokenFor(models.RoleStudent)
+	other := e.signUp("other@example.com", "password123")
+
+	completions := func() int64 {
+		var count int64
+		e.db.Model(&models.Progress{}).Count(&count)
+		return count
+	}
+	refused := func(t *testing.T, path, token string) {
+		t.Helper()
+		status, resp := e.do(http.MethodPost, path, token, nil)
+		if status != http.StatusForbidden || resp.Error == nil || resp.Error.Code != "NOT_ENROLLED" {
+			t.Errorf("POST %s: status %d with %+v, want %d NOT_ENROLLED", path, status, resp.Error, http.StatusForbidden)
+		}
+	}
+
+	t.Run("without an enrollment", func(t *testing.T) {
+		refused(t, topic, student)
+		refused(t, video, student)
+		if n := completions(); n != 0 {
+			t.Errorf("%d completions stored, want 0", n)
+		}
+	})
+
+	t.Run("through a program", func(t *testing.T) {
+		e.mustDo(http.MethodPost, "/api/v1/enrollments/", student, models.EnrollInput{ProgramID: programID}, nil)
+
+		var progress models.MaterialProgress
+		e.mustDo(http.MethodPost, topic, student, nil, &progress)
+		if progress.CompletedTopics != 1 || progress.CompletedVideos != 0 {
+			t.Errorf("progress %+v after completing the topic", progress)
+		}
+	})
+
+	t.Run("directly in the course", func(t *testing.T) {
+		var enrollment models.Enrollment
+		e.mustDo(http.MethodPost, "/api/v1/enrollments/", other, models.EnrollInput{CourseID: courseID}, &enrollment)
+		e.mustDo(http.MethodPost, video, other, nil, nil)
+
+		e.mustDo(http.MethodDelete, fmt.Sprintf("/api/v1/enrollments/%d", enrollment.ID), other, nil, nil)
+		refused(t, topic, other)
+	})
+
+	t.Run("a course without the material", func(t *testing.T) {
+		third := e.signUp("third@example.com", "password123")
+		e.mustDo(http.MethodPost, "/api/v1/enrollments/", third, models.EnrollInput{CourseID: e.createCourse("Rust")}, nil)
+		refused(t, topic, third)
+	})
+
+	if n := completions(); n != 2 {
+		t.Errorf("%d completions stored, want 2", n)
+	}
+	if status, _ := e.do(http.MethodPost, "/api/v1/me/progress/topics/9999", student, nil); status != http.StatusNotFound {
+		t.Errorf("status %d completing a missing topic, want %d", status, http.StatusNotFound)
+	}
+}
+
+func TestProgressRoutes(t *testing.T) {
+	e := newTestEnv(t)
+	student := e.tokenFor(models.RoleStudent)
+	material := e.createMaterial("Go")
+	courseID := e.createCourse("Go Basics")
+	e.mustDo(http.MethodPost, fmt.Sprintf("/api/v1/courses/%d/materials", courseID), e.tokenFor(models.RoleAdmin), models.AttachMaterialInput{MaterialID: material.ID}, nil)
+	e.mustDo(http.MethodPost, "/api/v1/enrollments/", student, models.EnrollInput{CourseID: courseID}, nil)
+
+	var user models.User
+	if err := e.db.Where("role = ?", models.RoleStudent).First(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	topicID, videoID := material.Content[0].ID, material.VideoCourses[0].ID
+	topic := fmt.Sprintf("/api/v1/me/progress/topics/%d", topicID)
+	video := fmt.Sprintf("/api/v1/me/progress/videos/%d", videoID)
+
+	// stored loads the student's completions from the database
+	stored := func(t *testing.T) []models.Progress {
+		t.Helper()
+		var records []models.Progress
+		if err := e.db.Where("user_id = ?", user.ID).Order("id").Find(&records).Error; err != nil {
+			t.Fatal(err)
+		}
+		return records
+	}
+
+	t.Run("complete a topic", func(t *testing.T) {
+		var progress models.MaterialProgress
+		e.mustDo(http.MethodPost, topic, student, nil, &progress)
+		if progress.MaterialID != material.ID || progress.CompletedTopics != 1 || progress.TotalTopics != 1 || progress.TotalVideos != 1 || progress.Percent != 50 {
+			t.Errorf("progress %+v, want half of the material completed", progress)
+		}
+		if len(progress.CompletedTopicIDs) != 1 || progress.CompletedTopicIDs[0] != topicID {
+			t.Errorf("completed topics %v, want %d", progress.CompletedTopicIDs, topicID)
+		}
+
+		records := stored(t)
+		if len(records) != 1 || records[0].ItemType != models.ProgressContentTopic || records[0].ItemID != topicID || records[0].MaterialID != material.ID {
+			t.Fatalf("stored completions %+v, want the topic", records)
+		}
+
+		// Completing again keeps the original completion
+		e.mustDo(http.MethodPost, topic, student, nil, nil)
+		if again := stored(t); len(again) != 1 || !again[0].CompletedAt.Equal(records[0].CompletedAt) {
+			t.Errorf("stored completions %+v after completing twice, want the first one kept", again)
+		}
+	})
+
+	t.Run("complete a video", func(t *testing.T) {
+		var progress models.MaterialProgress
+		e.mustDo(http.MethodPost, video, student, nil, &progress)
+		if progress.CompletedVideos != 1 || progress.Percent != 100 {
+			t.Errorf("progress %+v, want the material completed", progress)
+		}
+		if records := stored(t); len(records) != 2 || records[1].ItemType != models.ProgressVideoCourse || records[1].ItemID != videoID {
+			t.Errorf("stored completions %+v, want the topic and the video", records)
+		}
+	})
+
+	t.Run("summaries", func(t *testing.T) {
+		var summary models.ProgressSummary
+		e.mustDo(http.MethodGet, "/api/v1/me/progress", student, nil, &summary)
+		if summary.UserID != user.ID || summary.Percent != 100 || len(summary.Materials) != 1 || summary.Materials[0].Title != "Go" {
+			t.Errorf("summary %+v, want the material completed", summary)
+		}
+
+		var course models.CourseProgress
+		e.mustDo(http.MethodGet, fmt.Sprintf("/api/v1/me/progress/courses/%d", courseID), student, nil, &course)
+		if course.CourseID != courseID || course.Title != "Go Basics" || course.Percent != 100 || len(course.Materials) != 1 {
+			t.Errorf("course progress %+v, want the course completed", course)
+		}
+
+		var mentorView models.CourseProgress
+		e.mustDo(http.MethodGet, fmt.Sprintf("/api/v1/users/%d/progress/courses/%d", user.ID, courseID), e.tokenFor(models.RoleMentor), nil, &mentorView)
+		if mentorView.Percent != course.Percent || len(mentorView.Materials) != 1 {
+			t.Errorf("progress seen by a mentor %+v, want %+v", mentorView, course)
+		}
+	})
+
+	e.runCases([]routeCase{
+		{name: "progress without a token", method: http.MethodGet, path: "/api/v1/me/progress", status: http.StatusUnauthorized},
+		{name: "progress of a missing material", method: http.MethodGet, path: "/api/v1/me/progress/materials/9999", role: models.RoleStudent, status: http.StatusNotFound},
+		{name: "progress of a missing course", method: http.MethodGet, path: "/api/v1/me/progress/courses/9999", role: models.RoleStudent, status: http.StatusNotFound},
+		{name: "complete a missing video", method: http.MethodPost, path: "/api/v1/me/progress/videos/9999", role: models.RoleStudent, status: http.StatusNotFound},
+		{name: "user progress as student", method: http.MethodGet, path: fmt.Sprintf("/api/v1/users/%d/progress", user.ID), role: models.RoleStudent, status: http.StatusForbidden},
+		{name: "user progress as mentor", method: http.MethodGet, path: fmt.Sprintf("/api/v1/users/%d/progress", user.ID), role: models.RoleMentor, status: http.StatusOK},
+		{name: "progress of a missing user", method: http.MethodGet, path: "/api/v1/users/9999/progress", role: models.RoleAdmin, status: http.StatusNotFound},
+	})
+
+	t.Run("uncomplete", func(t *testing.T) {
+		var progress models.MaterialProgress
+		e.mustDo(http.MethodDelete, topic, student, nil, &progress)
+		if progress.CompletedTopics != 0 || progress.CompletedVideos != 1 || progress.Percent != 50 {
+			t.Errorf("progress %+v, want the video alone completed", progress)
+		}
+		if records := stored(t); len(records) != 1 || records[0].ItemID != videoID {
+			t.Errorf("stored completions %+v, want the video alone", records)
+		}
+
+		e.mustDo(http.MethodGet, fmt.Sprintf("/api/v1/me/progress/materials/%d", material.ID), student, nil, &progress)
+		if progress.Percent != 50 {
+			t.Errorf("material progress %+v, want half completed", progress)
+		}
+	})
+}
@@ -1,18 +1,92 @@
 package routes
 
 import (
+	"course-api/config"
 	"course-api/handlers"
 	"course-api/middleware"
 	"course-api/models"
+	"course-api/repository"
+	"course-api/utils/cache"
+	"course-api/utils/mailer"
+	"course-api/utils/metrics"
+	"course-api/utils/search"
+	"course-api/validator"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
 	"github.com/gofiber/swagger"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
 )
 
-func SetupRoutes(app *fiber.App) {
+// Dependencies are the shared services the handlers are built from
+type Dependencies struct {
+	DB       *gorm.DB
+	Redis    *redis.Client // Optional; the Redis token denylist is skipped without it
+	Cache    *cache.Cache  // Built on Redis, see cache.New
+	Settings *config.Settings
+	Search   *search.Index
+	Mailer   mailer.Mailer
+	Metrics  *metrics.Metrics // Served on /metrics; install Metrics.GormPlugin on DB to time queries
+}
+
+// Cache-Control of the responses. Catalog items are reused by clients for a
+// minute, lists are revalidated with their ETag on every use since they change
+// as soon as an item is added, and personal data and tokens are never stored.
+const (
+	cacheCatalogItem = "private, max-age=60"
+	cacheCatalogList = "private, no-cache"
+	cacheNoStore     = "no-store"
+)
+
+func SetupRoutes(app *fiber.App, deps Dependencies) {
+	settings := deps.Settings
+	validate := validator.New()
+	tokens := middleware.NewTokenService(deps.DB, deps.Redis, settings.Auth)
+	protected := tokens.Protected()
+
+	// Repositories
+	courseRepo := repository.NewCourseRepository(deps.DB)
+	programRepo := repository.NewProgramRepository(deps.DB)
+	materialRepo := repository.NewMaterialRepository(deps.DB)
+	topicRepo := repository.NewContentTopicRepository(deps.DB)
+	videoRepo := repository.NewVideoRepository(deps.DB)
+	userRepo := repository.NewUserRepository(deps.DB)
+	enrollmentRepo := repository.NewEnrollmentRepository(deps.DB)
+
+	// Catalog reads are cached, each resource with its own TTL
+	responseCache := deps.Cache
+	ttl := settings.Cache
+
+	// Handlers
+	authHandler := handlers.NewAuthHandler(userRepo, repository.NewUserTokenRepository(deps.DB), tokens, deps.Mailer, validate, settings.Auth, settings.AppURL)
+	userHandler := handlers.NewUserHandler(userRepo, tokens, validate)
+	courseHandler := handlers.NewCourseHandler(courseRepo, responseCache, deps.Search, validate, ttl.TTLOf(ttl.Courses))
+	programHandler := handlers.NewProgramHandler(programRepo, responseCache, validate, ttl.TTLOf(ttl.Programs))
+	materialHandler := handlers.NewMaterialHandler(materialRepo, responseCache, deps.Search, validate, ttl.TTLOf(ttl.Materials))
+	topicHandler := handlers.NewContentTopicHandler(topicRepo, responseCache, deps.Search, validate, ttl.TTLOf(ttl.Content))
+	videoHandler := handlers.NewVideoHandler(videoRepo, materialRepo, responseCache, validate, ttl.TTLOf(ttl.Videos))
+	curriculumHandler := handlers.NewCurriculumHandler(repository.NewCurriculumRepository(deps.DB), programRepo, courseRepo, materialRepo, responseCache, validate, ttl.TTLOf(ttl.Curricula))
+	enrollmentHandler := handlers.NewEnrollmentHandler(enrollmentRepo, courseRepo, programRepo, validate)
+	progressHandler := handlers.NewProgressHandler(repository.NewProgressRepository(deps.DB), enrollmentRepo, userRepo, courseRepo, materialRepo, topicRepo, videoRepo)
+	searchHandler := handlers.NewSearchHandler(deps.Search)
+	healthHandler := handlers.NewHealthHandler(deps.DB, deps.Redis)
+	cacheAdminHandler := handlers.NewCacheAdminHandler(responseCache)
+
+	// Request metrics, first so they cover every route, then the request span
+	// and ID that handlers and their queries are traced and logged with
+	app.Use(middleware.MetricsMiddleware(deps.Metrics))
+	app.Use(middleware.TracingMiddleware())
+	app.Use(middleware.RequestID())
+	app.Get("/metrics", adaptor.HTTPHandler(deps.Metrics.Handler()))
+
 	// Swagger route
 	app.Get("/api/v1/swagger/*", swagger.HandlerDefault)
 
+	// Health probes, registered before the logger so polling does not flood the log
+	app.Get("/healthz", healthHandler.Liveness)
+	app.Get("/readyz", healthHandler.Readiness)
+
 	// Middleware global
 	app.Use(middleware.LoggerMiddleware())
 
@@ -20,55 +94,137 @@ func SetupRoutes(app *fiber.App) {
 	api := app.Group("/api")
 	v1 := api.Group("/v1")
 
+	item := middleware.CacheControl(cacheCatalogItem)
+	list := middleware.CacheControl(cacheCatalogList)
+	noStore := middleware.CacheControl(cacheNoStore)
+
 	// Auth routes (public)
 	auth := v1.Group("/auth")
-	auth.Post("/signup", handlers.SignUp)
-	auth.Post("/signin", handlers.SignIn)
+	auth.Use(noStore)
+	auth.Post("/signup", authHandler.SignUp)
+	auth.Post("/signin", authHandler.SignIn)
+	auth.Post("/bootstrap-admin", authHandler.BootstrapAdmin)
+	auth.Post("/refresh", authHandler.RefreshToken)
+	auth.Post("/logout", authHandler.Logout)
+	auth.Post("/verify-email", authHandler.VerifyEmail)
+	auth.Post("/resend-verification", authHandler.ResendVerification)
+	auth.Post("/forgot-password", authHandler.ForgotPassword)
+	auth.Post("/reset-password", authHandler.ResetPassword)
 
 	// Courses routes (protected)
 	courses := v1.Group("/courses")
-	courses.Use(middleware.Protected()) // Auth middleware for all courses routes
-	courses.Get("/", handlers.GetAllCourses)
-	courses.Get("/:id", handlers.GetCourse)
+	courses.Use(protected) // Auth middleware for all courses routes
+	courses.Get("/", list, courseHandler.GetAllCourses)
+	courses.Get("/:id", item, courseHandler.GetCourse)
+	courses.Get("/:id/curriculum", item, curriculumHandler.GetCourseCurriculum)
 
 	// Only Admin & Mentor can modify courses
 	courses.Use(middleware.RequireRole(models.RoleAdmin, models.RoleMentor))
-	courses.Post("/", handlers.CreateCourse)
-	courses.Put("/:id", handlers.UpdateCourse)
-	courses.Delete("/:id", handlers.DeleteCourse)
+	courses.Post("/", courseHandler.CreateCourse)
+	courses.Put("/:id", courseHandler.UpdateCourse)
+	courses.Delete("/:id", courseHandler.DeleteCourse)
+	courses.Get("/:id/enrollments", noStore, enrollmentHandler.GetCourseEnrollments)
+	courses.Post("/:id/materials", curriculumHandler.AddCourseMaterial)
+	courses.Put("/:id/materials/order", curriculumHandler.ReorderCourseMaterials)
+	courses.Delete("/:id/materials/:material_id", curriculumHandler.RemoveCourseMaterial)
 
 	// Programs routes (protected)
 	programs := v1.Group("/programs")
-	programs.Use(middleware.Protected()) // Auth middleware for all programs routes
-	programs.Get("/", handlers.GetAllPrograms)
-	programs.Get("/:id", handlers.GetProgram)
+	programs.Use(protected) // Auth middleware for all programs routes
+	programs.Get("/", list, programHandler.GetAllPrograms)
+	programs.Get("/:id", item, programHandler.GetProgram)
+	programs.Get("/:id/curriculum", item, curriculumHandler.GetProgramCurriculum)
 
 	// Only Admin & Mentor can modify programs
 	programs.Use(middleware.RequireRole(models.RoleAdmin, models.RoleMentor))
-	programs.Post("/", handlers.CreateProgram)
-	programs.Put("/:id", handlers.UpdateProgram)
-	programs.Delete("/:id", handlers.DeleteProgram)
+	programs.Post("/", programHandler.CreateProgram)
+	programs.Put("/:id", programHandler.UpdateProgram)
+	programs.Delete("/:id", programHandler.DeleteProgram)
+	programs.Get("/:id/enrollments", noStore, enrollmentHandler.GetProgramEnrollments)
+	programs.Post("/:id/courses", curriculumHandler.AddProgramCourse)
+	programs.Put("/:id/courses/order", curriculumHandler.ReorderProgramCourses)
+	programs.Delete("/:id/courses/:course_id", curriculumHandler.RemoveProgramCourse)
 
 	// Materials routes (protected)
 	materials := v1.Group("/materials")
-	materials.Use(middleware.Protected()) // Auth middleware for all materials routes
-	materials.Get("/", handlers.GetAllMaterials)
-	materials.Get("/:id", handlers.GetMaterial)
+	materials.Use(protected) // Auth middleware for all materials routes
+	materials.Get("/", list, materialHandler.GetAllMaterials)
+	materials.Get("/:id", item, materialHandler.GetMaterial)
 
 	// Only Admin & Mentor can modify materials
 	materials.Use(middleware.RequireRole(models.RoleAdmin, models.RoleMentor))
-	materials.Post("/", handlers.CreateMaterial)
-	materials.Put("/:id", handlers.UpdateMaterial)
-	materials.Delete("/:id", handlers.DeleteMaterial)
+	materials.Post("/", materialHandler.CreateMaterial)
+	materials.Put("/:id", materialHandler.UpdateMaterial)
+	materials.Delete("/:id", materialHandler.DeleteMaterial)
 
 	// Content Topic routes (protected)
 	content := v1.Group("/content")
-	content.Use(middleware.Protected())
-	content.Get("/material/:material_id", handlers.GetContentTopics)
-	content.Get("/:id", handlers.GetContentTopic)
+	content.Use(protected)
+	content.Get("/material/:material_id", list, topicHandler.GetContentTopics)
+	content.Get("/:id", item, topicHandler.GetContentTopic)
 	// Restrict content management to admin and mentor roles
 	content.Use(middleware.RequireRole(models.RoleAdmin, models.RoleMentor))
-	content.Post("/", handlers.CreateContentTopic)
-	content.Put("/:id", handlers.UpdateContentTopic)
-	content.Delete("/:id", handlers.DeleteContentTopic)
+	content.Post("/", topicHandler.CreateContentTopic)
+	content.Put("/:id", topicHandler.UpdateContentTopic)
+	content.Delete("/:id", topicHandler.DeleteContentTopic)
+
+	// Video routes (protected)
+	videos := v1.Group("/videos")
+	videos.Use(protected)
+	videos.Get("/", list, videoHandler.GetVideos)
+	videos.Get("/material/:material_id", list, videoHandler.GetMaterialVideos)
+	videos.Get("/:id", item, videoHandler.GetVideo)
+	// Restrict video management to admin and mentor roles
+	videos.Use(middleware.RequireRole(models.RoleAdmin, models.RoleMentor))
+	videos.Post("/", videoHandler.CreateVideo)
+	videos.Put("/material/:material_id/order", videoHandler.ReorderMaterialVideos)
+	videos.Put("/:id", videoHandler.UpdateVideo)
+	videos.Delete("/:id", videoHandler.DeleteVideo)
+
+	// Enrollment routes (protected)
+	enrollments := v1.Group("/enrollments")
+	enrollments.Use(protected, noStore)
+	enrollments.Post("/", enrollmentHandler.Enroll)
+	enrollments.Get("/me", enrollmentHandler.GetMyEnrollments)
+	enrollments.Delete("/:id", enrollmentHandler.Unenroll)
+
+	// Only Admin & Mentor can change enrollment status
+	enrollments.Use(middleware.RequireRole(models.RoleAdmin, models.RoleMentor))
+	enrollments.Put("/:id/status", enrollmentHandler.UpdateEnrollmentStatus)
+
+	// Search routes (protected)
+	v1.Get("/search", protected, noStore, searchHandler.Search)
+
+	// Progress routes for the authenticated user
+	me := v1.Group("/me")
+	me.Use(protected, noStore)
+	me.Get("/progress", progressHandler.GetMyProgress)
+	me.Get("/progress/materials/:id", progressHandler.GetMyMaterialProgress)
+	me.Get("/progress/courses/:id", progressHandler.GetMyCourseProgress)
+	me.Post("/progress/topics/:id", progressHandler.CompleteContentTopic)
+	me.Delete("/progress/topics/:id", progressHandler.UncompleteContentTopic)
+	me.Post("/progress/videos/:id", progressHandler.CompleteVideoCourse)
+	me.Delete("/progress/videos/:id", progressHandler.UncompleteVideoCourse)
+
+	// User routes, only Admin & Mentor can view other users' progress
+	users := v1.Group("/users")
+	users.Use(protected, noStore)
+	users.Use(middleware.RequireRole(models.RoleAdmin, models.RoleMentor))
+	users.Get("/:id/progress", progressHandler.GetUserProgress)
+	users.Get("/:id/progress/courses/:course_id", progressHandler.GetUserCourseProgress)
+
+	// Only Admin can manage users
+	users.Use(middleware.RequireRole(models.RoleAdmin))
+	users.Get("/", userHandler.GetAllUsers)
+	users.Get("/:id", userHandler.GetUser)
+	users.Put("/:id/role", userHandler.UpdateUserRole)
+	users.Put("/:id/status", userHandler.UpdateUserStatus)
+	users.Put("/:id/password", userHandler.ResetUserPassword)
+
+	// Admin routes
+	admin := v1.Group("/admin")
+	admin.Use(protected, noStore)
+	admin.Use(middleware.RequireRole(models.RoleAdmin))
+	admin.Get("/cache", cacheAdminHandler.GetCacheStats)
+	admin.Delete("/cache/:namespace?", cacheAdminHandler.PurgeCache)
 }
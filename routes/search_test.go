@@ -0,0 +1,87 @@
+package routes_test
+
+import (
+	"course-api/models"
+	"course-api/utils/query"
+	"course-api/utils/search"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestSearchRoutes(t *testing.T) {
+	e := newTestEnv(t)
+	student, admin := e.tokenFor(models.RoleStudent), e.tokenFor(models.RoleAdmin)
+	courseID := e.createCourse("Concurrency")
+	material := e.createMaterial("Concurrency patterns")
+
+	// find searches as the student and returns the hits and their meta
+	find := func(t *testing.T, params string) ([]search.Hit, query.Meta) {
+		t.Helper()
+		status, resp := e.do(http.MethodGet, "/api/v1/search?"+params, student, nil)
+		if status != http.StatusOK {
+			t.Fatalf("search %q: status %d: %s", params, status, resp.Message)
+		}
+		var hits []search.Hit
+		var meta query.Meta
+		if err := json.Unmarshal(resp.Data, &hits); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(resp.Meta, &meta); err != nil {
+			t.Fatal(err)
+		}
+		return hits, meta
+	}
+
+	e.runCases([]routeCase{
+		{name: "search without a token", method: http.MethodGet, path: "/api/v1/search?q=go", status: http.StatusUnauthorized},
+		{name: "search without terms", method: http.MethodGet, path: "/api/v1/search?q=%20", role: models.RoleStudent, status: http.StatusBadRequest},
+		{name: "search too long", method: http.MethodGet, path: "/api/v1/search?q=" + strings.Repeat("a", 201), role: models.RoleStudent, status: http.StatusBadRequest},
+		{name: "search an unknown type", method: http.MethodGet, path: "/api/v1/search?q=go&type=video", role: models.RoleStudent, status: http.StatusBadRequest},
+	})
+
+	t.Run("ranked hits", func(t *testing.T) {
+		hits, meta := find(t, "q=concurrency")
+		if len(hits) != 2 || meta.Total != 2 {
+			t.Fatalf("hits %+v, want the course and the material", hits)
+		}
+		// The exact title ranks first
+		if hits[0].Type != search.TypeCourse || hits[0].ID != courseID || hits[1].Type != search.TypeMaterial || hits[1].ID != material.ID {
+			t.Errorf("hits %+v, want the course then the material", hits)
+		}
+		if !strings.Contains(hits[0].Snippet, "<mark>") {
+			t.Errorf("snippet %q without the matched word marked", hits[0].Snippet)
+		}
+	})
+
+	t.Run("filters and pages", func(t *testing.T) {
+		if hits, _ := find(t, "q=concurrency&type=material"); len(hits) != 1 || hits[0].ID != material.ID {
+			t.Errorf("material hits %+v", hits)
+		}
+		if hits, _ := find(t, "q=concur"); len(hits) != 2 {
+			t.Errorf("%d hits for a prefix, want 2", len(hits))
+		}
+		hits, meta := find(t, "q=concurrency&limit=1&page=2")
+		if len(hits) != 1 || meta.Total != 2 || meta.TotalPages != 2 || meta.HasMore {
+			t.Errorf("second page %+v with meta %+v", hits, meta)
+		}
+		if hits, _ := find(t, "q=introduction&type=content_topic"); len(hits) != 1 || hits[0].ID != material.Content[0].ID || hits[0].MaterialID != material.ID {
+			t.Errorf("topic hits %+v, want the material's topic", hits)
+		}
+	})
+
+	t.Run("writes update the index", func(t *testing.T) {
+		e.mustDo(http.MethodPut, fmt.Sprintf("/api/v1/courses/%d", courseID), admin, models.UpdateCourseInput{Title: "Parallelism"}, nil)
+		if hits, _ := find(t, "q=parallelism"); len(hits) != 1 || hits[0].ID != courseID || hits[0].Title != "Parallelism" {
+			t.Errorf("hits %+v after renaming the course", hits)
+		}
+
+		e.mustDo(http.MethodDelete, fmt.Sprintf("/api/v1/materials/%d", material.ID), admin, nil, nil)
+		if hits, _ := find(t, "q="+url.QueryEscape("concurrency patterns")+"&type=material,content_topic"); len(hits) != 0 {
+			t.Errorf("hits %+v after deleting the material", hits)
+		}
+	})
+}
@@ -0,0 +1,354 @@
+package routes_test
+
+import (
+	"bytes"
+	"context"
+	"course-api/config"
+	"course-api/migrations"
+	"course-api/models"
+	"course-api/responses"
+	"course-api/routes"
+	"course-api/utils/cache"
+	"course-api/utils/logging"
+	"course-api/utils/mailer"
+	"course-api/utils/metrics"
+	"course-api/utils/search"
+	"course-api/utils/tracing"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// testEnv is the full application wired against a temporary SQLite database
+// and an in-process Redis
+type testEnv struct {
+	t      *testing.T
+	app    *fiber.App
+	db     *gorm.DB
+	redis  *miniredis.Miniredis
+	client *redis.Client // Connected to redis
+	prefix string        // Of the cache keys in redis
+	tokens map[models.Role]string
+	users  int
+}
+
+// apiResponse is the envelope written by the responses package
+type apiResponse struct {
+	Success bool            `json:"success"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+	Meta    json.RawMessage `json:"meta"`
+	Error   *struct {
+		Code    string `json:"code"`
+		Details []struct {
+			Field string `json:"field"`
+			Rule  string `json:"rule"`
+		} `json:"details"`
+	} `json:"error"`
+}
+
+// discardMailer drops every email so tests do not write to the log
+type discardMailer struct{}
+
+func (discardMailer) Send(context.Context, mailer.Message) error { return nil }
+
+// newTestEnv migrates a fresh database and builds the app through routes.SetupRoutes.
+// configure may change the default settings before the app is built.
+func newTestEnv(t *testing.T, configure ...func(*config.Settings)) *testEnv {
+	t.Helper()
+
+	settings := config.DefaultSettings()
+	settings.Auth.JWTSecret = "test-secret"
+	settings.Database.Driver = config.DriverSQLite
+	settings.Database.SQLitePath = filepath.Join(t.TempDir(), "test.db")
+	for _, f := range configure {
+		f(settings)
+	}
+
+	dialector, err := config.Dialector(settings.Database)
+	if err != nil {
+		t.Fatalf("dialector: %v", err)
+	}
+	db, err := gorm.Open(dialector, &gorm.Config{Logger: logging.NewGormLogger(0), TranslateError: true})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	appMetrics := metrics.New()
+	if err := db.Use(appMetrics.GormPlugin()); err != nil {
+		t.Fatalf("database metrics: %v", err)
+	}
+	if err := db.Use(tracing.GormPlugin()); err != nil {
+		t.Fatalf("database tracing: %v", err)
+	}
+	if _, err := migrations.Up(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{Addr: redisServer.Addr()})
+	t.Cleanup(func() { redisClient.Close() })
+	if err := tracing.InstrumentRedis(redisClient); err != nil {
+		t.Fatalf("redis tracing: %v", err)
+	}
+
+	responseCache := cache.New(redisClient, settings.Cache, appMetrics)
+	t.Cleanup(func() { responseCache.Close() })
+
+	app := fiber.New(fiber.Config{ErrorHandler: responses.ErrorHandler})
+	routes.SetupRoutes(app, routes.Dependencies{
+		DB:       db,
+		Redis:    redisClient,
+		Cache:    responseCache,
+		Settings: settings,
+		Search:   search.NewIndex(),
+		Mailer:   discardMailer{},
+		Metrics:  appMetrics,
+	})
+
+	return &testEnv{
+		t:      t,
+		app:    app,
+		db:     db,
+		redis:  redisServer,
+		client: redisClient,
+		prefix: settings.Cache.Prefix,
+		tokens: make(map[models.Role]string),
+	}
+}
+
+// cached reports whether the cache holds key
+func (e *testEnv) cached(key string) bool {
+	return e.redis.Exists(e.prefix + key)
+}
+
+// cacheKeys lists the keys held by the cache, without their prefix. The tags'
+// invalidation counters, which outlive invalidations, are left out.
+func (e *testEnv) cacheKeys() []string {
+	var keys []string
+	for _, key := range e.redis.Keys() {
+		if strings.HasPrefix(key, e.prefix) && !strings.HasSuffix(key, ":generation") {
+			keys = append(keys, strings.TrimPrefix(key, e.prefix))
+		}
+	}
+	return keys
+}
+
+// request sends a JSON request, with a bearer token when token is not empty, and decodes the envelope
+func (e *testEnv) request(method, path, token string, body interface{}) (int, apiResponse, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, apiResponse{}, fmt.Errorf("encode body: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := e.app.Test(req, -1)
+	if err != nil {
+		return 0, apiResponse{}, fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	var out apiResponse
+	raw, _ := io.ReadAll(resp.Body)
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return resp.StatusCode, out, fmt.Errorf("%s %s: decode %q: %w", method, path, raw, err)
+	}
+	return resp.StatusCode, out, nil
+}
+
+// do is request for the test goroutine, failing the test when the request cannot be made
+func (e *testEnv) do(method, path, token string, body interface{}) (int, apiResponse) {
+	e.t.Helper()
+
+	status, resp, err := e.request(method, path, token, body)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	return status, resp
+}
+
+// routeCase is one request of a table-driven route test
+type routeCase struct {
+	name   string
+	method string
+	path   string
+	role   models.Role // Sent without a token when empty
+	body   interface{}
+	status int
+}
+
+// runCases sends the cases in order, each as a subtest, and checks their status codes
+func (e *testEnv) runCases(cases []routeCase) {
+	e.t.Helper()
+
+	for _, tc := range cases {
+		// Tokens are signed in here because subtests must not fail the parent test
+		token := ""
+		if tc.role != "" {
+			token = e.tokenFor(tc.role)
+		}
+
+		e.t.Run(tc.name, func(t *testing.T) {
+			status, resp, err := e.request(tc.method, tc.path, token, tc.body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if status != tc.status {
+				t.Errorf("%s %s as %q: status %d, want %d (%s)", tc.method, tc.path, tc.role, status, tc.status, resp.Message)
+			}
+		})
+	}
+}
+
+// mustDo is do for requests that are expected to succeed; it decodes data into dest when not nil
+func (e *testEnv) mustDo(method, path, token string, body, dest interface{}) {
+	e.t.Helper()
+
+	status, resp := e.do(method, path, token, body)
+	if status != fiber.StatusOK {
+		e.t.Fatalf("%s %s: status %d: %s", method, path, status, resp.Message)
+	}
+	if dest != nil {
+		if err := json.Unmarshal(resp.Data, dest); err != nil {
+			e.t.Fatalf("%s %s: decode data: %v", method, path, err)
+		}
+	}
+}
+
+// signUp registers a student through the API and returns its access token
+func (e *testEnv) signUp(email, password string) string {
+	e.t.Helper()
+
+	var data struct {
+		Token string `json:"token"`
+	}
+	e.mustDo("POST", "/api/v1/auth/signup", "", models.SignupInput{Email: email, Password: password, FullName: "Test User"}, &data)
+	return data.Token
+}
+
+// signIn signs in through the API and returns the access token
+func (e *testEnv) signIn(email, password string) string {
+	e.t.Helper()
+
+	var data struct {
+		Token string `json:"token"`
+	}
+	e.mustDo("POST", "/api/v1/auth/signin", "", models.LoginInput{Email: email, Password: password}, &data)
+	return data.Token
+}
+
+// createUser stores an active, verified user with the role
+func (e *testEnv) createUser(role models.Role, password string) models.User {
+	e.t.Helper()
+
+	e.users++
+	user := models.User{
+		Email:         fmt.Sprintf("%s%d@example.com", role, e.users),
+		Password:      password,
+		FullName:      fmt.Sprintf("Test %s", role),
+		Role:          role,
+		IsActive:      true,
+		EmailVerified: true,
+	}
+	if err := user.HashPassword(); err != nil {
+		e.t.Fatalf("hash password: %v", err)
+	}
+	if err := e.db.Create(&user).Error; err != nil {
+		e.t.Fatalf("create %s: %v", role, err)
+	}
+	return user
+}
+
+// tokenFor returns an access token of a user with the role, signing one in on first use
+func (e *testEnv) tokenFor(role models.Role) string {
+	e.t.Helper()
+
+	if token, ok := e.tokens[role]; ok {
+		return token
+	}
+	user := e.createUser(role, "password")
+	token := e.signIn(user.Email, "password")
+	e.tokens[role] = token
+	return token
+}
+
+// createCourse adds a course as admin and returns its ID
+func (e *testEnv) createCourse(title string) uint {
+	e.t.Helper()
+
+	var course models.Course
+	e.mustDo("POST", "/api/v1/courses/", e.tokenFor(models.RoleAdmin), models.CreateCourseInput{
+		Title:       title,
+		Description: "About " + title,
+		Instructor:  "Ada",
+		Duration:    10,
+		Price:       99,
+	}, &course)
+	return course.ID
+}
+
+// createProgram adds a program as admin and returns its ID
+func (e *testEnv) createProgram(title string) uint {
+	e.t.Helper()
+
+	var program models.Program
+	e.mustDo("POST", "/api/v1/programs/", e.tokenFor(models.RoleAdmin), models.CreateProgramInput{
+		Title:    title,
+		Type:     "regular",
+		Duration: "3 months",
+		Price:    500,
+		Features: []string{"Mentoring"},
+	}, &program)
+	return program.ID
+}
+
+// createMaterial adds a material with one content topic and one video as admin
+func (e *testEnv) createMaterial(title string) models.Material {
+	e.t.Helper()
+
+	var material models.Material
+	e.mustDo("POST", "/api/v1/materials/", e.tokenFor(models.RoleAdmin), map[string]interface{}{
+		"title":          title,
+		"description":    "About " + title,
+		"icon":           "book",
+		"duration":       5,
+		"lessons":        2,
+		"learningPoints": []string{"Basics"},
+		"content": []map[string]interface{}{
+			{"title": "Introduction", "content": "<p>Hello</p>", "topics": []string{"intro"}},
+		},
+		"videoCourses": []map[string]interface{}{
+			{
+				"title":       "Welcome",
+				"description": "Getting started",
+				"youtube_id":  "dQw4w9WgXcQ",
+				"duration":    "12:30",
+				"instructor":  "Ada",
+				"level":       "beginner",
+			},
+		},
+	}, &material)
+	return material
+}
@@ -0,0 +1,96 @@
+package routes_test
+
+import (
+	"course-api/models"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordSpans sends the spans ended until the test ends to a recorder
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+	return recorder
+}
+
+func TestTracing(t *testing.T) {
+	// Before the environment, so its Redis client is traced by this provider
+	recorder := recordSpans(t)
+	e := newTestEnv(t)
+	token := e.tokenFor(models.RoleStudent)
+	course := e.createCourse("Go Basics")
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	const parentID = "00f067aa0ba902b7"
+	req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/courses/%d", course), nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("traceparent", "00-"+traceID+"-"+parentID+"-01")
+	resp, err := e.app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	var server sdktrace.ReadOnlySpan
+	var children []sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		if span.SpanContext().TraceID().String() != traceID {
+			continue
+		}
+		if span.Name() == "GET /api/v1/courses/:id" {
+			server = span
+		} else {
+			children = append(children, span)
+		}
+	}
+	if server == nil {
+		t.Fatalf("no request span in the caller's trace")
+	}
+	if got := server.Parent().SpanID().String(); got != parentID {
+		t.Errorf("request span parent %s, want %s", got, parentID)
+	}
+
+	var statements, commands int
+	for _, span := range children {
+		if span.Parent().SpanID() != server.SpanContext().SpanID() {
+			t.Errorf("span %q is not a child of the request span", span.Name())
+		}
+		switch {
+		case strings.HasPrefix(span.Name(), "gorm."):
+			statements++
+			for _, attr := range span.Attributes() {
+				if attr.Key == "db.query.text" && strings.Contains(attr.Value.AsString(), "Go Basics") {
+					t.Errorf("span %q records query values", span.Name())
+				}
+			}
+		default:
+			commands++
+		}
+	}
+	if statements == 0 {
+		t.Errorf("no GORM spans in %d children", len(children))
+	}
+	if commands == 0 {
+		t.Errorf("no Redis spans in %d children", len(children))
+	}
+}
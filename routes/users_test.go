@@ -0,0 +1,101 @@
+package routes_test
+
+import (
+	"course-api/models"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestUserRoutes(t *testing.T) {
+	e := newTestEnv(t)
+	admin := e.tokenFor(models.RoleAdmin)
+	target := e.createUser(models.RoleStudent, "password")
+	targetToken := e.signIn(target.Email, "password")
+	path := fmt.Sprintf("/api/v1/users/%d", target.ID)
+
+	var self models.User
+	if err := e.db.Where("role = ?", models.RoleAdmin).First(&self).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// stored reloads the target user from the database
+	stored := func(t *testing.T) models.User {
+		t.Helper()
+		var user models.User
+		if err := e.db.First(&user, target.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		return user
+	}
+	active := func(v bool) *bool { return &v }
+
+	e.runCases([]routeCase{
+		{name: "list as student", method: http.MethodGet, path: "/api/v1/users/", role: models.RoleStudent, status: http.StatusForbidden},
+		{name: "list as mentor", method: http.MethodGet, path: "/api/v1/users/", role: models.RoleMentor, status: http.StatusForbidden},
+		{name: "list with an unknown role", method: http.MethodGet, path: "/api/v1/users/?role=owner", role: models.RoleAdmin, status: http.StatusBadRequest},
+		{name: "get a missing user", method: http.MethodGet, path: "/api/v1/users/9999", role: models.RoleAdmin, status: http.StatusNotFound},
+		{name: "update role as mentor", method: http.MethodPut, path: path + "/role", role: models.RoleMentor, body: models.UpdateUserRoleInput{Role: models.RoleAdmin}, status: http.StatusForbidden},
+		{name: "update to an unknown role", method: http.MethodPut, path: path + "/role", role: models.RoleAdmin, body: models.UpdateUserRoleInput{Role: "owner"}, status: http.StatusBadRequest},
+		{name: "reset a short password", method: http.MethodPut, path: path + "/password", role: models.RoleAdmin, body: models.ResetUserPasswordInput{Password: "short"}, status: http.StatusBadRequest},
+	})
+
+	t.Run("list and get", func(t *testing.T) {
+		var users []models.User
+		e.mustDo(http.MethodGet, "/api/v1/users/?role=student&q="+strings.ToUpper(target.Email[:8]), admin, nil, &users)
+		if len(users) != 1 || users[0].ID != target.ID {
+			t.Errorf("users %+v, want the student", users)
+		}
+
+		status, resp := e.do(http.MethodGet, path, admin, nil)
+		if status != http.StatusOK || strings.Contains(string(resp.Data), "password") || !strings.Contains(string(resp.Data), target.Email) {
+			t.Errorf("status %d with user %s, want it without its password", status, resp.Data)
+		}
+	})
+
+	t.Run("update role", func(t *testing.T) {
+		var updated models.User
+		e.mustDo(http.MethodPut, path+"/role", admin, models.UpdateUserRoleInput{Role: models.RoleMentor}, &updated)
+		if updated.Role != models.RoleMentor || stored(t).Role != models.RoleMentor {
+			t.Errorf("role %q, stored %q, want mentor", updated.Role, stored(t).Role)
+		}
+		// The sessions carrying the old role are signed out
+		if status, _ := e.do(http.MethodGet, "/api/v1/courses/", targetToken, nil); status != http.StatusUnauthorized {
+			t.Errorf("token of the old role: status %d, want %d", status, http.StatusUnauthorized)
+		}
+	})
+
+	t.Run("the last admin stays", func(t *testing.T) {
+		selfPath := fmt.Sprintf("/api/v1/users/%d", self.ID)
+		if status, resp := e.do(http.MethodPut, selfPath+"/role", admin, models.UpdateUserRoleInput{Role: models.RoleStudent}); status != http.StatusBadRequest || resp.Error == nil || resp.Error.Code != "LAST_ACTIVE_ADMIN" {
+			t.Errorf("demoting the last admin: status %d with %+v", status, resp.Error)
+		}
+		if status, resp := e.do(http.MethodPut, selfPath+"/status", admin, models.UpdateUserStatusInput{IsActive: active(false)}); status != http.StatusBadRequest || resp.Error == nil || resp.Error.Code != "CANNOT_DEACTIVATE_SELF" {
+			t.Errorf("deactivating oneself: status %d with %+v", status, resp.Error)
+		}
+	})
+
+	t.Run("deactivate", func(t *testing.T) {
+		e.mustDo(http.MethodPut, path+"/status", admin, models.UpdateUserStatusInput{IsActive: active(false)}, nil)
+		if stored(t).IsActive {
+			t.Error("stored user is still active")
+		}
+		if status, resp := e.do(http.MethodPost, "/api/v1/auth/signin", "", models.LoginInput{Email: target.Email, Password: "password"}); status != http.StatusForbidden || resp.Error == nil || resp.Error.Code != "ACCOUNT_DEACTIVATED" {
+			t.Errorf("signing in deactivated: status %d with %+v", status, resp.Error)
+		}
+		e.mustDo(http.MethodPut, path+"/status", admin, models.UpdateUserStatusInput{IsActive: active(true)}, nil)
+	})
+
+	t.Run("reset password", func(t *testing.T) {
+		before := stored(t).Password
+		e.mustDo(http.MethodPut, path+"/password", admin, models.ResetUserPasswordInput{Password: "new-password"}, nil)
+		if after := stored(t).Password; after == before || after == "new-password" {
+			t.Errorf("stored password %q, want a new hash", after)
+		}
+		if status, _ := e.do(http.MethodPost, "/api/v1/auth/signin", "", models.LoginInput{Email: target.Email, Password: "password"}); status != http.StatusUnauthorized {
+			t.Errorf("signing in with the old password: status %d, want %d", status, http.StatusUnauthorized)
+		}
+		e.signIn(target.Email, "new-password")
+	})
+}
@@ -1,81 +1,371 @@
 package cache
 
 import (
+	"bytes"
 	"context"
+	"course-api/config"
+	"course-api/utils/metrics"
 	"encoding/json"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"strconv"
+	"strings"
 	"time"
 
-	"course-api/config"
-
 	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
 )
 
 // DefaultExpiration is the default cache expiration time
 const DefaultExpiration = 15 * time.Minute
 
-// Set stores a value in the cache with the given key and expiration
-func Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
-	if config.RedisClient == nil {
-		return nil // Silently skip if Redis is not available
+// Cache stores JSON encoded values in Redis, behind an optional in-process tier
+// answering repeated reads without a round trip. Without a Redis client the
+// in-process tier is used alone, and without either every lookup is a miss and
+// writes are skipped, so callers need no special casing.
+// Every Redis key starts with the cache's prefix, so the cache can share a Redis
+// instance with other data and be cleared without touching it.
+type Cache struct {
+	client      *redis.Client
+	local       *local // nil when the in-process tier is disabled
+	prefix      string
+	staleFor    time.Duration // How long expired values are still served while refreshed
+	jitter      float64       // Fraction of the TTL randomly added to it
+	lockTimeout time.Duration // 0 when loads are not locked across instances
+	flights     singleflight.Group
+	metrics     *metrics.Metrics
+
+	origin string        // Identifies the invalidations this instance publishes
+	pubsub *redis.PubSub // Subscribed to the invalidations of other instances
+	done   chan struct{} // Closed when the subscriber stops
+}
+
+// New returns a Cache backed by client, which may be nil to only cache in process.
+// With both Redis and the in-process tier, New subscribes to the invalidations
+// published by other instances; Close stops the subscriber.
+// Hits, misses and errors are counted in m when it is not nil.
+func New(client *redis.Client, settings config.CacheSettings, m *metrics.Metrics) *Cache {
+	c := &Cache{
+		client:   client,
+		prefix:   settings.Prefix,
+		staleFor: settings.StaleFor,
+		jitter:   settings.Jitter,
+		metrics:  m,
+	}
+	if settings.Lock {
+		c.lockTimeout = settings.LockTimeout
+	}
+	if settings.LocalSize > 0 {
+		c.local = newLocal(int64(settings.LocalSize)<<20, settings.LocalTTL)
+	}
+	if c.local != nil && c.client != nil {
+		c.subscribe()
+	}
+	return c
+}
+
+// redisKey is the Redis key of a cache key
+func (c *Cache) redisKey(key string) string {
+	return c.prefix + key
+}
+
+// Entry describes a cached value: its key, how long it is kept and the tags
+// that invalidate it
+type Entry struct {
+	Key  string
+	TTL  time.Duration // DefaultExpiration when 0
+	Tags []string
+}
+
+// tagKey is the Redis set listing the keys tagged with tag
+func tagKey(tag string) string {
+	return "tag:" + tag
+}
+
+// generationKey is the Redis counter of the invalidations of tag, see generations
+func generationKey(tag string) string {
+	return tagKey(tag) + ":generation"
+}
+
+// generationTTL is how long a tag's generation outlives its last invalidation. It
+// exceeds loadTimeout so a counter cannot expire and count again during a load.
+const generationTTL = 2 * loadTimeout
+
+// setScript stores the entry and adds it to its ARGV[3] tag sets. A tag set lives
+// at least as long as its longest entry, so invalidating a tag reaches every live
+// entry. When ARGV holds generations past ARGV[3], the entry is only stored, and 1
+// returned, if the generation keys following the tag sets still hold them.
+var setScript = redis.NewScript(`
+local tags = tonumber(ARGV[3])
+for i = 1, #ARGV - 3 do
+	if tonumber(redis.call('GET', KEYS[1 + tags + i]) or 0) ~= tonumber(ARGV[3 + i]) then
+		return 0
+	end
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+for i = 2, 1 + tags do
+	redis.call('SADD', KEYS[i], KEYS[1])
+	if redis.call('PTTL', KEYS[i]) < tonumber(ARGV[2]) then
+		redis.call('PEXPIRE', KEYS[i], ARGV[2])
+	end
+end
+return 1
+`)
+
+// invalidateScript deletes every key listed in the tag sets, then the sets themselves,
+// counts the invalidation in the generation keys following the sets, and returns the
+// listed keys. The tagged keys are deleted in batches to stay below Lua's unpack limit.
+var invalidateScript = redis.NewScript(`
+local invalidated = {}
+local tags = #KEYS / 2
+for i = 1, tags do
+	local keys = redis.call('SMEMBERS', KEYS[i])
+	for j = 1, #keys, 500 do
+		redis.call('DEL', unpack(keys, j, math.min(j + 499, #keys)))
+	end
+	for _, key in ipairs(keys) do
+		table.insert(invalidated, key)
+	end
+	redis.call('DEL', KEYS[i])
+	redis.call('INCR', KEYS[tags + i])
+	redis.call('PEXPIRE', KEYS[tags + i], ARGV[1])
+end
+return invalidated
+`)
+
+// Values are stored as "<fresh until>|<JSON>", the first part being the Unix time
+// in milliseconds after which the value is stale. Redis keeps a value for staleFor
+// longer than that, so an expired value can be served while it is refreshed.
+const freshSeparator = '|'
+
+// Set stores value under the entry's key and tags it with the entry's tags. The
+// TTL is extended by a random jitter so entries cached together expire apart.
+func (c *Cache) Set(ctx context.Context, entry Entry, value interface{}) error {
+	return c.set(ctx, entry, value, nil)
+}
+
+// generations is a snapshot of the invalidations of an entry's tags: in Redis,
+// per tag, and in process, where any invalidation counts
+type generations struct {
+	redis []int64
+	local uint64
+}
+
+// generations reads the invalidation counts of the tags, which a value loaded
+// from then on is only cached with (see set)
+func (c *Cache) generations(ctx context.Context, tags []string) (*generations, error) {
+	gens := &generations{}
+	if c.local != nil {
+		gens.local = c.local.currentGeneration()
+	}
+	if c.client == nil || len(tags) == 0 {
+		return gens, nil
+	}
+
+	keys := make([]string, len(tags))
+	for i, tag := range tags {
+		keys[i] = c.redisKey(generationKey(tag))
+	}
+	values, err := c.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		c.failed(ctx, "generations", tagKey(tags[0]), err)
+		return nil, err
+	}
+	gens.redis = make([]int64, len(values))
+	for i, value := range values {
+		if value != nil {
+			gens.redis[i], _ = strconv.ParseInt(value.(string), 10, 64)
+		}
+	}
+	return gens, nil
+}
+
+// set is Set skipping the write when since is not nil and one of the entry's
+// tags was invalidated after since was read, as the value may predate the write
+// that invalidated it. Each tier checks its own counters: Redis its per-tag
+// generations, the in-process tier its generation, which any invalidation bumps.
+func (c *Cache) set(ctx context.Context, entry Entry, value interface{}, since *generations) error {
+	if c.client == nil && c.local == nil {
+		return nil // Silently skip if caching is disabled
 	}
+	ttl := entry.TTL
+	if ttl <= 0 {
+		ttl = DefaultExpiration
+	}
+	if c.jitter > 0 {
+		ttl += rand.N(time.Duration(float64(ttl)*c.jitter) + 1)
+	}
+
 	data, err := json.Marshal(value)
 	if err != nil {
+		c.failed(ctx, "set", entry.Key, err)
 		return err
 	}
-	return config.RedisClient.Set(ctx, key, data, expiration).Err()
-}
+	freshUntil := time.Now().Add(ttl)
+	stored := strconv.AppendInt(nil, freshUntil.UnixMilli(), 10)
+	stored = append(append(stored, freshSeparator), data...)
+	keepLocally := func() {
+		if c.local == nil || (since != nil && c.local.currentGeneration() != since.local) {
+			return
+		}
+		c.local.set(entry.Key, stored, entry.Tags, freshUntil.Add(c.staleFor))
+	}
+	if c.client == nil {
+		keepLocally()
+		return nil
+	}
 
-// Get retrieves a value from the cache and unmarshals it into the provided interface
-func Get(ctx context.Context, key string, dest interface{}) error {
-	if config.RedisClient == nil {
-		return redis.Nil // Return cache miss if Redis is not available
+	keys := make([]string, 0, 2*len(entry.Tags)+1)
+	keys = append(keys, c.redisKey(entry.Key))
+	for _, tag := range entry.Tags {
+		keys = append(keys, c.redisKey(tagKey(tag)))
+	}
+	args := []interface{}{stored, (ttl + c.staleFor).Milliseconds(), len(entry.Tags)}
+	if since != nil {
+		for i, tag := range entry.Tags {
+			keys = append(keys, c.redisKey(generationKey(tag)))
+			args = append(args, since.redis[i])
+		}
 	}
-	data, err := config.RedisClient.Get(ctx, key).Bytes()
+	written, err := setScript.Run(ctx, c.client, keys, args...).Int()
 	if err != nil {
+		// The in-process tier still spares this instance the next load
+		keepLocally()
+		c.failed(ctx, "set", entry.Key, err)
 		return err
 	}
-	return json.Unmarshal(data, dest)
+	if written == 1 {
+		keepLocally()
+	}
+	return nil
 }
 
-// Delete removes a key from the cache
-func Delete(ctx context.Context, key string) error {
-	if config.RedisClient == nil {
-		return nil // Silently skip if Redis is not available
-	}
-	return config.RedisClient.Del(ctx, key).Err()
+// Get retrieves a value from the cache, fresh or stale, and unmarshals it into dest
+func (c *Cache) Get(ctx context.Context, key string, dest interface{}) error {
+	_, err := c.lookup(ctx, key, dest)
+	return err
 }
 
-// Clear removes all keys from the cache
-func Clear(ctx context.Context) error {
-	if config.RedisClient == nil {
-		return nil // Silently skip if Redis is not available
+// lookup is Get telling whether the value is fresh, counting hits and misses
+func (c *Cache) lookup(ctx context.Context, key string, dest interface{}) (fresh bool, err error) {
+	fresh, err = c.read(ctx, key, dest)
+
+	switch {
+	case err == nil:
+		if c.metrics != nil {
+			c.metrics.CacheHit(key)
+		}
+	case errors.Is(err, redis.Nil):
+		if c.metrics != nil {
+			c.metrics.CacheMiss(key)
+		}
+	default:
+		c.failed(ctx, "get", key, err)
 	}
-	return config.RedisClient.FlushAll(ctx).Err()
+	return fresh, err
 }
 
-// GetOrSet retrieves a value from cache or sets it if not found
-func GetOrSet(ctx context.Context, key string, dest interface{}, fetchFunc func() (interface{}, error)) error {
-	// Try to get from cache first
-	err := Get(ctx, key, dest)
-	if err == nil {
-		return nil
+// read unmarshals the value of key into dest and tells whether it is fresh,
+// reading the in-process tier before Redis and keeping what Redis returns there.
+// A missing key is redis.Nil.
+func (c *Cache) read(ctx context.Context, key string, dest interface{}) (fresh bool, err error) {
+	var data []byte
+	var cached bool
+	if c.local != nil {
+		data, cached = c.local.get(key)
+	}
+	if !cached {
+		if c.client == nil {
+			return false, redis.Nil // Return cache miss if Redis is not available
+		}
+		if data, err = c.client.Get(ctx, c.redisKey(key)).Bytes(); err != nil {
+			return false, err
+		}
 	}
 
-	// If not in cache or Redis is not available, fetch the data
-	data, err := fetchFunc()
-	if err != nil {
-		return err
+	header, value, found := bytes.Cut(data, []byte{freshSeparator})
+	freshUntil, err := strconv.ParseInt(string(header), 10, 64)
+	if !found || err != nil {
+		return false, errors.New("cache: malformed value")
+	}
+	if err := json.Unmarshal(value, dest); err != nil {
+		return false, err
+	}
+	if !cached && c.local != nil {
+		// Redis tracks the entry's tags, and broadcasts the keys it invalidates
+		c.local.set(key, data, nil, time.UnixMilli(freshUntil).Add(c.staleFor))
 	}
+	return time.Now().UnixMilli() < freshUntil, nil
+}
 
-	// Store in cache (will be skipped if Redis is not available)
-	if err := Set(ctx, key, data, DefaultExpiration); err != nil {
+// Delete removes the keys from the cache
+func (c *Cache) Delete(ctx context.Context, keys ...string) error {
+	if c.local != nil {
+		c.local.delete(keys...)
+	}
+	if c.client == nil || len(keys) == 0 {
+		return nil // Silently skip if Redis is not available
+	}
+	redisKeys := make([]string, len(keys))
+	for i, key := range keys {
+		redisKeys[i] = c.redisKey(key)
+	}
+	err := c.client.Del(ctx, redisKeys...).Err()
+	if err != nil {
+		for _, key := range keys {
+			c.failed(ctx, "delete", key, err)
+		}
 		return err
 	}
+	c.publish(ctx, invalidation{Keys: keys})
+	return nil
+}
 
-	// Copy the fresh data to destination
-	jsonData, err := json.Marshal(data)
+// Invalidate removes every entry tagged with one of the tags
+func (c *Cache) Invalidate(ctx context.Context, tags ...string) error {
+	if c.local != nil {
+		c.local.invalidate(tags...)
+	}
+	if c.client == nil || len(tags) == 0 {
+		return nil // Silently skip if Redis is not available
+	}
+	keys := make([]string, 2*len(tags))
+	for i, tag := range tags {
+		keys[i] = c.redisKey(tagKey(tag))
+		keys[len(tags)+i] = c.redisKey(generationKey(tag))
+	}
+	deleted, err := invalidateScript.Run(ctx, c.client, keys, generationTTL.Milliseconds()).StringSlice()
 	if err != nil {
+		for _, tag := range tags {
+			c.failed(ctx, "invalidate", tagKey(tag), err)
+		}
 		return err
 	}
-	return json.Unmarshal(jsonData, dest)
+
+	// Entries read from Redis are held in process without their tags
+	invalidated := make([]string, len(deleted))
+	for i, key := range deleted {
+		invalidated[i] = strings.TrimPrefix(key, c.prefix)
+	}
+	if c.local != nil {
+		c.local.delete(invalidated...)
+	}
+	c.publish(ctx, invalidation{Keys: invalidated})
+	return nil
+}
+
+// Clear removes every key of the cache, leaving other data in Redis alone
+func (c *Cache) Clear(ctx context.Context) error {
+	_, err := c.Purge(ctx, "")
+	return err
+}
+
+// failed counts and logs a failed operation on key. Callers treat cache failures
+// as misses, so this is where they become visible.
+func (c *Cache) failed(ctx context.Context, operation, key string, err error) {
+	if c.metrics != nil {
+		c.metrics.CacheError(key, operation)
+	}
+	slog.WarnContext(ctx, "Cache operation failed", "operation", operation, "key", key, "error", err)
 }
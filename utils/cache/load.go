@@ -0,0 +1,149 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// loadTimeout bounds a load shared by concurrent readers, which runs detached
+// from the request that started it
+const loadTimeout = 30 * time.Second
+
+// lockPoll is how often a reader waiting for another instance's load checks for its value
+const lockPoll = 50 * time.Millisecond
+
+// lockKey is the Redis key an instance holds while it loads key
+func lockKey(key string) string {
+	return "lock:" + key
+}
+
+// unlockScript releases a lock only when it is still held with the given token,
+// so a load outliving its lock cannot release another instance's lock
+var unlockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// GetOrSet reads the entry through the cache: a cached value is returned with hit
+// set, otherwise load is called and its value cached. Load errors are returned and
+// not cached; failing to cache the value is logged and does not fail the read.
+//
+// Concurrent misses of a key in the process share one load, and with locking
+// enabled instances wait for the one holding the key's lock instead of loading
+// too. A stale value is returned as a hit while one goroutine refreshes it.
+func GetOrSet[T any](ctx context.Context, c *Cache, entry Entry, load func(context.Context) (T, error)) (value T, hit bool, err error) {
+	fresh, err := c.lookup(ctx, entry.Key, &value)
+	if err == nil {
+		if !fresh {
+			c.flights.DoChan(entry.Key, func() (interface{}, error) {
+				refreshed, err := fill(ctx, c, entry, load)
+				if err != nil {
+					slog.WarnContext(ctx, "Cache refresh failed", "key", entry.Key, "error", err)
+				}
+				return refreshed, err
+			})
+		}
+		return value, true, nil
+	}
+
+	var zero T
+	loaded := c.flights.DoChan(entry.Key, func() (interface{}, error) {
+		return fill(ctx, c, entry, load)
+	})
+	select {
+	case <-ctx.Done():
+		return zero, false, ctx.Err()
+	case result := <-loaded:
+		if result.Err != nil {
+			return zero, false, result.Err
+		}
+		return result.Val.(T), false, nil
+	}
+}
+
+// fill loads the entry's value and caches it, unless one of the entry's tags is
+// invalidated during the load. It runs detached from ctx, whose request may end
+// before the load other readers are waiting for. With locking enabled it first
+// takes the key's lock, or waits for the instance holding it.
+func fill[T any](ctx context.Context, c *Cache, entry Entry, load func(context.Context) (T, error)) (T, error) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), loadTimeout)
+	defer cancel()
+
+	if c.lockTimeout > 0 && c.client != nil {
+		unlock, locked := c.lock(ctx, entry.Key)
+		if !locked {
+			var value T
+			if c.await(ctx, entry.Key, &value) {
+				return value, nil
+			}
+		} else {
+			defer unlock()
+			// The previous holder may have just cached a fresh value
+			var value T
+			if fresh, err := c.read(ctx, entry.Key, &value); err == nil && fresh {
+				return value, nil
+			}
+		}
+	}
+
+	// A write invalidating the entry while it loads makes the value outdated, and
+	// without the counts of invalidations so far that cannot be told
+	since, genErr := c.generations(ctx, entry.Tags)
+	value, err := load(ctx)
+	if err != nil || genErr != nil {
+		return value, err
+	}
+	_ = c.set(ctx, entry, value, since)
+	return value, nil
+}
+
+// lock takes the lock of key for lockTimeout. When Redis fails, the caller loads
+// as if it held the lock rather than failing the read.
+func (c *Cache) lock(ctx context.Context, key string) (unlock func(), locked bool) {
+	token := make([]byte, 16)
+	_, _ = rand.Read(token)
+	value := hex.EncodeToString(token)
+	redisKey := c.redisKey(lockKey(key))
+
+	locked, err := c.client.SetNX(ctx, redisKey, value, c.lockTimeout).Result()
+	if err != nil {
+		c.failed(ctx, "lock", key, err)
+		return func() {}, true
+	}
+	return func() {
+		if err := unlockScript.Run(ctx, c.client, []string{redisKey}, value).Err(); err != nil {
+			c.failed(ctx, "unlock", key, err)
+		}
+	}, locked
+}
+
+// await waits for the instance holding the lock of key to cache its value and
+// reads it into dest. It gives up when the lock is released or expires without
+// a value, and the caller then loads the value itself.
+func (c *Cache) await(ctx context.Context, key string, dest interface{}) bool {
+	deadline := time.Now().Add(c.lockTimeout)
+	for time.Now().Before(deadline) {
+		// A stale value is good enough while another instance refreshes it
+		if _, err := c.read(ctx, key, dest); err == nil {
+			return true
+		}
+		if held, err := c.client.Exists(ctx, c.redisKey(lockKey(key))).Result(); err != nil || held == 0 {
+			_, err := c.read(ctx, key, dest)
+			return err == nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(lockPoll):
+		}
+	}
+	return false
+}
@@ -0,0 +1,177 @@
+package cache
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+)
+
+// local is the in-process tier in front of Redis: a least recently used list of
+// stored values, bounded by their total size, each dropped when it expires. It
+// indexes its entries by tag so it can be invalidated without Redis.
+type local struct {
+	mu       sync.Mutex
+	maxBytes int64
+	ttl      time.Duration // Longest an entry is kept, however long it is fresh in Redis
+	bytes    int64
+	order    *list.List // Of *localEntry, the most recently used first
+	entries  map[string]*list.Element
+	tags     map[string]map[string]struct{} // Keys tagged with each tag
+
+	generation uint64 // Counts invalidations and purges, see Cache.generations
+}
+
+// localEntry is a stored value, in the format kept in Redis
+type localEntry struct {
+	key     string
+	data    []byte
+	tags    []string
+	expires time.Time
+}
+
+// newLocal returns an empty local tier holding up to maxBytes of values, each for at most ttl
+func newLocal(maxBytes int64, ttl time.Duration) *local {
+	return &local{
+		maxBytes: maxBytes,
+		ttl:      ttl,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+		tags:     make(map[string]map[string]struct{}),
+	}
+}
+
+// size is what an entry counts against maxBytes
+func (e *localEntry) size() int64 {
+	return int64(len(e.key) + len(e.data))
+}
+
+// get returns the stored value of key, marking it as recently used
+func (l *local) get(key string) ([]byte, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	el, ok := l.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*localEntry)
+	if !time.Now().Before(entry.expires) {
+		l.remove(el)
+		return nil, false
+	}
+	l.order.MoveToFront(el)
+	return entry.data, true
+}
+
+// set stores the value of key until expires, or for the tier's TTL when sooner,
+// evicting the least recently used values beyond maxBytes. Values larger than
+// maxBytes are not kept.
+func (l *local) set(key string, data []byte, tags []string, expires time.Time) {
+	if limit := time.Now().Add(l.ttl); limit.Before(expires) {
+		expires = limit
+	}
+	entry := &localEntry{key: key, data: data, tags: tags, expires: expires}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if el, ok := l.entries[key]; ok {
+		l.remove(el)
+	}
+	if entry.size() > l.maxBytes {
+		return
+	}
+	l.entries[key] = l.order.PushFront(entry)
+	l.bytes += entry.size()
+	for _, tag := range tags {
+		keys := l.tags[tag]
+		if keys == nil {
+			keys = make(map[string]struct{})
+			l.tags[tag] = keys
+		}
+		keys[key] = struct{}{}
+	}
+	for l.bytes > l.maxBytes {
+		l.remove(l.order.Back())
+	}
+}
+
+// delete drops the keys
+func (l *local) delete(keys ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, key := range keys {
+		if el, ok := l.entries[key]; ok {
+			l.remove(el)
+		}
+	}
+}
+
+// invalidate drops every key tagged with one of the tags
+func (l *local) invalidate(tags ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.generation++
+	for _, tag := range tags {
+		for key := range l.tags[tag] {
+			l.remove(l.entries[key])
+		}
+	}
+}
+
+// purge drops the keys of a namespace, or every key when namespace is empty,
+// and returns how many were dropped
+func (l *local) purge(namespace string) int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.generation++
+	var purged int64
+	for key, el := range l.entries {
+		if namespace == "" || strings.HasPrefix(key, namespace+":") {
+			l.remove(el)
+			purged++
+		}
+	}
+	return purged
+}
+
+// currentGeneration counts the invalidations and purges so far
+func (l *local) currentGeneration() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.generation
+}
+
+// LocalStats describes the in-process tier
+type LocalStats struct {
+	Keys     int   `json:"keys"`
+	Bytes    int64 `json:"bytes"`
+	MaxBytes int64 `json:"max_bytes"`
+}
+
+// stats counts the entries held, expired ones included until they are next read or evicted
+func (l *local) stats() LocalStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return LocalStats{Keys: len(l.entries), Bytes: l.bytes, MaxBytes: l.maxBytes}
+}
+
+// remove unlinks an entry from the list, the map and the tag index. The caller holds mu.
+func (l *local) remove(el *list.Element) {
+	entry := l.order.Remove(el).(*localEntry)
+	delete(l.entries, entry.key)
+	l.bytes -= entry.size()
+	for _, tag := range entry.tags {
+		keys := l.tags[tag]
+		delete(keys, entry.key)
+		if len(keys) == 0 {
+			delete(l.tags, tag)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// scanBatch is the COUNT hint of the SCAN calls walking the cache's keys
+const scanBatch = 500
+
+// tagNamespace holds the tag sets and their invalidation counters, see tagKey and generationKey
+const tagNamespace = "tag"
+
+// ErrTagNamespace is returned when purging the tag sets alone, which would leave
+// the tagged entries cached with nothing left to invalidate them
+var ErrTagNamespace = errors.New("the tag namespace can only be purged with the whole cache")
+
+// Namespace is the part of a cache key before its first colon, e.g. "courses"
+// for "courses:42" and "courses:list"
+func Namespace(key string) string {
+	namespace, _, _ := strings.Cut(key, ":")
+	return namespace
+}
+
+// Stats describes the keys of the cache, overall and per namespace, and the
+// in-process tier of this instance when enabled. Keys are counted with SCAN
+// while the cache is in use, so the numbers are approximate.
+type Stats struct {
+	Enabled     bool             `json:"enabled"`
+	Prefix      string           `json:"prefix"`
+	Keys        int64            `json:"keys"`
+	MemoryBytes int64            `json:"memory_bytes"`
+	Namespaces  []NamespaceStats `json:"namespaces"`
+	Local       *LocalStats      `json:"local,omitempty"`
+}
+
+// NamespaceStats describes the keys of one namespace. The TTLs are the remaining
+// lifetimes of the keys that expire; NoTTL counts those that do not.
+type NamespaceStats struct {
+	Namespace     string `json:"namespace"`
+	Keys          int64  `json:"keys"`
+	MemoryBytes   int64  `json:"memory_bytes"`
+	MinTTLSeconds int64  `json:"min_ttl_seconds"`
+	MaxTTLSeconds int64  `json:"max_ttl_seconds"`
+	AvgTTLSeconds int64  `json:"avg_ttl_seconds"`
+	NoTTL         int64  `json:"no_ttl"`
+
+	ttlSum time.Duration
+}
+
+// Stats walks the cache's keys and reports their count, memory and TTLs
+func (c *Cache) Stats(ctx context.Context) (Stats, error) {
+	stats := Stats{Enabled: c.client != nil || c.local != nil, Prefix: c.prefix, Namespaces: []NamespaceStats{}}
+	if c.local != nil {
+		local := c.local.stats()
+		stats.Local = &local
+	}
+	if c.client == nil {
+		return stats, nil
+	}
+
+	byName := make(map[string]*NamespaceStats)
+	err := c.scan(ctx, "", func(keys []string) error {
+		pipe := c.client.Pipeline()
+		ttls := make([]*redis.DurationCmd, len(keys))
+		sizes := make([]*redis.Cmd, len(keys))
+		for i, key := range keys {
+			ttls[i] = pipe.PTTL(ctx, key)
+			// Spelled in upper case, which every Redis compatible server accepts
+			sizes[i] = pipe.Do(ctx, "MEMORY", "USAGE", key)
+		}
+		// Keys expiring since the SCAN answer redis.Nil and are skipped below
+		if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+			return err
+		}
+
+		for i, key := range keys {
+			ttl := ttls[i].Val()
+			size, err := sizes[i].Int64()
+			if ttls[i].Err() != nil || err != nil || ttl == -2 {
+				continue
+			}
+
+			name := Namespace(strings.TrimPrefix(key, c.prefix))
+			ns := byName[name]
+			if ns == nil {
+				ns = &NamespaceStats{Namespace: name}
+				byName[name] = ns
+			}
+			ns.Keys++
+			ns.MemoryBytes += size
+			if ttl == -1 {
+				ns.NoTTL++
+				continue
+			}
+			seconds := int64(ttl / time.Second)
+			if ns.Keys-ns.NoTTL == 1 || seconds < ns.MinTTLSeconds {
+				ns.MinTTLSeconds = seconds
+			}
+			if seconds > ns.MaxTTLSeconds {
+				ns.MaxTTLSeconds = seconds
+			}
+			ns.ttlSum += ttl
+		}
+		return nil
+	})
+	if err != nil {
+		c.failed(ctx, "stats", "", err)
+		return stats, err
+	}
+
+	for _, ns := range byName {
+		if expiring := ns.Keys - ns.NoTTL; expiring > 0 {
+			ns.AvgTTLSeconds = int64(ns.ttlSum/time.Second) / expiring
+		}
+		stats.Keys += ns.Keys
+		stats.MemoryBytes += ns.MemoryBytes
+		stats.Namespaces = append(stats.Namespaces, *ns)
+	}
+	sort.Slice(stats.Namespaces, func(i, j int) bool {
+		return stats.Namespaces[i].Namespace < stats.Namespaces[j].Namespace
+	})
+	return stats, nil
+}
+
+// Purge deletes the keys of a namespace, or of the whole cache when namespace is
+// empty, and returns how many were deleted from Redis, or from the in-process
+// tier without Redis. Keys are found with SCAN and deleted with UNLINK in
+// batches, so Redis is never blocked by one large command. Every instance
+// purges its in-process tier too.
+func (c *Cache) Purge(ctx context.Context, namespace string) (int64, error) {
+	if namespace == tagNamespace {
+		return 0, ErrTagNamespace
+	}
+	var purged int64
+	if c.local != nil {
+		purged = c.local.purge(namespace)
+	}
+	if c.client == nil {
+		return purged, nil // Silently skip if Redis is not available
+	}
+
+	match := ""
+	if namespace != "" {
+		match = namespace + ":"
+	}
+	var deleted int64
+	err := c.scan(ctx, match, func(keys []string) error {
+		n, err := c.client.Unlink(ctx, keys...).Result()
+		deleted += n
+		return err
+	})
+	if err != nil {
+		c.failed(ctx, "purge", namespace, err)
+		return deleted, err
+	}
+	c.publish(ctx, invalidation{Purge: &namespace})
+	return deleted, nil
+}
+
+// scan calls fn with every batch of Redis keys starting with the cache prefix
+// followed by match. SCAN may return a key more than once.
+func (c *Cache) scan(ctx context.Context, match string, fn func(keys []string) error) error {
+	pattern := escapeGlob(c.prefix+match) + "*"
+	var cursor uint64
+	for {
+		keys, next, err := c.client.Scan(ctx, cursor, pattern, scanBatch).Result()
+		if err != nil {
+			return err
+		}
+		if len(keys) > 0 {
+			if err := fn(keys); err != nil {
+				return err
+			}
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// globEscaper escapes the characters that are special in SCAN MATCH patterns
+var globEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+
+// escapeGlob makes s match itself literally in a SCAN MATCH pattern
+func escapeGlob(s string) string {
+	return globEscaper.Replace(s)
+}
@@ -0,0 +1,77 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+)
+
+// invalidationChannel is the Redis channel, under the cache prefix, on which
+// instances announce the keys they invalidate so the others drop their copies
+const invalidationChannel = "invalidations"
+
+// invalidation is a message of the invalidation channel. Purge is set when a
+// namespace, or the whole cache when empty, was purged.
+type invalidation struct {
+	Origin string   `json:"origin"`
+	Keys   []string `json:"keys,omitempty"`
+	Purge  *string  `json:"purge,omitempty"`
+}
+
+// subscribe listens to the invalidations of other instances until Close. Messages
+// missed while the connection is down are not replayed; the in-process TTL bounds
+// how long an entry invalidated meanwhile is still served.
+func (c *Cache) subscribe() {
+	token := make([]byte, 8)
+	_, _ = rand.Read(token)
+	c.origin = hex.EncodeToString(token)
+	c.pubsub = c.client.Subscribe(context.Background(), c.redisKey(invalidationChannel))
+	c.done = make(chan struct{})
+
+	go func() {
+		defer close(c.done)
+		for msg := range c.pubsub.Channel() {
+			var inv invalidation
+			if err := json.Unmarshal([]byte(msg.Payload), &inv); err != nil {
+				slog.Warn("Malformed cache invalidation", "error", err)
+				continue
+			}
+			if inv.Origin == c.origin {
+				continue
+			}
+			if inv.Purge != nil {
+				c.local.purge(*inv.Purge)
+			}
+			c.local.delete(inv.Keys...)
+		}
+	}()
+}
+
+// publish announces an invalidation to the other instances. Nothing is published
+// without an in-process tier, as no instance then holds copies to drop.
+func (c *Cache) publish(ctx context.Context, inv invalidation) {
+	if c.pubsub == nil || (len(inv.Keys) == 0 && inv.Purge == nil) {
+		return
+	}
+	inv.Origin = c.origin
+	payload, err := json.Marshal(inv)
+	if err == nil {
+		err = c.client.Publish(ctx, c.redisKey(invalidationChannel), payload).Err()
+	}
+	if err != nil {
+		c.failed(ctx, "publish", invalidationChannel, err)
+	}
+}
+
+// Close stops listening to the invalidations of other instances, and must be
+// called before the Redis client is closed
+func (c *Cache) Close() error {
+	if c.pubsub == nil {
+		return nil
+	}
+	err := c.pubsub.Close()
+	<-c.done
+	return err
+}
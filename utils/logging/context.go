@@ -0,0 +1,110 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestInfo describes the request a context belongs to. The route and user
+// are only known once routing and authentication ran, so they are filled in
+// while the request is served.
+type RequestInfo struct {
+	ID     string
+	Method string
+	Path   string
+	Start  time.Time
+
+	mu     sync.Mutex
+	route  func() string
+	userID uint
+}
+
+type requestInfoKey struct{}
+
+// NewContext returns a copy of ctx carrying info
+func NewContext(ctx context.Context, info *RequestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, info)
+}
+
+// FromContext returns the request of ctx, or nil outside of a request
+func FromContext(ctx context.Context) *RequestInfo {
+	if ctx == nil {
+		return nil
+	}
+	info, _ := ctx.Value(requestInfoKey{}).(*RequestInfo)
+	return info
+}
+
+// SetRoute sets how to read the matched route pattern, e.g. /api/v1/courses/:id.
+// It is a function because the route changes as the request moves from the
+// middleware to its handler.
+func (i *RequestInfo) SetRoute(route func() string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.route = route
+}
+
+// Route returns the matched route pattern, or the path before one is known
+func (i *RequestInfo) Route() string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.route == nil {
+		return i.Path
+	}
+	return i.route()
+}
+
+// SetUserID records the authenticated user of the request
+func (i *RequestInfo) SetUserID(id uint) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.userID = id
+}
+
+// UserID returns the authenticated user, or 0 for anonymous requests
+func (i *RequestInfo) UserID() uint {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.userID
+}
+
+// attrs returns the attributes added to every record of the request
+func (i *RequestInfo) attrs() []slog.Attr {
+	attrs := []slog.Attr{
+		slog.String("request_id", i.ID),
+		slog.String("method", i.Method),
+		slog.String("route", i.Route()),
+	}
+	if userID := i.UserID(); userID != 0 {
+		attrs = append(attrs, slog.Uint64("user_id", uint64(userID)))
+	}
+	return append(attrs, slog.Float64("elapsed_ms", float64(time.Since(i.Start).Microseconds())/1000))
+}
+
+// contextHandler adds the request attributes and the trace and span IDs of the
+// record's context
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if info := FromContext(ctx); info != nil {
+		r.AddAttrs(info.attrs()...)
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		r.AddAttrs(slog.String("trace_id", span.TraceID().String()), slog.String("span_id", span.SpanID().String()))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,73 @@
+// Package logging builds the structured application logger.
+//
+// Every record logged with a request context, through slog.InfoContext and
+// friends, carries the request ID, method, route, user ID and elapsed time of
+// that request, and the trace and span IDs when it is traced. Attributes whose
+// key names a secret are redacted.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Supported values of LOG_FORMAT
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// Redacted replaces the value of secret attributes
+const Redacted = "[REDACTED]"
+
+// secretKeys are the substrings marking an attribute key as secret
+var secretKeys = []string{"password", "secret", "token", "authorization", "cookie", "api_key", "apikey"}
+
+// New returns a logger writing records at or above level ("debug", "info", "warn"
+// or "error") to w, as JSON or as logfmt-style text
+func New(w io.Writer, level, format string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q: %w", level, err)
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl, ReplaceAttr: redact}
+
+	var handler slog.Handler
+	switch format {
+	case FormatJSON, "":
+		handler = slog.NewJSONHandler(w, opts)
+	case FormatText:
+		handler = slog.NewTextHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("invalid log format %q, expected json or text", format)
+	}
+
+	return slog.New(&contextHandler{Handler: handler}), nil
+}
+
+// redact hides the values of secret attributes and of bearer credentials
+func redact(_ []string, a slog.Attr) slog.Attr {
+	if IsSecretKey(a.Key) {
+		return slog.String(a.Key, Redacted)
+	}
+	if a.Value.Kind() == slog.KindString {
+		if value := a.Value.String(); len(value) > 7 && strings.EqualFold(value[:7], "bearer ") {
+			return slog.String(a.Key, "Bearer "+Redacted)
+		}
+	}
+	return a
+}
+
+// IsSecretKey reports whether values logged under key must be redacted
+func IsSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, secret := range secretKeys {
+		if strings.Contains(key, secret) {
+			return true
+		}
+	}
+	return false
+}
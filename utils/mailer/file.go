@@ -0,0 +1,43 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// LogMailer logs the recipient and subject of messages. Bodies hold verification
+// and reset tokens, which must not reach the logs, so nothing it sends can be
+// acted on; FileMailer keeps them readable.
+type LogMailer struct{}
+
+func (LogMailer) Send(ctx context.Context, msg Message) error {
+	slog.InfoContext(ctx, "Mail", "to", msg.To, "subject", msg.Subject)
+	return nil
+}
+
+// FileMailer writes each message to its own file in Dir, for local use and tests
+type FileMailer struct {
+	Dir string
+}
+
+func (m FileMailer) Send(_ context.Context, msg Message) error {
+	dir := m.Dir
+	if dir == "" {
+		dir = "mail"
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	recipient := strings.NewReplacer("@", "_at_", "/", "_").Replace(msg.To)
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), recipient)
+	content := fmt.Sprintf("To: %s\nSubject: %s\n\n%s\n", msg.To, msg.Subject, msg.Body)
+
+	return os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644)
+}
@@ -0,0 +1,44 @@
+package mailer
+
+import (
+	"context"
+	"log/slog"
+
+	"course-api/config"
+)
+
+// Message is a plain-text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers email messages
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// New returns the mailer selected by the mail settings (smtp, file or log, default file)
+func New(settings config.MailSettings) Mailer {
+	switch driver := settings.Driver; driver {
+	case "smtp":
+		slog.Info("Mailer: sending email through SMTP")
+		return SMTPMailer{
+			Host:     settings.SMTPHost,
+			Port:     settings.SMTPPort,
+			Username: settings.SMTPUsername,
+			Password: settings.SMTPPassword,
+			From:     settings.From,
+		}
+	case "", "file":
+		slog.Info("Mailer: writing email to files", "dir", settings.Dir)
+		return FileMailer{Dir: settings.Dir}
+	case "log":
+		slog.Info("Mailer: writing email to the log")
+		return LogMailer{}
+	default:
+		slog.Warn("Unknown MAIL_DRIVER, email will be written to the log", "driver", driver)
+		return LogMailer{}
+	}
+}
@@ -0,0 +1,117 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics holds the Prometheus collectors of the application in their own registry,
+// so every app instance, including the ones built by tests, starts from zero
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests *prometheus.CounterVec
+	httpDuration *prometheus.HistogramVec
+	dbDuration   *prometheus.HistogramVec
+	dbErrors     *prometheus.CounterVec
+	cacheHits    *prometheus.CounterVec
+	cacheMisses  *prometheus.CounterVec
+	cacheErrors  *prometheus.CounterVec
+}
+
+// New registers the application collectors along with the Go runtime and process collectors
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "HTTP requests by method, route and status code.",
+		}, []string{"method", "route", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "HTTP request latency by method and route.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		dbDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "db_query_duration_seconds",
+			Help:    "Database query duration by operation and table.",
+			Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"operation", "table"}),
+		dbErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "db_query_errors_total",
+			Help: "Failed database queries by operation and table, not counting record not found.",
+		}, []string{"operation", "table"}),
+		cacheHits: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "cache_hits_total",
+			Help: "Cache lookups answered from Redis, by key prefix.",
+		}, []string{"prefix"}),
+		cacheMisses: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "cache_misses_total",
+			Help: "Cache lookups that found no entry, by key prefix.",
+		}, []string{"prefix"}),
+		cacheErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "cache_errors_total",
+			Help: "Failed cache operations by key prefix and operation.",
+		}, []string{"prefix", "operation"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpDuration,
+		m.dbDuration,
+		m.dbErrors,
+		m.cacheHits,
+		m.cacheMisses,
+		m.cacheErrors,
+	)
+	return m
+}
+
+// Handler serves the metrics in the Prometheus text format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// ObserveRequest records a served HTTP request; route is the matched route pattern
+func (m *Metrics) ObserveRequest(method, route string, status int, duration time.Duration) {
+	m.httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	m.httpDuration.WithLabelValues(method, route).Observe(duration.Seconds())
+}
+
+// ObserveQuery records a database query
+func (m *Metrics) ObserveQuery(operation, table string, duration time.Duration, failed bool) {
+	m.dbDuration.WithLabelValues(operation, table).Observe(duration.Seconds())
+	if failed {
+		m.dbErrors.WithLabelValues(operation, table).Inc()
+	}
+}
+
+// CacheHit counts a cache lookup answered from the cache
+func (m *Metrics) CacheHit(key string) {
+	m.cacheHits.WithLabelValues(keyPrefix(key)).Inc()
+}
+
+// CacheMiss counts a cache lookup that found no entry
+func (m *Metrics) CacheMiss(key string) {
+	m.cacheMisses.WithLabelValues(keyPrefix(key)).Inc()
+}
+
+// CacheError counts a failed cache operation such as get, set or delete
+func (m *Metrics) CacheError(key, operation string) {
+	m.cacheErrors.WithLabelValues(keyPrefix(key), operation).Inc()
+}
+
+// keyPrefix labels cache metrics by the part of the key before the first colon,
+// so "courses:42" and "courses:all" are both counted under "courses"
+func keyPrefix(key string) string {
+	prefix, _, _ := strings.Cut(key, ":")
+	return prefix
+}
@@ -0,0 +1,92 @@
+package query
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Filter maps a query parameter to a condition on the list query
+type Filter struct {
+	Param string
+	Build func(value string) (func(*gorm.DB) *gorm.DB, error)
+}
+
+// Equals matches rows whose column equals the parameter value
+func Equals(param, column string) Filter {
+	return Filter{Param: param, Build: func(value string) (func(*gorm.DB) *gorm.DB, error) {
+		return func(db *gorm.DB) *gorm.DB {
+			return db.Where(column+" = ?", value)
+		}, nil
+	}}
+}
+
+// OneOf matches rows whose column equals the value, which must be one of allowed
+func OneOf(param, column string, allowed ...string) Filter {
+	return Filter{Param: param, Build: func(value string) (func(*gorm.DB) *gorm.DB, error) {
+		for _, a := range allowed {
+			if value == a {
+				return func(db *gorm.DB) *gorm.DB {
+					return db.Where(column+" = ?", value)
+				}, nil
+			}
+		}
+		return nil, errors.New("must be one of " + strings.Join(allowed, ", "))
+	}}
+}
+
+// Contains matches rows whose column contains the value, ignoring case
+func Contains(param, column string) Filter {
+	return Filter{Param: param, Build: func(value string) (func(*gorm.DB) *gorm.DB, error) {
+		like := "%" + strings.ToLower(value) + "%"
+		return func(db *gorm.DB) *gorm.DB {
+			return db.Where("LOWER("+column+") LIKE ?", like)
+		}, nil
+	}}
+}
+
+// Min matches rows whose numeric column is greater than or equal to the value
+func Min(param, column string) Filter {
+	return numeric(param, column, ">=")
+}
+
+// Max matches rows whose numeric column is less than or equal to the value
+func Max(param, column string) Filter {
+	return numeric(param, column, "<=")
+}
+
+// Bool matches rows whose boolean column equals the value
+func Bool(param, column string) Filter {
+	return Filter{Param: param, Build: func(value string) (func(*gorm.DB) *gorm.DB, error) {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, errors.New("must be true or false")
+		}
+		return func(db *gorm.DB) *gorm.DB {
+			return db.Where(column+" = ?", b)
+		}, nil
+	}}
+}
+
+// Custom builds an arbitrary condition from the raw parameter value
+func Custom(param string, build func(db *gorm.DB, value string) *gorm.DB) Filter {
+	return Filter{Param: param, Build: func(value string) (func(*gorm.DB) *gorm.DB, error) {
+		return func(db *gorm.DB) *gorm.DB {
+			return build(db, value)
+		}, nil
+	}}
+}
+
+func numeric(param, column, operator string) Filter {
+	return Filter{Param: param, Build: func(value string) (func(*gorm.DB) *gorm.DB, error) {
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, errors.New("must be a number")
+		}
+		return func(db *gorm.DB) *gorm.DB {
+			return db.Where(column+" "+operator+" ?", n)
+		}, nil
+	}}
+}
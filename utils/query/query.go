@@ -0,0 +1,314 @@
+package query
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// DefaultLimit is the page size used when limit is not given
+	DefaultLimit = 20
+	// MaxLimit caps the page size a client can ask for
+	MaxLimit = 100
+)
+
+// ParamError reports an invalid list query parameter
+type ParamError struct {
+	Param   string
+	Message string
+}
+
+func (e *ParamError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Param, e.Message)
+}
+
+// IsParamError reports whether err was caused by invalid query parameters
+func IsParamError(err error) bool {
+	var pe *ParamError
+	return errors.As(err, &pe)
+}
+
+// SortField is one column of the ORDER BY clause
+type SortField struct {
+	Column string
+	Desc   bool
+}
+
+// Options describes what a list endpoint accepts
+type Options struct {
+	// Sortable maps the names accepted in sort= to database columns
+	Sortable map[string]string
+	// DefaultSort is used when sort= is not given, e.g. "-created_at"
+	DefaultSort string
+	Filters     []Filter
+}
+
+// Meta is returned next to list data in the response envelope
+type Meta struct {
+	Page       int    `json:"page,omitempty"`
+	Limit      int    `json:"limit"`
+	Total      int64  `json:"total"`
+	TotalPages int    `json:"total_pages"`
+	HasMore    bool   `json:"has_more"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// Params is a parsed list request
+type Params struct {
+	Page      int
+	Limit     int
+	Sort      []SortField
+	UseCursor bool
+
+	sortSpec string
+	cursor   []json.RawMessage
+	filters  []func(*gorm.DB) *gorm.DB
+	key      []string // See Key
+}
+
+type cursorPayload struct {
+	Sort   string            `json:"s"`
+	Values []json.RawMessage `json:"v"`
+}
+
+// Parse reads page, limit, cursor, sort and filter parameters from the request.
+// Passing cursor= (even empty) switches from page to cursor pagination.
+func Parse(c *fiber.Ctx, opts Options) (*Params, error) {
+	p := &Params{Page: 1, Limit: DefaultLimit}
+
+	if c.Query("limit") != "" {
+		p.Limit = c.QueryInt("limit", 0)
+		if p.Limit < 1 || p.Limit > MaxLimit {
+			return nil, &ParamError{"limit", fmt.Sprintf("must be between 1 and %d", MaxLimit)}
+		}
+	}
+
+	if c.Query("page") != "" {
+		p.Page = c.QueryInt("page", 0)
+		if p.Page < 1 {
+			return nil, &ParamError{"page", "must be 1 or greater"}
+		}
+	}
+
+	p.sortSpec = c.Query("sort", opts.DefaultSort)
+	sortFields, err := parseSort(p.sortSpec, opts.Sortable)
+	if err != nil {
+		return nil, err
+	}
+	p.Sort = sortFields
+
+	if c.Context().QueryArgs().Has("cursor") {
+		p.UseCursor = true
+		if raw := c.Query("cursor"); raw != "" {
+			decoded, err := base64.RawURLEncoding.DecodeString(raw)
+			if err != nil {
+				return nil, &ParamError{"cursor", "malformed cursor"}
+			}
+			var payload cursorPayload
+			if err := json.Unmarshal(decoded, &payload); err != nil || len(payload.Values) != len(p.Sort) {
+				return nil, &ParamError{"cursor", "malformed cursor"}
+			}
+			if payload.Sort != p.sortSpec {
+				return nil, &ParamError{"cursor", "cursor was issued for a different sort"}
+			}
+			p.cursor = payload.Values
+		}
+	}
+
+	if p.Limit != DefaultLimit {
+		p.key = append(p.key, fmt.Sprintf("limit=%d", p.Limit))
+	}
+	if p.UseCursor {
+		p.key = append(p.key, "cursor="+url.QueryEscape(c.Query("cursor")))
+	} else if p.Page != 1 {
+		p.key = append(p.key, fmt.Sprintf("page=%d", p.Page))
+	}
+	if defaults, _ := parseSort(opts.DefaultSort, opts.Sortable); sortKey(p.Sort) != sortKey(defaults) {
+		p.key = append(p.key, "sort="+sortKey(p.Sort))
+	}
+
+	for _, f := range opts.Filters {
+		value := c.Query(f.Param)
+		if value == "" {
+			continue
+		}
+		scope, err := f.Build(value)
+		if err != nil {
+			return nil, &ParamError{f.Param, err.Error()}
+		}
+		p.filters = append(p.filters, scope)
+		// Escaped so a value holding "&" or "=" cannot pass for several parameters
+		p.key = append(p.key, f.Param+"="+url.QueryEscape(value))
+	}
+
+	return p, nil
+}
+
+// Key identifies the page the parameters select: equivalent requests share it,
+// parameters left at their defaults are not part of it, nor are query parameters
+// the endpoint does not accept. It is empty for the first page with no filters.
+func (p *Params) Key() string {
+	return strings.Join(p.key, "&")
+}
+
+// parseSort reads a comma separated list of sort keys, each descending when
+// prefixed with "-", and ends it with the id tie-breaker
+func parseSort(spec string, sortable map[string]string) ([]SortField, error) {
+	var fields []SortField
+	for _, key := range strings.Split(spec, ",") {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		desc := strings.HasPrefix(key, "-")
+		key = strings.TrimPrefix(key, "-")
+		column, ok := sortable[key]
+		if !ok {
+			return nil, &ParamError{"sort", fmt.Sprintf("cannot sort by %q", key)}
+		}
+		fields = append(fields, SortField{Column: column, Desc: desc})
+	}
+	// A unique tie-breaker keeps pages and cursors stable
+	if len(fields) == 0 || fields[len(fields)-1].Column != "id" {
+		fields = append(fields, SortField{Column: "id"})
+	}
+	return fields, nil
+}
+
+// sortKey writes sort fields back as columns, e.g. "-created_at,id"
+func sortKey(fields []SortField) string {
+	columns := make([]string, len(fields))
+	for i, f := range fields {
+		columns[i] = f.Column
+		if f.Desc {
+			columns[i] = "-" + f.Column
+		}
+	}
+	return strings.Join(columns, ",")
+}
+
+// Filtered applies the requested filters to db
+func (p *Params) Filtered(db *gorm.DB) *gorm.DB {
+	return db.Scopes(p.filters...)
+}
+
+// Find loads one page of filtered, sorted rows into dest, which must point to a slice.
+// Scopes such as Preload are only applied to the data query, not to the count.
+func (p *Params) Find(db *gorm.DB, dest interface{}, scopes ...func(*gorm.DB) *gorm.DB) (Meta, error) {
+	db = p.Filtered(db)
+	meta := Meta{Limit: p.Limit}
+
+	if err := db.Session(&gorm.Session{}).Model(dest).Count(&meta.Total).Error; err != nil {
+		return meta, err
+	}
+	meta.TotalPages = int((meta.Total + int64(p.Limit) - 1) / int64(p.Limit))
+
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(dest); err != nil {
+		return meta, err
+	}
+
+	tx := db.Session(&gorm.Session{}).Scopes(scopes...)
+	for _, s := range p.Sort {
+		tx = tx.Order(clause.OrderByColumn{Column: clause.Column{Table: stmt.Table, Name: s.Column}, Desc: s.Desc})
+	}
+
+	if p.UseCursor {
+		if p.cursor != nil {
+			condition, err := p.keysetCondition(stmt)
+			if err != nil {
+				return meta, err
+			}
+			tx = tx.Where(condition)
+		}
+	} else {
+		meta.Page = p.Page
+		tx = tx.Offset((p.Page - 1) * p.Limit)
+	}
+
+	// Fetch one extra row to know whether another page follows
+	if err := tx.Limit(p.Limit + 1).Find(dest).Error; err != nil {
+		return meta, err
+	}
+
+	rows := reflect.ValueOf(dest).Elem()
+	if rows.Len() > p.Limit {
+		meta.HasMore = true
+		rows.Set(rows.Slice(0, p.Limit))
+
+		if p.UseCursor {
+			cursor, err := p.encodeCursor(stmt, rows.Index(p.Limit-1))
+			if err != nil {
+				return meta, err
+			}
+			meta.NextCursor = cursor
+		}
+	}
+
+	return meta, nil
+}
+
+// keysetCondition builds (a > x) OR (a = x AND b > y) ... for the sort columns
+func (p *Params) keysetCondition(stmt *gorm.Statement) (clause.Expression, error) {
+	values := make([]interface{}, len(p.Sort))
+	for i, s := range p.Sort {
+		field := stmt.Schema.LookUpField(s.Column)
+		if field == nil {
+			return nil, fmt.Errorf("unknown sort column %s", s.Column)
+		}
+		ptr := reflect.New(field.FieldType)
+		if err := json.Unmarshal(p.cursor[i], ptr.Interface()); err != nil {
+			return nil, &ParamError{"cursor", "malformed cursor"}
+		}
+		values[i] = ptr.Elem().Interface()
+	}
+
+	var or []clause.Expression
+	for i, s := range p.Sort {
+		and := make([]clause.Expression, 0, i+1)
+		for j := 0; j < i; j++ {
+			and = append(and, clause.Eq{Column: clause.Column{Table: stmt.Table, Name: p.Sort[j].Column}, Value: values[j]})
+		}
+		column := clause.Column{Table: stmt.Table, Name: s.Column}
+		if s.Desc {
+			and = append(and, clause.Lt{Column: column, Value: values[i]})
+		} else {
+			and = append(and, clause.Gt{Column: column, Value: values[i]})
+		}
+		or = append(or, clause.And(and...))
+	}
+
+	return clause.Or(or...), nil
+}
+
+func (p *Params) encodeCursor(stmt *gorm.Statement, row reflect.Value) (string, error) {
+	payload := cursorPayload{Sort: p.sortSpec}
+	for _, s := range p.Sort {
+		field := stmt.Schema.LookUpField(s.Column)
+		if field == nil {
+			return "", fmt.Errorf("unknown sort column %s", s.Column)
+		}
+		value, _ := field.ValueOf(context.Background(), row)
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return "", err
+		}
+		payload.Values = append(payload.Values, raw)
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
@@ -0,0 +1,119 @@
+package search
+
+import (
+	"log/slog"
+	"strings"
+
+	"course-api/models"
+
+	"gorm.io/gorm"
+)
+
+// CourseDocument builds the searchable document of a course
+func CourseDocument(course models.Course) Document {
+	return Document{
+		Type:  TypeCourse,
+		ID:    course.ID,
+		Title: course.Title,
+		Fields: []Field{
+			{Name: "title", Text: course.Title, Weight: 3},
+			{Name: "description", Text: course.Description, Weight: 1},
+		},
+	}
+}
+
+// MaterialDocument builds the searchable document of a material, without its content topics
+func MaterialDocument(material models.Material) Document {
+	return Document{
+		Type:  TypeMaterial,
+		ID:    material.ID,
+		Title: material.Title,
+		Fields: []Field{
+			{Name: "title", Text: material.Title, Weight: 3},
+			{Name: "description", Text: material.Description, Weight: 1},
+			{Name: "learningPoints", Text: strings.Join(material.LearningPoints, ". "), Weight: 1.5},
+		},
+	}
+}
+
+// ContentTopicDocument builds the searchable document of a content topic from its tag-stripped HTML
+func ContentTopicDocument(topic models.ContentTopic) Document {
+	return Document{
+		Type:       TypeContentTopic,
+		ID:         topic.ID,
+		MaterialID: topic.MaterialID,
+		Title:      topic.Title,
+		Fields: []Field{
+			{Name: "title", Text: topic.Title, Weight: 3},
+			{Name: "topics", Text: strings.Join(topic.Topics, ". "), Weight: 2},
+			{Name: "content", Text: StripHTML(topic.Content), Weight: 1},
+		},
+	}
+}
+
+// IndexCourse adds or replaces a course in the index
+func (ix *Index) IndexCourse(course models.Course) {
+	ix.Put(CourseDocument(course))
+}
+
+// RemoveCourse drops a course from the index
+func (ix *Index) RemoveCourse(id uint) {
+	ix.Remove(TypeCourse, id)
+}
+
+// IndexMaterial adds or replaces a material in the index. The material's
+// Content must be preloaded: its topics replace the ones indexed before.
+func (ix *Index) IndexMaterial(material models.Material) {
+	ix.Put(MaterialDocument(material))
+	ix.RemoveWhere(TypeContentTopic, func(d Document) bool { return d.MaterialID == material.ID })
+	for _, topic := range material.Content {
+		ix.IndexContentTopic(topic)
+	}
+}
+
+// RemoveMaterial drops a material and its content topics from the index
+func (ix *Index) RemoveMaterial(id uint) {
+	ix.Remove(TypeMaterial, id)
+	ix.RemoveWhere(TypeContentTopic, func(d Document) bool { return d.MaterialID == id })
+}
+
+// IndexContentTopic adds or replaces a content topic in the index
+func (ix *Index) IndexContentTopic(topic models.ContentTopic) {
+	ix.Put(ContentTopicDocument(topic))
+}
+
+// RemoveContentTopic drops a content topic from the index
+func (ix *Index) RemoveContentTopic(id uint) {
+	ix.Remove(TypeContentTopic, id)
+}
+
+// Rebuild replaces the contents of the index with every course, material and content topic in db
+func (ix *Index) Rebuild(db *gorm.DB) error {
+	var courses []models.Course
+	if err := db.Find(&courses).Error; err != nil {
+		return err
+	}
+	var materials []models.Material
+	if err := db.Find(&materials).Error; err != nil {
+		return err
+	}
+	var topics []models.ContentTopic
+	if err := db.Find(&topics).Error; err != nil {
+		return err
+	}
+
+	fresh := NewIndex()
+	for _, course := range courses {
+		fresh.Put(CourseDocument(course))
+	}
+	for _, material := range materials {
+		fresh.Put(MaterialDocument(material))
+	}
+	for _, topic := range topics {
+		fresh.Put(ContentTopicDocument(topic))
+	}
+
+	ix.swap(fresh)
+	slog.Info("Search index built", "documents", fresh.Len())
+	return nil
+}
@@ -0,0 +1,251 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Document types that can be searched
+const (
+	TypeCourse       = "course"
+	TypeMaterial     = "material"
+	TypeContentTopic = "content_topic"
+)
+
+const (
+	// minPrefixLength is the shortest last query term that also matches longer words,
+	// so "navig" finds "navigation" while typing
+	minPrefixLength = 3
+	// prefixPenalty scales down the score of prefix matches compared to whole words
+	prefixPenalty = 0.5
+	// titlePhraseBoost rewards documents whose title contains the whole query
+	titlePhraseBoost = 1.5
+)
+
+// Field is one weighted piece of searchable text of a document
+type Field struct {
+	Name   string
+	Text   string
+	Weight float64
+}
+
+// Document is something that can be found through search
+type Document struct {
+	Type       string
+	ID         uint
+	MaterialID uint
+	Title      string
+	Fields     []Field
+}
+
+// Hit is one ranked search result
+type Hit struct {
+	Type       string  `json:"type"`
+	ID         uint    `json:"id"`
+	MaterialID uint    `json:"material_id,omitempty"`
+	Title      string  `json:"title"`
+	Field      string  `json:"field"`
+	Snippet    string  `json:"snippet"`
+	Score      float64 `json:"score"`
+}
+
+type docKey struct {
+	Type string
+	ID   uint
+}
+
+type indexedDoc struct {
+	Document
+	// terms holds the term frequencies of every field, in the order of Fields
+	terms []map[string]int
+}
+
+// Index is an in-memory inverted index safe for concurrent use
+type Index struct {
+	mu       sync.RWMutex
+	docs     map[docKey]*indexedDoc
+	postings map[string]map[docKey]struct{}
+}
+
+// NewIndex creates an empty index
+func NewIndex() *Index {
+	return &Index{
+		docs:     make(map[docKey]*indexedDoc),
+		postings: make(map[string]map[docKey]struct{}),
+	}
+}
+
+// Len returns the number of indexed documents
+func (ix *Index) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return len(ix.docs)
+}
+
+// Put adds the document, replacing any earlier version with the same type and ID
+func (ix *Index) Put(doc Document) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	key := docKey{doc.Type, doc.ID}
+	ix.remove(key)
+
+	d := &indexedDoc{Document: doc, terms: make([]map[string]int, len(doc.Fields))}
+	for i, f := range doc.Fields {
+		d.terms[i] = make(map[string]int)
+		for _, term := range Tokenize(f.Text) {
+			d.terms[i][term]++
+			if ix.postings[term] == nil {
+				ix.postings[term] = make(map[docKey]struct{})
+			}
+			ix.postings[term][key] = struct{}{}
+		}
+	}
+	ix.docs[key] = d
+}
+
+// Remove drops a document from the index
+func (ix *Index) Remove(docType string, id uint) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.remove(docKey{docType, id})
+}
+
+// RemoveWhere drops every document of the type for which match returns true
+func (ix *Index) RemoveWhere(docType string, match func(Document) bool) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	for key, d := range ix.docs {
+		if key.Type == docType && match(d.Document) {
+			ix.remove(key)
+		}
+	}
+}
+
+// swap replaces the contents of ix with those of other, which must not be used afterwards
+func (ix *Index) swap(other *Index) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.docs, ix.postings = other.docs, other.postings
+}
+
+func (ix *Index) remove(key docKey) {
+	d, ok := ix.docs[key]
+	if !ok {
+		return
+	}
+	for _, terms := range d.terms {
+		for term := range terms {
+			delete(ix.postings[term], key)
+			if len(ix.postings[term]) == 0 {
+				delete(ix.postings, term)
+			}
+		}
+	}
+	delete(ix.docs, key)
+}
+
+// Search returns documents containing every query term, best matches first.
+// types restricts the result to the given document types when not empty.
+func (ix *Index) Search(q string, types ...string) []Hit {
+	terms := Tokenize(q)
+	if len(terms) == 0 {
+		return nil
+	}
+
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	// Expand every query term to the indexed terms it matches, with their weight
+	expanded := make([]map[string]float64, len(terms))
+	for i, t := range terms {
+		expanded[i] = map[string]float64{}
+		if _, ok := ix.postings[t]; ok {
+			expanded[i][t] = 1
+		}
+		if i == len(terms)-1 && len(t) >= minPrefixLength {
+			for term := range ix.postings {
+				if term != t && strings.HasPrefix(term, t) {
+					expanded[i][term] = prefixPenalty
+				}
+			}
+		}
+		if len(expanded[i]) == 0 {
+			return nil
+		}
+	}
+
+	allowed := make(map[string]bool, len(types))
+	for _, t := range types {
+		allowed[t] = true
+	}
+
+	total := float64(len(ix.docs))
+	phrase := strings.Join(terms, " ")
+	var hits []Hit
+
+	for key, d := range ix.docs {
+		if len(allowed) > 0 && !allowed[key.Type] {
+			continue
+		}
+
+		fieldScores := make([]float64, len(d.Fields))
+		score := 0.0
+		matchedAll := true
+		for _, candidates := range expanded {
+			termScore := 0.0
+			for term, weight := range candidates {
+				idf := math.Log(1 + total/float64(len(ix.postings[term])))
+				for i, f := range d.Fields {
+					if tf := d.terms[i][term]; tf > 0 {
+						s := weight * idf * f.Weight * (1 + math.Log(float64(tf)))
+						fieldScores[i] += s
+						termScore += s
+					}
+				}
+			}
+			if termScore == 0 {
+				matchedAll = false
+				break
+			}
+			score += termScore
+		}
+		if !matchedAll {
+			continue
+		}
+
+		if strings.Contains(strings.Join(Tokenize(d.Title), " "), phrase) {
+			score *= titlePhraseBoost
+		}
+
+		best := 0
+		for i := range fieldScores {
+			if fieldScores[i] > fieldScores[best] {
+				best = i
+			}
+		}
+
+		hits = append(hits, Hit{
+			Type:       d.Type,
+			ID:         d.ID,
+			MaterialID: d.MaterialID,
+			Title:      d.Title,
+			Field:      d.Fields[best].Name,
+			Snippet:    Snippet(d.Fields[best].Text, terms),
+			Score:      math.Round(score*1000) / 1000,
+		})
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		if hits[i].Type != hits[j].Type {
+			return hits[i].Type < hits[j].Type
+		}
+		return hits[i].ID < hits[j].ID
+	})
+	return hits
+}
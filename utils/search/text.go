@@ -0,0 +1,158 @@
+package search
+
+import (
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	nethtml "golang.org/x/net/html"
+)
+
+// snippetRadius is roughly how many characters are kept on each side of the first match
+const snippetRadius = 80
+
+// StripHTML returns the visible text of an HTML fragment with tags, scripts and styles removed
+func StripHTML(s string) string {
+	var b strings.Builder
+	z := nethtml.NewTokenizer(strings.NewReader(s))
+	skip := 0
+	for {
+		switch z.Next() {
+		case nethtml.ErrorToken:
+			return strings.Join(strings.Fields(b.String()), " ")
+		case nethtml.StartTagToken:
+			if name, _ := z.TagName(); isHiddenTag(name) {
+				skip++
+			}
+			b.WriteByte(' ')
+		case nethtml.EndTagToken:
+			if name, _ := z.TagName(); isHiddenTag(name) && skip > 0 {
+				skip--
+			}
+			b.WriteByte(' ')
+		case nethtml.SelfClosingTagToken:
+			b.WriteByte(' ')
+		case nethtml.TextToken:
+			if skip == 0 {
+				b.Write(z.Text())
+			}
+		}
+	}
+}
+
+func isHiddenTag(name []byte) bool {
+	switch string(name) {
+	case "script", "style":
+		return true
+	}
+	return false
+}
+
+// Tokenize splits text into lower-cased words
+func Tokenize(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+type span struct{ start, end int }
+
+// wordSpans returns the byte offsets of each word in s, in the same order as Tokenize
+func wordSpans(s string) []span {
+	var spans []span
+	start := -1
+	for i, r := range s {
+		word := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if word && start < 0 {
+			start = i
+		} else if !word && start >= 0 {
+			spans = append(spans, span{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, span{start, len(s)})
+	}
+	return spans
+}
+
+// Snippet cuts a window of text around the first word matching one of the terms and
+// wraps every matching word in <mark>. The text is HTML-escaped so it is safe to render.
+func Snippet(text string, terms []string) string {
+	spans := wordSpans(text)
+	matches := make([]bool, len(spans))
+	first := -1
+	for i, sp := range spans {
+		if matchesAny(strings.ToLower(text[sp.start:sp.end]), terms) {
+			matches[i] = true
+			if first < 0 {
+				first = i
+			}
+		}
+	}
+
+	from, to := 0, len(text)
+	if first >= 0 {
+		from = clampStart(text, spans[first].start-snippetRadius)
+		to = clampEnd(text, spans[first].end+snippetRadius)
+	} else if len(text) > 2*snippetRadius {
+		to = clampEnd(text, 2*snippetRadius)
+	}
+
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString("…")
+	}
+	pos := from
+	for i, sp := range spans {
+		if !matches[i] || sp.start < from || sp.end > to {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[pos:sp.start]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(text[sp.start:sp.end]))
+		b.WriteString("</mark>")
+		pos = sp.end
+	}
+	b.WriteString(html.EscapeString(text[pos:to]))
+	if to < len(text) {
+		b.WriteString("…")
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// matchesAny reports whether word equals one of the terms or, for the last term, starts with it
+func matchesAny(word string, terms []string) bool {
+	for i, t := range terms {
+		if word == t || (i == len(terms)-1 && len(t) >= minPrefixLength && strings.HasPrefix(word, t)) {
+			return true
+		}
+	}
+	return false
+}
+
+// clampStart moves i back to a word boundary at or before it
+func clampStart(s string, i int) int {
+	if i <= 0 {
+		return 0
+	}
+	for i > 0 && !utf8.RuneStart(s[i]) {
+		i--
+	}
+	if j := strings.LastIndexByte(s[:i], ' '); j >= 0 {
+		return j + 1
+	}
+	return 0
+}
+
+// clampEnd moves i forward to a word boundary at or after it
+func clampEnd(s string, i int) int {
+	if i >= len(s) {
+		return len(s)
+	}
+	if j := strings.IndexByte(s[i:], ' '); j >= 0 {
+		return i + j
+	}
+	return len(s)
+}
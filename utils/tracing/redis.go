@@ -0,0 +1,16 @@
+package tracing
+
+import (
+	"github.com/redis/go-redis/extra/redisotel/v9"
+	"github.com/redis/go-redis/v9"
+)
+
+// InstrumentRedis traces every command of client as a child of the span in the
+// command context. Arguments are not recorded since they hold cached payloads.
+// A nil client, when Redis is disabled, is left alone.
+func InstrumentRedis(client *redis.Client) error {
+	if client == nil {
+		return nil
+	}
+	return redisotel.InstrumentTracing(client, redisotel.WithDBStatement(false))
+}
@@ -1,7 +0,0 @@
-package utils
-
-import (
-	"github.com/go-playground/validator/v10"
-)
-
-var Validate = validator.New()
@@ -1,7 +1,79 @@
 package validator
 
 import (
+	"errors"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/go-playground/validator/v10"
 )
 
-var Validate = validator.New()
+// Validator checks request inputs and models against their validate tags
+type Validator = validator.Validate
+
+var errInvalidDuration = errors.New("invalid video duration")
+
+// youtubeIDPattern matches the 11 character ID of a YouTube video
+var youtubeIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{11}$`)
+
+// New returns a Validator with the application's custom rules registered.
+// One instance is shared by all handlers; it is safe for concurrent use.
+func New() *Validator {
+	v := validator.New()
+	// Report fields by their JSON names, which are what clients send
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			return f.Name
+		}
+		return name
+	})
+	v.RegisterValidation("youtube_id", func(fl validator.FieldLevel) bool {
+		return youtubeIDPattern.MatchString(fl.Field().String())
+	})
+	v.RegisterValidation("video_duration", func(fl validator.FieldLevel) bool {
+		_, err := ParseDuration(fl.Field().String())
+		return err == nil
+	})
+	return v
+}
+
+// ParseDuration reads a video length written as "mm:ss", "h:mm:ss" or a Go duration such as "12m30s"
+func ParseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if !strings.Contains(s, ":") {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, err
+		}
+		if d <= 0 {
+			return 0, errInvalidDuration
+		}
+		return d, nil
+	}
+
+	parts := strings.Split(s, ":")
+	if len(parts) > 3 {
+		return 0, errInvalidDuration
+	}
+
+	var total time.Duration
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 || len(part) == 0 {
+			return 0, errInvalidDuration
+		}
+		// Minutes and seconds after the leading unit must be below 60
+		if i > 0 && (n >= 60 || len(part) != 2) {
+			return 0, errInvalidDuration
+		}
+		total = total*60 + time.Duration(n)
+	}
+	if total == 0 {
+		return 0, errInvalidDuration
+	}
+	return total * time.Second, nil
+}